@@ -0,0 +1,134 @@
+package budget
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/respawn-app/ksrc/internal/bytesize"
+)
+
+// BytesPerToken approximates how many bytes of source or ksrc output make up
+// one model token.
+const BytesPerToken = 4
+
+// Budget caps the size of a command's output. The zero value is no limit.
+type Budget struct {
+	// Value is the limit as given, in tokens or bytes.
+	Value  int64
+	Tokens bool
+}
+
+// Parse reads a budget: a token count (2000, 2000t, 8k = 8000 tokens) or a
+// byte size ending in B (8000B, 16KB, 1MiB).
+func Parse(value string) (Budget, error) {
+	s := strings.ToLower(strings.TrimSpace(value))
+	if s == "" {
+		return Budget{}, nil
+	}
+	invalid := fmt.Errorf("invalid --budget %q. Try: --budget 2000 (tokens), --budget 8k (tokens) or --budget 16KB (bytes)", value)
+	if strings.HasSuffix(s, "b") {
+		n, err := bytesize.Parse(s)
+		if err != nil || n == 0 {
+			return Budget{}, invalid
+		}
+		return Budget{Value: n}, nil
+	}
+	for _, suffix := range []string{"tokens", "token", "tok", "t"} {
+		if strings.HasSuffix(s, suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, suffix))
+			break
+		}
+	}
+	scale := int64(1)
+	if strings.HasSuffix(s, "k") {
+		s, scale = s[:len(s)-1], 1000
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return Budget{}, invalid
+	}
+	return Budget{Value: n * scale, Tokens: true}, nil
+}
+
+// Enabled reports whether output is limited.
+func (b Budget) Enabled() bool {
+	return b.Value > 0
+}
+
+// Bytes returns the limit in bytes, or 0 without one.
+func (b Budget) Bytes() int {
+	if b.Tokens {
+		return int(b.Value * BytesPerToken)
+	}
+	return int(b.Value)
+}
+
+func (b Budget) String() string {
+	if b.Tokens {
+		return fmt.Sprintf("%d tokens", b.Value)
+	}
+	return fmt.Sprintf("%d bytes", b.Value)
+}
+
+// Writer passes whole lines through until the budget is spent and counts the
+// lines it drops. Once a line does not fit, all later lines are dropped too, so
+// the output is always a prefix of the full output.
+type Writer struct {
+	w            io.Writer
+	left         int
+	line         []byte
+	over         bool
+	OmittedLines int
+	OmittedBytes int
+}
+
+func NewWriter(w io.Writer, b Budget) *Writer {
+	return &Writer{w: w, left: b.Bytes()}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.line = append(w.line, p...)
+			break
+		}
+		w.line = append(w.line, p[:i+1]...)
+		p = p[i+1:]
+		if err := w.emit(); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// Close writes or drops a final line without a newline.
+func (w *Writer) Close() error {
+	if len(w.line) == 0 {
+		return nil
+	}
+	return w.emit()
+}
+
+// Truncated reports whether any output was dropped.
+func (w *Writer) Truncated() bool {
+	return w.over
+}
+
+func (w *Writer) emit() error {
+	line := w.line
+	w.line = w.line[:0]
+	if !w.over && len(line) <= w.left {
+		w.left -= len(line)
+		_, err := w.w.Write(line)
+		return err
+	}
+	w.over = true
+	w.OmittedLines++
+	w.OmittedBytes += len(line)
+	return nil
+}
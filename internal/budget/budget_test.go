@@ -0,0 +1,52 @@
+package budget
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for value, want := range map[string]Budget{
+		"":          {},
+		"2000":      {Value: 2000, Tokens: true},
+		"2000t":     {Value: 2000, Tokens: true},
+		"8k":        {Value: 8000, Tokens: true},
+		"8k tokens": {Value: 8000, Tokens: true},
+		"8000B":     {Value: 8000},
+		"16KB":      {Value: 16 << 10},
+		"1MiB":      {Value: 1 << 20},
+	} {
+		got, err := Parse(value)
+		if err != nil {
+			t.Fatalf("Parse(%q) error: %v", value, err)
+		}
+		if got != want {
+			t.Fatalf("Parse(%q) = %+v, want %+v", value, got, want)
+		}
+	}
+	for _, value := range []string{"0", "-5", "lots", "0B", "5x"} {
+		if _, err := Parse(value); err == nil {
+			t.Fatalf("expected error for %q", value)
+		}
+	}
+	if got := (Budget{Value: 10, Tokens: true}).Bytes(); got != 40 {
+		t.Fatalf("expected 40 bytes for 10 tokens, got %d", got)
+	}
+}
+
+func TestWriterKeepsWholeLines(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWriter(&out, Budget{Value: 10})
+	w.Write([]byte("abc\nde"))
+	w.Write([]byte("f\nghijk\nl\n"))
+	w.Write([]byte("tail"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if out.String() != "abc\ndef\n" {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+	if !w.Truncated() || w.OmittedLines != 3 || w.OmittedBytes != 12 {
+		t.Fatalf("unexpected omission: %d lines, %d bytes", w.OmittedLines, w.OmittedBytes)
+	}
+}
@@ -0,0 +1,14 @@
+package bytesize
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	for value, want := range map[string]int64{"1024": 1024, "500M": 500 << 20, "4g": 4 << 30, "2KiB": 2048, "1 GB": 1 << 30} {
+		if got, err := Parse(value); err != nil || got != want {
+			t.Fatalf("Parse(%q) = %d, %v; want %d", value, got, err, want)
+		}
+	}
+	if _, err := Parse("lots"); err == nil {
+		t.Fatal("expected error for invalid size")
+	}
+}
@@ -0,0 +1,19 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Dir returns the ksrc cache directory: $KSRC_CACHE_DIR, or "ksrc" under the
+// user cache directory (~/.cache/ksrc on Linux, ~/Library/Caches/ksrc on macOS).
+func Dir() (string, error) {
+	if dir := os.Getenv("KSRC_CACHE_DIR"); dir != "" {
+		return dir, nil
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "ksrc"), nil
+}
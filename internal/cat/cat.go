@@ -6,46 +6,127 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 )
 
+// LineRange is a 1-based, inclusive range of lines. End 0 runs to the end
+// of the file; Last selects the last lines of the file instead of Start and
+// End. Resolve turns both into absolute lines.
 type LineRange struct {
 	Start int
 	End   int
+	Last  int
 }
 
+// ParseLineRange reads start,end, start,+count (count lines after start),
+// start: (to the end of the file), -count (the last lines) or a single line.
 func ParseLineRange(value string) (*LineRange, error) {
 	value = strings.TrimSpace(value)
 	if value == "" {
 		return nil, nil
 	}
-	parts := strings.Split(value, ",")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid line range: %q", value)
+	invalid := fmt.Errorf("invalid line range: %q. Try: 120,180, 120,+40, 120: or -50", value)
+	if rest, ok := strings.CutPrefix(value, "-"); ok {
+		n, err := parsePositive(rest)
+		if err != nil {
+			return nil, invalid
+		}
+		return &LineRange{Last: n}, nil
 	}
-	start, err := parsePositive(parts[0])
-	if err != nil {
-		return nil, err
+	if rest, ok := strings.CutSuffix(value, ":"); ok {
+		start, err := parsePositive(rest)
+		if err != nil {
+			return nil, invalid
+		}
+		return &LineRange{Start: start}, nil
 	}
-	end, err := parsePositive(parts[1])
+	first, second, ok := strings.Cut(value, ",")
+	start, err := parsePositive(first)
 	if err != nil {
-		return nil, err
+		return nil, invalid
+	}
+	if !ok {
+		return &LineRange{Start: start, End: start}, nil
+	}
+	second = strings.TrimSpace(second)
+	if count, ok := strings.CutPrefix(second, "+"); ok {
+		n, err := strconv.Atoi(strings.TrimSpace(count))
+		if err != nil || n < 0 {
+			return nil, invalid
+		}
+		return &LineRange{Start: start, End: start + n}, nil
 	}
-	if end < start {
-		return nil, fmt.Errorf("invalid line range: %q", value)
+	end, err := parsePositive(second)
+	if err != nil || end < start {
+		return nil, invalid
 	}
 	return &LineRange{Start: start, End: end}, nil
 }
 
+// ParseLineRanges parses several ranges, skipping empty values.
+func ParseLineRanges(values []string) ([]LineRange, error) {
+	var out []LineRange
+	for _, v := range values {
+		lr, err := ParseLineRange(v)
+		if err != nil {
+			return nil, err
+		}
+		if lr != nil {
+			out = append(out, *lr)
+		}
+	}
+	return out, nil
+}
+
+// Around returns the lines within context of line.
+func Around(line, context int) (LineRange, error) {
+	if line <= 0 || context < 0 {
+		return LineRange{}, fmt.Errorf("invalid --around %d --context %d. Try: --around 240 --context 30", line, context)
+	}
+	return LineRange{Start: max(1, line-context), End: line + context}, nil
+}
+
+// Resolve returns the absolute lines of r in a file of total lines; End is
+// capped at total.
+func (r LineRange) Resolve(total int) LineRange {
+	if r.Last > 0 {
+		return LineRange{Start: max(1, total-r.Last+1), End: total}
+	}
+	if r.End == 0 || r.End > total {
+		r.End = total
+	}
+	return r
+}
+
+func (r LineRange) String() string {
+	switch {
+	case r.Last > 0:
+		return fmt.Sprintf("-%d", r.Last)
+	case r.End == 0:
+		return fmt.Sprintf("%d:", r.Start)
+	}
+	return fmt.Sprintf("%d,%d", r.Start, r.End)
+}
+
 func parsePositive(s string) (int, error) {
-	var n int
-	_, err := fmt.Sscanf(strings.TrimSpace(s), "%d", &n)
+	n, err := strconv.Atoi(strings.TrimSpace(s))
 	if err != nil || n <= 0 {
 		return 0, fmt.Errorf("invalid line range value: %q", s)
 	}
 	return n, nil
 }
 
+// CountLines returns the number of lines in data; a last line without a
+// newline counts.
+func CountLines(data []byte) int {
+	n := bytes.Count(data, []byte("\n"))
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		n++
+	}
+	return n
+}
+
 // ReadFileFromZip reads a file from a zip/jar and optionally slices by line range.
 func ReadFileFromZip(zipPath, innerPath string, lr *LineRange) ([]byte, error) {
 	zr, err := zip.OpenReader(zipPath)
@@ -64,14 +145,24 @@ func ReadFileFromZip(zipPath, innerPath string, lr *LineRange) ([]byte, error) {
 			return nil, err
 		}
 		defer rc.Close()
-		if lr == nil {
-			return io.ReadAll(rc)
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			return nil, err
 		}
-		return readRange(rc, lr)
+		return Slice(data, lr)
 	}
 	return nil, fmt.Errorf("file not found in archive: %s", innerPath)
 }
 
+// Slice returns the lines of data in lr, or data itself when lr is nil.
+func Slice(data []byte, lr *LineRange) ([]byte, error) {
+	if lr == nil {
+		return data, nil
+	}
+	r := lr.Resolve(CountLines(data))
+	return readRange(bytes.NewReader(data), &r)
+}
+
 func readRange(r io.Reader, lr *LineRange) ([]byte, error) {
 	scanner := bufio.NewScanner(r)
 	buf := make([]byte, 0, 64*1024)
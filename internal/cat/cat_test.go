@@ -4,6 +4,7 @@ import (
 	"archive/zip"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -47,3 +48,171 @@ func TestReadFileFromZipRange(t *testing.T) {
 		t.Fatalf("unexpected data: %q", string(data))
 	}
 }
+
+func TestParseTarget(t *testing.T) {
+	id := "org.example:lib:1.0!/a/Flow.kt"
+	for arg, want := range map[string]string{
+		id:                id,
+		id + ":120-180":   id + ":120-180",
+		id + "#L120-L180": id + ":120-180",
+		id + "#L120-180":  id + ":120-180",
+		id + ":7":         id + ":7-7",
+		"a/Flow.kt#L3":    "a/Flow.kt:3-3",
+	} {
+		got, err := ParseTarget(arg)
+		if err != nil {
+			t.Fatalf("ParseTarget(%q) error: %v", arg, err)
+		}
+		if got.String() != want {
+			t.Fatalf("ParseTarget(%q) = %s, want %s", arg, got, want)
+		}
+	}
+	if _, err := ParseTarget(id + ":9-3"); err == nil {
+		t.Fatal("expected error for reversed range")
+	}
+}
+
+func TestFromSearch(t *testing.T) {
+	a := "org.example:lib:1.0!/a/A.kt"
+	b := "org.example:lib:1.0!/b/B.kt"
+	input := a + " 20:5:fun a()\n" +
+		b + " /tmp/x/b/B.kt:3:1:class B\n" +
+		a + " 24:1:fun b()\n" +
+		"\n" +
+		b + " 40-42\n" +
+		"41:3:val x\n" +
+		"40-context\n" +
+		`{"fileId":"` + a + `","line":100,"column":1,"text":"x"}` + "\n" +
+		`{"fileId":"` + a + `","line":101,"column":0,"text":"y","context":true}` + "\n"
+	got, err := FromSearch(strings.NewReader(input), 2)
+	if err != nil {
+		t.Fatalf("FromSearch error: %v", err)
+	}
+	var ranges []string
+	for _, target := range got {
+		ranges = append(ranges, target.String())
+	}
+	want := []string{a + ":18-26", a + ":98-102", b + ":1-5", b + ":38-44"}
+	if strings.Join(ranges, " ") != strings.Join(want, " ") {
+		t.Fatalf("unexpected ranges: %v", ranges)
+	}
+}
+
+func TestOutline(t *testing.T) {
+	src := "package a.b\n\nimport x.Y\n\nclass Foo {\n    fun bar(): Int {\n        return 1\n    }\n\n    val baz = 2\n}\n\nfun top() = Unit\n"
+	want := "package a.b\n5-11: class Foo\n6-8:   fun bar(): Int\n10:   val baz\n13: fun top()\n"
+	if got := string(Outline([]byte(src), "a/b/Foo.kt")); got != want {
+		t.Fatalf("unexpected outline:\n%s", got)
+	}
+}
+
+func TestHead(t *testing.T) {
+	data := []byte("ab\ncd\nef\n")
+	for max, want := range map[int]string{100: "ab\ncd\nef\n", 7: "ab\ncd\n", 6: "ab\ncd\n", 2: "", -1: ""} {
+		if got := string(Head(data, max)); got != want {
+			t.Fatalf("Head(%d) = %q, want %q", max, got, want)
+		}
+	}
+}
+
+func TestStripLines(t *testing.T) {
+	src := `/*
+ * Copyright 2024 Example
+ *
+ * Licensed under the Apache License, Version 2.0
+ */
+@file:JvmName("FlowKt")
+
+package a.b
+
+import x.Y
+import x.z.*
+
+/**
+ * Docs.
+ */
+class Foo { // trailing
+    val url = "http://example.com" // not a comment inside the string
+
+    /* block */ fun bar() = 1
+}
+`
+	format := func(lines []Line) string {
+		return string(Format(lines, true))
+	}
+	got := format(StripLines([]byte(src), "a/b/Foo.kt", Strip{License: true, Imports: true}))
+	want := "     6\t@file:JvmName(\"FlowKt\")\n" +
+		"     7\t\n" +
+		"     8\tpackage a.b\n" +
+		"     9\t\n" +
+		"    13\t/**\n" +
+		"    14\t * Docs.\n" +
+		"    15\t */\n" +
+		"    16\tclass Foo { // trailing\n" +
+		"    17\t    val url = \"http://example.com\" // not a comment inside the string\n" +
+		"    18\t\n" +
+		"    19\t    /* block */ fun bar() = 1\n" +
+		"    20\t}\n"
+	if got != want {
+		t.Fatalf("unexpected license,imports strip:\n%s", got)
+	}
+
+	got = format(StripLines([]byte(src), "a/b/Foo.kt", Strip{Comments: true, Imports: true, Blank: true}))
+	want = "     6\t@file:JvmName(\"FlowKt\")\n" +
+		"     8\tpackage a.b\n" +
+		"    16\tclass Foo {\n" +
+		"    17\t    val url = \"http://example.com\"\n" +
+		"    19\t    fun bar() = 1\n" +
+		"    20\t}\n"
+	if got != want {
+		t.Fatalf("unexpected comments,imports,blank strip:\n%s", got)
+	}
+
+	got = format(InRange(StripLines([]byte(src), "a/b/Foo.kt", Strip{Comments: true}), &LineRange{Start: 12, End: 17}))
+	if got != "    12\t\n    16\tclass Foo {\n    17\t    val url = \"http://example.com\"\n" {
+		t.Fatalf("unexpected stripped range:\n%s", got)
+	}
+
+	if _, err := ParseStrip("license,bogus"); err == nil {
+		t.Fatal("expected error for unknown strip option")
+	}
+}
+
+func TestParseLineRangeForms(t *testing.T) {
+	for value, want := range map[string]LineRange{
+		"120,180":  {Start: 120, End: 180},
+		"120, +40": {Start: 120, End: 160},
+		"120:":     {Start: 120},
+		"-50":      {Last: 50},
+		"7":        {Start: 7, End: 7},
+		" 3 , 3 ":  {Start: 3, End: 3},
+		"120,+0":   {Start: 120, End: 120},
+	} {
+		got, err := ParseLineRange(value)
+		if err != nil {
+			t.Fatalf("ParseLineRange(%q) error: %v", value, err)
+		}
+		if *got != want {
+			t.Fatalf("ParseLineRange(%q) = %+v, want %+v", value, *got, want)
+		}
+	}
+	for _, value := range []string{"0", "5,3", "-0", "a:", "1,+x", "1:3", "--5"} {
+		if _, err := ParseLineRange(value); err == nil {
+			t.Fatalf("expected error for %q", value)
+		}
+	}
+
+	for r, want := range map[LineRange]LineRange{
+		{Start: 120}:           {Start: 120, End: 200},
+		{Last: 50}:             {Start: 151, End: 200},
+		{Last: 500}:            {Start: 1, End: 200},
+		{Start: 190, End: 260}: {Start: 190, End: 200},
+	} {
+		if got := r.Resolve(200); got != want {
+			t.Fatalf("%+v.Resolve(200) = %+v, want %+v", r, got, want)
+		}
+	}
+	if got, _ := Around(3, 5); got != (LineRange{Start: 1, End: 8}) {
+		t.Fatalf("unexpected Around range: %+v", got)
+	}
+}
@@ -0,0 +1,45 @@
+package cat
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/respawn-app/ksrc/internal/kotlin"
+)
+
+// Outline lists the package and declarations of a Kotlin or Java file, one
+// per line as "<line>-<end>: <signature>", members indented under their
+// class. Declarations local to function bodies are not listed.
+func Outline(data []byte, name string) []byte {
+	f := kotlin.Parse(data, kotlin.LangForPath(name))
+	var out bytes.Buffer
+	if f.Package != "" {
+		fmt.Fprintf(&out, "package %s\n", f.Package)
+	}
+	var walk func(decls []*kotlin.Decl, depth int)
+	walk = func(decls []*kotlin.Decl, depth int) {
+		for _, d := range decls {
+			lines := fmt.Sprint(d.Line)
+			if d.EndLine > d.Line {
+				lines = fmt.Sprintf("%d-%d", d.Line, d.EndLine)
+			}
+			fmt.Fprintf(&out, "%s: %s%s\n", lines, strings.Repeat("  ", depth), d.Signature)
+			walk(d.Children, depth+1)
+		}
+	}
+	walk(f.Decls, 0)
+	return out.Bytes()
+}
+
+// Head returns the longest prefix of whole lines of data that fits in max
+// bytes.
+func Head(data []byte, max int) []byte {
+	if len(data) <= max {
+		return data
+	}
+	if max <= 0 {
+		return nil
+	}
+	return data[:bytes.LastIndexByte(data[:max], '\n')+1]
+}
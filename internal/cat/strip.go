@@ -0,0 +1,261 @@
+package cat
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/respawn-app/ksrc/internal/kotlin"
+)
+
+// Strip selects the noise StripLines removes.
+type Strip struct {
+	License  bool
+	Imports  bool
+	Comments bool
+	Blank    bool
+}
+
+// ParseStrip reads a comma-separated list of license, imports, comments and
+// blank.
+func ParseStrip(value string) (Strip, error) {
+	var s Strip
+	for _, part := range strings.Split(value, ",") {
+		switch strings.ToLower(strings.TrimSpace(part)) {
+		case "":
+		case "license":
+			s.License = true
+		case "imports":
+			s.Imports = true
+		case "comments":
+			s.Comments = true
+		case "blank":
+			s.Blank = true
+		default:
+			return Strip{}, fmt.Errorf("invalid --strip %q. Try: --strip license,imports,comments,blank", part)
+		}
+	}
+	return s, nil
+}
+
+// Enabled reports whether anything is stripped.
+func (s Strip) Enabled() bool {
+	return s != Strip{}
+}
+
+// Line is a line of a file with its 1-based number in the original file.
+type Line struct {
+	N    int
+	Text string
+}
+
+// Lines splits data into numbered lines without their line breaks.
+func Lines(data []byte) []Line {
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" {
+		return nil
+	}
+	parts := strings.Split(text, "\n")
+	out := make([]Line, len(parts))
+	for i, p := range parts {
+		out[i] = Line{N: i + 1, Text: strings.TrimSuffix(p, "\r")}
+	}
+	return out
+}
+
+var licenseText = regexp.MustCompile(`(?i)copyright|licen[cs]e|spdx`)
+
+// StripLines returns the lines of a Kotlin or Java file without the selected
+// noise, keeping original line numbers. Comments are found with the lexer, so
+// comment markers inside strings are left alone. The license is the leading
+// comments before any code, if they mention a copyright or license; imports
+// are the import directives of the file header. Lines left empty by stripping
+// are dropped, and runs of blank lines around stripped parts collapse to one.
+func StripLines(data []byte, name string, s Strip) []Line {
+	lines := Lines(data)
+	if !s.Enabled() || len(lines) == 0 {
+		return lines
+	}
+	toks := kotlin.Lex(data, kotlin.LangForPath(name))
+	drop := make(map[int]bool)    // whole lines to remove
+	cut := make(map[int][][2]int) // byte ranges to remove, per line
+	lineStart := lineOffsets(data)
+
+	removeSpan := func(tok kotlin.Token) {
+		for n := tok.Line; n <= tok.EndLine && n <= len(lines); n++ {
+			start, end := lineStart[n-1], lineStart[n-1]+len(lines[n-1].Text)
+			from, to := min(max(start, tok.Start), end), min(end, tok.End)
+			// Blank lines inside block comments get an empty span.
+			cut[n] = append(cut[n], [2]int{from - start, max(from, to) - start})
+		}
+	}
+
+	if s.License {
+		var leading []kotlin.Token
+		for _, tok := range toks {
+			if !tok.IsComment() {
+				break
+			}
+			leading = append(leading, tok)
+		}
+		for _, tok := range leading {
+			if licenseText.MatchString(tok.Text) {
+				for _, t := range leading {
+					if t.Kind != kotlin.DocComment {
+						removeSpan(t)
+					}
+				}
+				break
+			}
+		}
+	}
+	if s.Comments {
+		for _, tok := range toks {
+			if tok.IsComment() {
+				removeSpan(tok)
+			}
+		}
+	}
+	if s.Imports {
+		for _, n := range importLines(toks) {
+			drop[n] = true
+		}
+	}
+
+	out := make([]Line, 0, len(lines))
+	stripped, lastBlank := false, true
+	for _, line := range lines {
+		if drop[line.N] {
+			stripped = true
+			continue
+		}
+		text := line.Text
+		if spans := cut[line.N]; len(spans) > 0 {
+			text = removeSpans(text, spans)
+			if strings.TrimSpace(text) == "" {
+				stripped = true
+				continue
+			}
+			text = strings.TrimRight(text, " \t")
+		}
+		blank := strings.TrimSpace(text) == ""
+		if blank && (s.Blank || stripped && lastBlank) {
+			continue
+		}
+		if !blank {
+			stripped = false
+		}
+		lastBlank = blank
+		out = append(out, Line{N: line.N, Text: text})
+	}
+	return out
+}
+
+// importLines returns the lines of the import directives in the file header.
+func importLines(toks []kotlin.Token) []int {
+	var out []int
+	line := 0
+	for i := 0; i < len(toks); i++ {
+		tok := toks[i]
+		if tok.IsComment() || tok.Line == line {
+			continue
+		}
+		line = tok.Line
+		switch {
+		case tok.Kind == kotlin.Ident && tok.Text == "import":
+			// An import ends with its line, unless a qualified name continues
+			// on the next one.
+			end := tok.EndLine
+			for j := i + 1; j < len(toks); j++ {
+				t := toks[j]
+				if t.IsComment() || t.Line != end && toks[j-1].Text != "." && t.Text != "." {
+					break
+				}
+				end, i = t.EndLine, j
+			}
+			for n := tok.Line; n <= end; n++ {
+				out = append(out, n)
+			}
+			line = end
+		case tok.Kind == kotlin.Punct && tok.Text == "@":
+			// File annotations may span lines inside their arguments.
+			depth := 0
+			for j := i + 1; j < len(toks); j++ {
+				t := toks[j]
+				if depth == 0 && t.Line != line && !t.IsComment() {
+					break
+				}
+				switch t.Text {
+				case "(":
+					depth++
+				case ")":
+					depth--
+				}
+				i, line = j, t.EndLine
+			}
+		case tok.Kind == kotlin.Ident && tok.Text == "package", tok.Kind == kotlin.Punct && tok.Text == ";":
+		default:
+			return out
+		}
+	}
+	return out
+}
+
+func lineOffsets(data []byte) []int {
+	offsets := []int{0}
+	for i, b := range data {
+		if b == '\n' {
+			offsets = append(offsets, i+1)
+		}
+	}
+	return offsets
+}
+
+func removeSpans(text string, spans [][2]int) string {
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	var b strings.Builder
+	pos := 0
+	for _, sp := range spans {
+		if sp[0] > pos {
+			b.WriteString(text[pos:sp[0]])
+		}
+		pos = max(pos, sp[1])
+		// Drop the space that separated the comment from what follows.
+		if sp[1] > sp[0] && pos < len(text) && text[pos] == ' ' && (b.Len() == 0 || strings.HasSuffix(b.String(), " ")) {
+			pos++
+		}
+	}
+	b.WriteString(text[min(pos, len(text)):])
+	return b.String()
+}
+
+// InRange returns the lines whose original number is in lr, or all of them
+// when lr is nil.
+func InRange(lines []Line, lr *LineRange) []Line {
+	if lr == nil {
+		return lines
+	}
+	var out []Line
+	for _, l := range lines {
+		if l.N >= lr.Start && l.N <= lr.End {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// Format joins lines, prefixed with their original numbers when numbers is
+// set.
+func Format(lines []Line, numbers bool) []byte {
+	var out bytes.Buffer
+	for _, l := range lines {
+		if numbers {
+			fmt.Fprintf(&out, "%6d\t", l.N)
+		}
+		out.WriteString(l.Text)
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,155 @@
+package cat
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Target is a file to print: a file-id or a path inside a jar, with an
+// optional line range.
+type Target struct {
+	File  string
+	Range *LineRange
+}
+
+var (
+	colonRange  = regexp.MustCompile(`:(\d+)(?:-(\d*))?$`)
+	anchorRange = regexp.MustCompile(`#L(\d+)(?:-L?(\d+))?$`)
+)
+
+// ParseTarget splits an inline range off a file argument: File.kt:120-180,
+// File.kt#L120-L180, a single line (File.kt:120, File.kt#L120) or the rest of
+// the file (File.kt:120-).
+func ParseTarget(arg string) (Target, error) {
+	arg = strings.TrimSpace(arg)
+	// Only the part after the coordinate may carry a range; the coordinate
+	// itself is colon-separated.
+	offset := 0
+	if idx := strings.Index(arg, "!/"); idx >= 0 {
+		offset = idx + 2
+	}
+	for _, re := range []*regexp.Regexp{anchorRange, colonRange} {
+		m := re.FindStringSubmatchIndex(arg[offset:])
+		if m == nil {
+			continue
+		}
+		start, _ := strconv.Atoi(arg[offset+m[2] : offset+m[3]])
+		end := start
+		switch {
+		case m[4] >= 0 && m[4] == m[5]:
+			end = 0 // File.kt:120- runs to the end of the file
+		case m[4] >= 0:
+			end, _ = strconv.Atoi(arg[offset+m[4] : offset+m[5]])
+		}
+		if start <= 0 || end != 0 && end < start {
+			return Target{}, fmt.Errorf("invalid line range in %q. Try: <file-id>:120-180 or <file-id>#L120-L180", arg)
+		}
+		return Target{File: arg[:offset+m[0]], Range: &LineRange{Start: start, End: end}}, nil
+	}
+	return Target{File: arg}, nil
+}
+
+func (t Target) String() string {
+	switch {
+	case t.Range == nil:
+		return t.File
+	case t.Range.Last > 0:
+		return fmt.Sprintf("%s (last %d lines)", t.File, t.Range.Last)
+	case t.Range.End == 0:
+		return fmt.Sprintf("%s:%d-", t.File, t.Range.Start)
+	}
+	return fmt.Sprintf("%s:%d-%d", t.File, t.Range.Start, t.Range.End)
+}
+
+var (
+	hitLine    = regexp.MustCompile(`^(.+?!/.*?) (\d+):\d+:`)
+	hitPath    = regexp.MustCompile(`^(.+?!/.*?) .*?:(\d+):\d+:`)
+	blockStart = regexp.MustCompile(`^(.+!/.*) (\d+)-(\d+)$`)
+)
+
+// FromSearch reads `ksrc search` output (plain, --show-extracted-path,
+// --context blocks or --json) and returns the lines around each hit, context
+// lines on either side. Overlapping ranges of a file are merged; files are
+// returned in the order they first appear, ranges in line order.
+func FromSearch(r io.Reader, context int) ([]Target, error) {
+	if context < 0 {
+		context = 0
+	}
+	ranges := make(map[string][]LineRange)
+	var order []string
+	add := func(file string, start, end int) {
+		if _, ok := ranges[file]; !ok {
+			order = append(order, file)
+		}
+		ranges[file] = append(ranges[file], LineRange{Start: max(1, start-context), End: end + context})
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(line, "{") {
+			var hit struct {
+				FileID    string `json:"fileId"`
+				Line      int    `json:"line"`
+				StartLine int    `json:"startLine"`
+				EndLine   int    `json:"endLine"`
+				Context   bool   `json:"context"`
+			}
+			if json.Unmarshal([]byte(line), &hit) != nil || hit.FileID == "" || hit.Context {
+				continue
+			}
+			if hit.StartLine > 0 {
+				add(hit.FileID, hit.StartLine, hit.EndLine)
+			} else if hit.Line > 0 {
+				add(hit.FileID, hit.Line, hit.Line)
+			}
+			continue
+		}
+		if m := blockStart.FindStringSubmatch(line); m != nil {
+			start, _ := strconv.Atoi(m[2])
+			end, _ := strconv.Atoi(m[3])
+			add(m[1], start, end)
+			continue
+		}
+		m := hitLine.FindStringSubmatch(line)
+		if m == nil {
+			m = hitPath.FindStringSubmatch(line)
+		}
+		if m != nil {
+			n, _ := strconv.Atoi(m[2])
+			add(m[1], n, n)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var out []Target
+	for _, file := range order {
+		for _, lr := range mergeRanges(ranges[file]) {
+			out = append(out, Target{File: file, Range: &lr})
+		}
+	}
+	return out, nil
+}
+
+// mergeRanges sorts ranges and joins the ones that overlap or touch.
+func mergeRanges(in []LineRange) []LineRange {
+	sort.Slice(in, func(i, j int) bool { return in[i].Start < in[j].Start })
+	var out []LineRange
+	for _, lr := range in {
+		if n := len(out); n > 0 && lr.Start <= out[n-1].End+1 {
+			out[n-1].End = max(out[n-1].End, lr.End)
+			continue
+		}
+		out = append(out, lr)
+	}
+	return out
+}
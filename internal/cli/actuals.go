@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/respawn-app/ksrc/internal/kotlin"
+	"github.com/respawn-app/ksrc/internal/resolve"
+	"github.com/respawn-app/ksrc/internal/srcjar"
+	"github.com/respawn-app/ksrc/internal/symbols"
+	"github.com/spf13/cobra"
+)
+
+func newActualsCmd(app *App) *cobra.Command {
+	var flags ResolveFlags
+
+	cmd := &cobra.Command{
+		Use:   "actuals <symbol|file-id:line> [<module>]",
+		Short: "List the expect declaration of a multiplatform symbol and its actuals",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			symbol := strings.TrimSpace(args[0])
+			if symbol == "" {
+				return fmt.Errorf("symbol is required. Try: ksrc actuals kotlinx.coroutines.Dispatchers org.jetbrains.kotlinx:kotlinx-coroutines-core")
+			}
+			if len(args) == 2 {
+				if flags.Module != "" && flags.Module != args[1] {
+					return fmt.Errorf("module specified twice (arg and --module). Use only one.")
+				}
+				flags.Module = args[1]
+			}
+			atLine := strings.Contains(symbol, "!/")
+			var coord resolve.Coord
+			var inner string
+			var line int
+			if atLine {
+				var err error
+				coord, inner, line, err = resolve.ParseFileLine(symbol)
+				if err != nil {
+					return err
+				}
+				if flags.Module == "" && flags.Group == "" {
+					// Platform artifacts of a KMP library share its group.
+					flags.Group = coord.Group
+				}
+			}
+
+			// Resolve without the module selector so that platform artifacts
+			// (foo-jvm, foo-iosarm64) of the selected module are kept.
+			module := flags.Module
+			flags.Module = ""
+			flags.All = true
+			sources, _, meta, err := resolveSources(cmd.Context(), app, flags, "", true, true)
+			if err != nil {
+				return err
+			}
+			emitWarnings(cmd, meta)
+			if module != "" {
+				sources = withPlatformArtifacts(sources, module)
+				flags.Module = module
+			}
+			if len(sources) == 0 {
+				return noSourcesErr(flags, noSourcesHintForFlags(flags, meta))
+			}
+
+			archives := srcjar.NewArchives()
+			defer archives.Close()
+			var found []symbols.Counterpart
+			if atLine {
+				jarPath, err := findJarByCoord(sources, coord)
+				if err != nil {
+					return err
+				}
+				entry, err := srcjar.Find(archives, resolve.SourceJar{Coord: coord, Path: jarPath}, inner)
+				if err != nil {
+					return err
+				}
+				if symbol, found, err = symbols.ActualsAt(archives, sources, entry, line); err != nil {
+					return err
+				}
+			} else if found, err = symbols.Actuals(archives, sources, symbol); err != nil {
+				return err
+			}
+			if len(found) == 0 {
+				return fmt.Errorf("no expect/actual declaration found for %s. Try: ksrc search --all -q \"(expect|actual) .*\\b%s\\b\"", symbol, kotlin.SimpleName(symbol))
+			}
+			for _, c := range found {
+				set := c.SourceSet
+				if set == "" {
+					set = "-"
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s %s %d:%s\n", set, c.FileID, c.Line, c.Signature)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Project, "project", ".", "project root")
+	cmd.Flags().StringVar(&flags.Module, "module", "", "module selector (group:artifact[:version]); platform artifacts are included")
+	cmd.Flags().StringVar(&flags.Group, "group", "", "group filter")
+	cmd.Flags().StringVar(&flags.Artifact, "artifact", "", "artifact filter")
+	cmd.Flags().StringVar(&flags.Version, "version", "", "version filter")
+	cmd.Flags().StringVar(&flags.Scope, "scope", "compile", "dependency scope (compile|runtime|test|all)")
+	cmd.Flags().StringVar(&flags.Config, "config", "", "configuration name(s) or glob patterns (comma-separated)")
+	cmd.Flags().StringVar(&flags.Targets, "targets", "", "KMP targets (comma-separated)")
+	cmd.Flags().StringSliceVar(&flags.Subprojects, "subproject", nil, "limit to subproject (repeatable)")
+	cmd.Flags().BoolVar(&flags.Offline, "offline", false, "offline mode")
+	cmd.Flags().BoolVar(&flags.Refresh, "refresh", false, "refresh dependencies")
+	cmd.Flags().BoolVar(&flags.IncludeBuildSrc, "buildsrc", true, "include buildSrc dependencies (set --buildsrc=false to disable)")
+	cmd.Flags().BoolVar(&flags.IncludeBuildscript, "buildscript", true, "include buildscript classpath dependencies (set --buildscript=false to disable)")
+	cmd.Flags().BoolVar(&flags.IncludeIncludedBuilds, "include-builds", true, "include composite builds (includeBuild) (set --include-builds=false to disable)")
+
+	return cmd
+}
+
+// withPlatformArtifacts keeps the sources matching a module selector and the
+// KMP platform artifacts published next to it (artifact-jvm, artifact-iosarm64, ...).
+func withPlatformArtifacts(sources []resolve.SourceJar, selector string) []resolve.SourceJar {
+	platform := ""
+	if parts := strings.SplitN(selector, ":", 3); len(parts) >= 2 {
+		parts[1] += "-*"
+		platform = strings.Join(parts, ":")
+	}
+	out := make([]resolve.SourceJar, 0, len(sources))
+	for _, s := range sources {
+		if resolve.MatchModule(selector, s.Coord) || (platform != "" && resolve.MatchModule(platform, s.Coord)) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
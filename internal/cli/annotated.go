@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/respawn-app/ksrc/internal/symbols"
+	"github.com/spf13/cobra"
+)
+
+func newAnnotatedCmd(app *App) *cobra.Command {
+	var flags ResolveFlags
+
+	cmd := &cobra.Command{
+		Use:   "annotated <annotation> [<module>]",
+		Short: "List declarations carrying an annotation",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			annotation := strings.TrimPrefix(strings.TrimSpace(args[0]), "@")
+			if annotation == "" {
+				return fmt.Errorf("annotation is required. Try: ksrc annotated Composable androidx.compose.material3:material3")
+			}
+			if len(args) == 2 {
+				if flags.Module != "" && flags.Module != args[1] {
+					return fmt.Errorf("module specified twice (arg and --module). Use only one.")
+				}
+				flags.Module = args[1]
+			}
+			if err := requireModuleOrAll(flags.Module, flags.All); err != nil {
+				return err
+			}
+			sources, _, meta, err := resolveSources(cmd.Context(), app, flags, "", true, true)
+			if err != nil {
+				return err
+			}
+			emitWarnings(cmd, meta)
+			if len(sources) == 0 {
+				return noSourcesErr(flags, noSourcesHintForFlags(flags, meta))
+			}
+			found, err := symbols.Annotated(nil, sources, annotation)
+			if err != nil {
+				return err
+			}
+			for _, s := range found {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s %d:%s\n", s.FileID, s.Line, s.Signature)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Project, "project", ".", "project root")
+	cmd.Flags().BoolVar(&flags.All, "all", false, "search all resolved dependencies")
+	cmd.Flags().StringVar(&flags.Module, "module", "", "module selector (group:artifact[:version])")
+	cmd.Flags().StringVar(&flags.Group, "group", "", "group filter")
+	cmd.Flags().StringVar(&flags.Artifact, "artifact", "", "artifact filter")
+	cmd.Flags().StringVar(&flags.Version, "version", "", "version filter")
+	cmd.Flags().StringVar(&flags.Scope, "scope", "compile", "dependency scope (compile|runtime|test|all)")
+	cmd.Flags().StringVar(&flags.Config, "config", "", "configuration name(s) or glob patterns (comma-separated)")
+	cmd.Flags().StringVar(&flags.Targets, "targets", "", "KMP targets (comma-separated)")
+	cmd.Flags().StringSliceVar(&flags.Subprojects, "subproject", nil, "limit to subproject (repeatable)")
+	cmd.Flags().BoolVar(&flags.Offline, "offline", false, "offline mode")
+	cmd.Flags().BoolVar(&flags.Refresh, "refresh", false, "refresh dependencies")
+	cmd.Flags().BoolVar(&flags.IncludeBuildSrc, "buildsrc", true, "include buildSrc dependencies (set --buildsrc=false to disable)")
+	cmd.Flags().BoolVar(&flags.IncludeBuildscript, "buildscript", true, "include buildscript classpath dependencies (set --buildscript=false to disable)")
+	cmd.Flags().BoolVar(&flags.IncludeIncludedBuilds, "include-builds", true, "include composite builds (includeBuild) (set --include-builds=false to disable)")
+
+	return cmd
+}
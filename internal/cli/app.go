@@ -1,9 +1,14 @@
 package cli
 
-import "github.com/respawn-app/ksrc/internal/executil"
+import (
+	"github.com/respawn-app/ksrc/internal/budget"
+	"github.com/respawn-app/ksrc/internal/executil"
+)
 
 type App struct {
 	Runner executil.Runner
+	// Budget is the --budget of the running command.
+	Budget budget.Budget
 }
 
 func NewApp() *App {
@@ -0,0 +1,576 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/respawn-app/ksrc/internal/budget"
+	"github.com/respawn-app/ksrc/internal/cat"
+	"github.com/respawn-app/ksrc/internal/extract"
+	"github.com/respawn-app/ksrc/internal/index"
+	"github.com/respawn-app/ksrc/internal/ls"
+	"github.com/respawn-app/ksrc/internal/resolve"
+	"github.com/respawn-app/ksrc/internal/search"
+	"github.com/respawn-app/ksrc/internal/srcjar"
+	"github.com/respawn-app/ksrc/internal/symbols"
+	"github.com/spf13/cobra"
+)
+
+func newBatchCmd(app *App) *cobra.Command {
+	var flags ResolveFlags
+
+	cmd := &cobra.Command{
+		Use:   "batch",
+		Short: "Run JSON requests from stdin against one resolution",
+		Long: "Reads one JSON request per line from stdin, e.g.\n" +
+			"  {\"id\":1,\"op\":\"cat\",\"fileId\":\"group:artifact:version!/path/File.kt\",\"lines\":\"1,40\"}\n" +
+			"and writes one JSON response per request, in order. Ops: search, cat, where, def, class, ls.\n" +
+			"The project is resolved once for all requests; a failing request reports its error and the batch continues.",
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{ownsBudget: "responses"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags.All = true
+			sources, _, meta, err := resolveSources(cmd.Context(), app, flags, "", true, true)
+			if err != nil {
+				return err
+			}
+			emitWarnings(cmd, meta)
+			if len(sources) == 0 {
+				return noSourcesErr(flags, noSourcesHintForFlags(flags, meta))
+			}
+			b := &batch{app: app, project: flags.Project, sources: sources, edges: meta.Edges, archives: srcjar.NewArchives(), budget: app.Budget}
+			defer b.archives.Close()
+			if store, err := index.DefaultStore(); err == nil {
+				b.index = store
+			}
+			return b.run(cmd.Context(), cmd.InOrStdin(), cmd.OutOrStdout())
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Project, "project", ".", "project root")
+	cmd.Flags().StringVar(&flags.Scope, "scope", "compile", "dependency scope (compile|runtime|test|all)")
+	cmd.Flags().StringVar(&flags.Config, "config", "", "configuration name(s) or glob patterns (comma-separated)")
+	cmd.Flags().StringVar(&flags.Targets, "targets", "", "KMP targets (comma-separated)")
+	cmd.Flags().StringSliceVar(&flags.Subprojects, "subproject", nil, "limit to subproject (repeatable)")
+	cmd.Flags().BoolVar(&flags.Offline, "offline", false, "offline mode")
+	cmd.Flags().BoolVar(&flags.Refresh, "refresh", false, "refresh dependencies")
+	cmd.Flags().BoolVar(&flags.IncludeBuildSrc, "buildsrc", true, "include buildSrc dependencies (set --buildsrc=false to disable)")
+	cmd.Flags().BoolVar(&flags.IncludeBuildscript, "buildscript", true, "include buildscript classpath dependencies (set --buildscript=false to disable)")
+	cmd.Flags().BoolVar(&flags.IncludeIncludedBuilds, "include-builds", true, "include composite builds (includeBuild) (set --include-builds=false to disable)")
+
+	return cmd
+}
+
+// batchRequest is one line of batch input. Fields mirror the flags of the
+// corresponding command; Module narrows the resolved sources like <module>.
+type batchRequest struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Op     string          `json:"op"`
+	Module string          `json:"module,omitempty"`
+	All    bool            `json:"all,omitempty"`
+
+	// search
+	Query        stringList `json:"q,omitempty"`
+	And          bool       `json:"and,omitempty"`
+	Not          stringList `json:"not,omitempty"`
+	Per          string     `json:"per,omitempty"`
+	FixedStrings bool       `json:"fixedStrings,omitempty"`
+	Word         bool       `json:"word,omitempty"`
+	RGArgs       stringList `json:"rgArgs,omitempty"`
+	Context      int        `json:"context,omitempty"`
+	Package      string     `json:"package,omitempty"`
+	SourceSet    string     `json:"sourceSet,omitempty"`
+	Path         string     `json:"path,omitempty"`
+	In           string     `json:"in,omitempty"`
+	MaxResults   int        `json:"maxResults,omitempty"`
+
+	// cat, where, def, class, ls
+	FileID      string     `json:"fileId,omitempty"`
+	File        string     `json:"file,omitempty"`
+	Lines       stringList `json:"lines,omitempty"`
+	Around      int        `json:"around,omitempty"`
+	LineNumbers bool       `json:"lineNumbers,omitempty"`
+	Coord       string     `json:"coord,omitempty"`
+	Position    string     `json:"position,omitempty"`
+	FQN         string     `json:"fqn,omitempty"`
+	Glob        string     `json:"glob,omitempty"`
+	Find        string     `json:"find,omitempty"`
+}
+
+type batchResponse struct {
+	ID        json.RawMessage  `json:"id,omitempty"`
+	Op        string           `json:"op,omitempty"`
+	Result    any              `json:"result,omitempty"`
+	Error     string           `json:"error,omitempty"`
+	Truncated *batchTruncation `json:"truncated,omitempty"`
+}
+
+// batchTruncation reports what --budget cut from a response, in the shape of
+// the search --json summary.
+type batchTruncation struct {
+	Budget  string           `json:"budget"`
+	Omitted []*omittedModule `json:"omitted"`
+}
+
+// stringList accepts a JSON string or an array of strings.
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*l = stringList{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return fmt.Errorf("expected a string or an array of strings")
+	}
+	*l = many
+	return nil
+}
+
+type batchSearchResult struct {
+	Matches   []search.Match `json:"matches"`
+	Truncated bool           `json:"truncated,omitempty"`
+}
+
+type batchFile struct {
+	FileID    string `json:"fileId"`
+	StartLine int    `json:"startLine,omitempty"`
+	EndLine   int    `json:"endLine,omitempty"`
+	Text      string `json:"text"`
+}
+
+type batchLocation struct {
+	FileID string `json:"fileId,omitempty"`
+	Coord  string `json:"coord,omitempty"`
+	Jar    string `json:"jar"`
+}
+
+type batchSymbol struct {
+	FileID    string `json:"fileId"`
+	Line      int    `json:"line"`
+	StartLine int    `json:"startLine"`
+	EndLine   int    `json:"endLine"`
+	Signature string `json:"signature"`
+}
+
+type batchListedFile struct {
+	FileID string `json:"fileId"`
+	Lines  int    `json:"lines"`
+	Bytes  int64  `json:"bytes"`
+}
+
+// batch executes requests against one resolution, sharing open jars.
+type batch struct {
+	app      *App
+	project  string
+	sources  []resolve.SourceJar
+	edges    []resolve.Edge
+	archives *srcjar.Archives
+	index    *index.Store
+	budget   budget.Budget
+	used     int
+}
+
+func (b *batch) run(ctx context.Context, in io.Reader, out io.Writer) error {
+	r := bufio.NewReader(in)
+	for {
+		line, readErr := r.ReadBytes('\n')
+		if text := strings.TrimSpace(string(line)); text != "" {
+			var req batchRequest
+			resp := batchResponse{}
+			if err := json.Unmarshal([]byte(text), &req); err != nil {
+				resp.Error = fmt.Sprintf("invalid request: %v", err)
+			} else {
+				resp.ID, resp.Op = req.ID, req.Op
+				result, err := b.do(ctx, req)
+				if err != nil {
+					resp.Error = err.Error()
+				} else {
+					resp.Result = result
+				}
+			}
+			data, err := b.encode(resp)
+			if err != nil {
+				return err
+			}
+			if _, err := out.Write(data); err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+// encode renders resp as one JSON line. Under --budget, a result that does
+// not fit in what is left is cut: a search keeps the matches that fit, other
+// results are dropped, and the response reports the rest as truncated.
+func (b *batch) encode(resp batchResponse) ([]byte, error) {
+	data, err := marshalLine(resp)
+	if err != nil || !b.budget.Enabled() || resp.Result == nil || len(data) <= b.budget.Bytes()-b.used {
+		b.used += len(data)
+		return data, err
+	}
+	omitted := &omissions{}
+	if res, ok := resp.Result.(batchSearchResult); ok {
+		kept := res
+		kept.Matches = []search.Match{}
+		base, err := marshalLine(batchResponse{ID: resp.ID, Op: resp.Op, Result: kept})
+		if err != nil {
+			return nil, err
+		}
+		size := len(base)
+		for _, m := range res.Matches {
+			if omitted.matches == 0 {
+				md, err := json.Marshal(m)
+				if err != nil {
+					return nil, err
+				}
+				if size+len(md)+1 <= b.budget.Bytes()-b.used {
+					size += len(md) + 1
+					kept.Matches = append(kept.Matches, m)
+					continue
+				}
+			}
+			omitted.add(m.FileID, 1)
+		}
+		resp.Result = kept
+	} else {
+		for _, fileID := range resultFileIDs(resp.Result) {
+			omitted.add(fileID, 0)
+		}
+		resp.Result = nil
+	}
+	resp.Truncated = &batchTruncation{Budget: b.budget.String(), Omitted: omitted.modules}
+	data, err = marshalLine(resp)
+	b.used += len(data)
+	return data, err
+}
+
+func marshalLine(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	return append(data, '\n'), err
+}
+
+// resultFileIDs returns the files (or the coord, for where) a result refers to.
+func resultFileIDs(result any) []string {
+	var ids []string
+	switch r := result.(type) {
+	case batchFile:
+		ids = append(ids, r.FileID)
+	case []batchFile:
+		for _, f := range r {
+			ids = append(ids, f.FileID)
+		}
+	case batchLocation:
+		ids = append(ids, r.FileID+r.Coord)
+	case []batchSymbol:
+		for _, sym := range r {
+			ids = append(ids, sym.FileID)
+		}
+	case []batchListedFile:
+		for _, f := range r {
+			ids = append(ids, f.FileID)
+		}
+	}
+	return ids
+}
+
+func (b *batch) do(ctx context.Context, req batchRequest) (any, error) {
+	switch req.Op {
+	case "search":
+		return b.search(ctx, req)
+	case "cat":
+		return b.cat(req)
+	case "where":
+		return b.where(req)
+	case "def":
+		return b.def(req)
+	case "class":
+		return b.class(req)
+	case "ls":
+		return b.ls(req)
+	case "":
+		return nil, fmt.Errorf("op is required. Try: {\"op\":\"cat\",\"fileId\":\"<file-id>\"}")
+	}
+	return nil, fmt.Errorf("unknown op %q. Try: search, cat, where, def, class or ls", req.Op)
+}
+
+// jars returns the resolved sources selected by module, or all of them.
+func (b *batch) jars(module string) ([]resolve.SourceJar, error) {
+	if module == "" {
+		return b.sources, nil
+	}
+	jars := resolve.FilterSources(b.sources, module, "", "", "")
+	if len(jars) == 0 {
+		return nil, fmt.Errorf("E_NO_SOURCES: no resolved sources match %s. Try: ksrc deps to see resolved coords", module)
+	}
+	return jars, nil
+}
+
+// jarFor returns the resolved source jar of coord.
+func (b *batch) jarFor(coord resolve.Coord) (resolve.SourceJar, error) {
+	path, err := findJarByCoord(b.sources, coord)
+	return resolve.SourceJar{Coord: coord, Path: path}, err
+}
+
+func (b *batch) search(ctx context.Context, req batchRequest) (any, error) {
+	if req.Module == "" && !req.All {
+		return nil, fmt.Errorf("E_NO_MODULE: module required unless all is set. Try: {\"op\":\"search\",\"module\":\"group:artifact\",\"q\":\"<pattern>\"}")
+	}
+	jars, err := b.jars(req.Module)
+	if err != nil {
+		return nil, err
+	}
+	per := req.Per
+	if per == "" {
+		per = "file"
+	}
+	query, err := buildQuery(req.Query, req.And, false, req.Not, per, req.FixedStrings, req.Word)
+	if err != nil {
+		return nil, err
+	}
+	regions, err := search.ParseRegions(splitCSV(req.In))
+	if err != nil {
+		return nil, err
+	}
+	rgArgs := append([]string(nil), req.RGArgs...)
+	if req.Context > 0 {
+		rgArgs = append(rgArgs, "-C", strconv.Itoa(req.Context))
+	}
+	opts := search.Options{
+		Query:   query,
+		Jars:    jars,
+		RGArgs:  rgArgs,
+		WorkDir: b.project,
+		Filter: search.Filter{
+			Packages:   splitCSV(req.Package),
+			SourceSets: splitCSV(req.SourceSet),
+			Paths:      splitCSV(req.Path),
+		},
+		In:         regions,
+		Classify:   true,
+		Extracts:   extract.DefaultCache,
+		Index:      b.index,
+		MaxResults: req.MaxResults,
+	}
+	res := batchSearchResult{Matches: []search.Match{}}
+	err = search.Stream(ctx, b.app.Runner, opts, func(m search.Match) error {
+		m.File = ""
+		res.Matches = append(res.Matches, m)
+		return nil
+	})
+	if errors.Is(err, search.ErrLimitReached) {
+		res.Truncated, err = true, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (b *batch) cat(req batchRequest) (any, error) {
+	arg := req.FileID
+	if arg == "" {
+		arg = req.File
+	}
+	ctxLines := req.Context
+	if ctxLines == 0 {
+		ctxLines = 5
+	}
+	targets, err := catTargets([]string{arg}, req.Lines, req.Around, ctxLines)
+	if err != nil {
+		return nil, err
+	}
+	var entry srcjar.Entry
+	switch {
+	case req.FileID != "":
+		coord, inner, err := resolve.ParseFileID(targets[0].File)
+		if err != nil {
+			return nil, err
+		}
+		jar, err := b.jarFor(coord)
+		if err != nil {
+			return nil, err
+		}
+		if entry, err = srcjar.Find(b.archives, jar, inner); err != nil {
+			return nil, err
+		}
+	case req.File != "" && req.Module != "":
+		jars, err := b.jars(req.Module)
+		if err != nil {
+			return nil, err
+		}
+		if entry, err = findEntry(b.archives, jars, targets[0].File); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("fileId, or file with module, is required. Try: {\"op\":\"cat\",\"fileId\":\"<file-id>\"}")
+	}
+	data, err := entry.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]batchFile, 0, len(targets))
+	for _, t := range targets {
+		f := batchFile{FileID: entry.FileID()}
+		lines := cat.Lines(data)
+		if t.Range != nil {
+			r := t.Range.Resolve(len(lines))
+			lines = cat.InRange(lines, &r)
+			f.StartLine, f.EndLine = r.Start, r.End
+		}
+		if req.LineNumbers {
+			f.Text = string(cat.Format(lines, true))
+		} else if t.Range != nil {
+			f.Text = string(cat.Format(lines, false))
+		} else {
+			f.Text = string(data)
+		}
+		files = append(files, f)
+	}
+	if len(files) == 1 {
+		return files[0], nil
+	}
+	return files, nil
+}
+
+func (b *batch) where(req batchRequest) (any, error) {
+	switch {
+	case req.FileID != "":
+		coord, inner, err := resolve.ParseFileID(req.FileID)
+		if err != nil {
+			return nil, err
+		}
+		jar, err := b.jarFor(coord)
+		if err != nil {
+			return nil, err
+		}
+		return batchLocation{FileID: resolve.FormatFileID(coord, inner), Jar: jar.Path}, nil
+	case req.Coord != "":
+		coord, err := resolve.ParseCoord(req.Coord)
+		if err != nil {
+			return nil, err
+		}
+		jars, err := b.jars(coord.String())
+		if err != nil {
+			return nil, err
+		}
+		if len(jars) > 1 {
+			coords := make([]string, 0, len(jars))
+			for _, jar := range jars {
+				coords = append(coords, jar.Coord.String())
+			}
+			return nil, fmt.Errorf("E_AMBIGUOUS: %s matches %d source jars (%s). Try: a full group:artifact:version coord, or a fileId from search", req.Coord, len(jars), strings.Join(coords, ", "))
+		}
+		return batchLocation{Coord: jars[0].Coord.String(), Jar: jars[0].Path}, nil
+	}
+	return nil, fmt.Errorf("fileId or coord is required. Try: {\"op\":\"where\",\"coord\":\"group:artifact\"}")
+}
+
+func (b *batch) def(req batchRequest) (any, error) {
+	if req.Position == "" {
+		return nil, fmt.Errorf("position is required. Try: {\"op\":\"def\",\"position\":\"<file-id>:<line>:<col>\"}")
+	}
+	coord, inner, line, col, err := resolve.ParseFilePosition(req.Position)
+	if err != nil {
+		return nil, err
+	}
+	jar, err := b.jarFor(coord)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := srcjar.Find(b.archives, jar, inner)
+	if err != nil {
+		return nil, err
+	}
+	closure := resolve.Closure([]resolve.Coord{coord}, b.edges, -1)
+	var deps []resolve.SourceJar
+	for _, s := range b.sources {
+		if closure[s.Coord.String()] {
+			deps = append(deps, s)
+		}
+	}
+	name, defs, err := symbols.Definitions(b.archives, deps, entry, line, col)
+	if err != nil {
+		return nil, err
+	}
+	if len(defs) == 0 {
+		return nil, fmt.Errorf("definition not found for %q", name)
+	}
+	return batchSymbols(defs), nil
+}
+
+func (b *batch) class(req batchRequest) (any, error) {
+	if !strings.Contains(req.FQN, ".") {
+		return nil, fmt.Errorf("fqn is required. Try: {\"op\":\"class\",\"fqn\":\"androidx.compose.runtime.State\"}")
+	}
+	jars, err := b.jars(req.Module)
+	if err != nil {
+		return nil, err
+	}
+	found, err := symbols.Class(b.archives, jars, req.FQN)
+	if err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("class not found: %s", req.FQN)
+	}
+	return batchSymbols(found), nil
+}
+
+func (b *batch) ls(req batchRequest) (any, error) {
+	if req.Module == "" && !req.All && req.Find == "" {
+		return nil, fmt.Errorf("E_NO_MODULE: module required unless all or find is set. Try: {\"op\":\"ls\",\"module\":\"group:artifact\"}")
+	}
+	jars, err := b.jars(req.Module)
+	if err != nil {
+		return nil, err
+	}
+	var keep func(string) bool
+	if globs := splitCSV(req.Glob); len(globs) > 0 {
+		keep = search.Filter{Paths: globs}.KeepPath
+	}
+	files, err := ls.List(b.archives, jars, keep)
+	if err != nil {
+		return nil, err
+	}
+	if req.Find != "" {
+		files = ls.Find(files, req.Find)
+	}
+	if req.MaxResults > 0 && len(files) > req.MaxResults {
+		files = files[:req.MaxResults]
+	}
+	if err := ls.CountLines(files); err != nil {
+		return nil, err
+	}
+	out := make([]batchListedFile, len(files))
+	for i, f := range files {
+		out[i] = batchListedFile{FileID: f.FileID(), Lines: f.Lines, Bytes: f.Bytes}
+	}
+	return out, nil
+}
+
+func batchSymbols(found []symbols.Symbol) []batchSymbol {
+	out := make([]batchSymbol, len(found))
+	for i, s := range found {
+		out[i] = batchSymbol{FileID: s.FileID, Line: s.Line, StartLine: s.StartLine, EndLine: s.EndLine, Signature: s.Signature}
+	}
+	return out
+}
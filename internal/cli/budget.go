@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/respawn-app/ksrc/internal/budget"
+	"github.com/spf13/cobra"
+)
+
+// ownsBudget marks commands that degrade on their own under --budget
+// (search, cat, batch). Output of the others is cut after the last line that fits.
+const ownsBudget = "ksrc/budget"
+
+// limitOutput applies --budget to commands that do not handle it themselves
+// and returns a function that reports what was cut.
+func limitOutput(cmd *cobra.Command, b budget.Budget) func() error {
+	if !b.Enabled() || cmd.Annotations[ownsBudget] != "" {
+		return func() error { return nil }
+	}
+	out := cmd.OutOrStdout()
+	w := budget.NewWriter(out, b)
+	cmd.SetOut(w)
+	return func() error {
+		if err := w.Close(); err != nil {
+			return err
+		}
+		if w.Truncated() {
+			writeTruncated(out, b, fmt.Sprintf("omitted %d lines (%d bytes)", w.OmittedLines, w.OmittedBytes))
+			fmt.Fprintln(out, "Try: narrow the command (a module, --glob, --find, --max-results) or raise --budget")
+		}
+		return nil
+	}
+}
+
+func writeTruncated(w io.Writer, b budget.Budget, what string) {
+	fmt.Fprintf(w, "truncated: --budget %s reached; %s\n", b, what)
+}
@@ -1,79 +1,86 @@
 package cli
 
 import (
-	"context"
+	"bytes"
 	"fmt"
+	"io"
 	"strings"
 
+	"github.com/respawn-app/ksrc/internal/budget"
 	"github.com/respawn-app/ksrc/internal/cat"
 	"github.com/respawn-app/ksrc/internal/resolve"
+	"github.com/respawn-app/ksrc/internal/srcjar"
 	"github.com/spf13/cobra"
 )
 
 func newCatCmd(app *App) *cobra.Command {
 	var flags ResolveFlags
-	var lines string
+	var lines []string
+	var around int
+	var fromSearch bool
+	var context int
+	var stripValue string
+	var numbers bool
 
 	cmd := &cobra.Command{
-		Use:   "cat <file-id|path>",
+		Use:   "cat <file-id|path>...",
 		Short: "Print file contents from dependency sources",
-		Args:  cobra.ExactArgs(1),
+		Long: "Prints one or more files. A file may carry a line range (<file-id>:120-180 or <file-id>#L120-L180).\n" +
+			"Ranges: --lines 120,180 | 120,+40 | 120: | -50 (repeatable), or --around 240 --context 30.\n" +
+			"With --from-search, reads `ksrc search` output from stdin and prints the lines around each hit.\n" +
+			"Several files or ranges are separated by ==> <file-id> <== headers.",
+		Annotations: map[string]string{ownsBudget: "outline"},
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && !fromSearch {
+				return fmt.Errorf("requires at least 1 arg(s), only received 0. Try: ksrc cat <file-id> or ksrc search ... | ksrc cat --from-search")
+			}
+			return nil
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			arg := strings.TrimSpace(args[0])
-			lr, err := cat.ParseLineRange(lines)
+			strip, err := cat.ParseStrip(stripValue)
 			if err != nil {
 				return err
 			}
-
-			if strings.Contains(arg, "!/") {
-				coord, inner, err := resolve.ParseFileID(arg)
+			targets, err := catTargets(args, lines, around, context)
+			if err != nil {
+				return err
+			}
+			if fromSearch {
+				hits, err := cat.FromSearch(cmd.InOrStdin(), context)
 				if err != nil {
 					return err
 				}
-				flags.Module = coord.String()
-				flags.Version = coord.Version
+				if len(hits) == 0 && len(targets) == 0 {
+					return fmt.Errorf("no search hits on stdin. Try: ksrc search <module> -q \"<pattern>\" | ksrc cat --from-search")
+				}
+				targets = append(targets, hits...)
+			}
 
-				sources, _, _, err := resolveSources(context.Background(), app, flags, "", true, false)
+			sources, err := catSources(cmd, app, flags, targets)
+			if err != nil {
+				return err
+			}
+			archives := srcjar.NewArchives()
+			defer archives.Close()
+			p := &catPrinter{out: cmd.OutOrStdout(), headers: len(targets) > 1 || fromSearch, budget: app.Budget, strip: strip, numbers: numbers}
+			for _, t := range targets {
+				if p.cutID != "" {
+					p.skipped = append(p.skipped, t.String())
+					continue
+				}
+				entry, err := catEntry(archives, sources, flags, t.File)
 				if err != nil {
 					return err
 				}
-				if len(sources) == 0 {
-					return noSourcesErr(flags, noSourcesHintForCoord(coord))
-				}
-				jarPath, err := findJarByCoord(sources, coord)
+				data, err := entry.Read()
 				if err != nil {
 					return err
 				}
-				data, err := cat.ReadFileFromZip(jarPath, inner, lr)
-				if err != nil {
+				if err := p.print(entry, data, t.Range); err != nil {
 					return err
 				}
-				_, err = cmd.OutOrStdout().Write(data)
-				return err
-			}
-
-			if flags.Module == "" && flags.Group == "" && flags.Artifact == "" {
-				return fmt.Errorf("path requires --module or a file-id. Try: ksrc cat <file-id> or ksrc cat --module group:artifact[:version] <path>")
-			}
-
-			sources, _, meta, err := resolveSources(context.Background(), app, flags, "", true, true)
-			if err != nil {
-				return err
 			}
-			emitWarnings(cmd, meta)
-			if len(sources) == 0 {
-				return noSourcesErr(flags, noSourcesHintForFlags(flags, meta))
-			}
-			jarPath, inner, err := findFileInJars(sources, arg)
-			if err != nil {
-				return err
-			}
-			data, err := cat.ReadFileFromZip(jarPath, inner, lr)
-			if err != nil {
-				return err
-			}
-			_, err = cmd.OutOrStdout().Write(data)
-			return err
+			return p.report()
 		},
 	}
 
@@ -91,11 +98,255 @@ func newCatCmd(app *App) *cobra.Command {
 	cmd.Flags().BoolVar(&flags.IncludeBuildSrc, "buildsrc", true, "include buildSrc dependencies (set --buildsrc=false to disable)")
 	cmd.Flags().BoolVar(&flags.IncludeBuildscript, "buildscript", true, "include buildscript classpath dependencies (set --buildscript=false to disable)")
 	cmd.Flags().BoolVar(&flags.IncludeIncludedBuilds, "include-builds", true, "include composite builds (includeBuild) (set --include-builds=false to disable)")
-	cmd.Flags().StringVar(&lines, "lines", "", "line range (start,end)")
+	cmd.Flags().StringArrayVar(&lines, "lines", nil, "line range for files without an inline range: start,end | start,+count | start: | -count (repeatable)")
+	cmd.Flags().IntVar(&around, "around", 0, "print the lines around this line (see --context)")
+	cmd.Flags().BoolVar(&fromSearch, "from-search", false, "read ksrc search output from stdin and print the lines around each hit")
+	cmd.Flags().IntVarP(&context, "context", "C", 5, "lines around --around or each hit with --from-search")
+	cmd.Flags().StringVar(&stripValue, "strip", "", "leave out noise: license,imports,comments,blank (comma-separated)")
+	cmd.Flags().BoolVarP(&numbers, "line-numbers", "n", false, "prefix lines with their line numbers in the original file")
 
 	return cmd
 }
 
+// catTargets pairs each file argument with its inline range, or else with
+// every range given by --lines and --around.
+func catTargets(args, lines []string, around, context int) ([]cat.Target, error) {
+	ranges, err := cat.ParseLineRanges(lines)
+	if err != nil {
+		return nil, err
+	}
+	if around != 0 {
+		lr, err := cat.Around(around, context)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, lr)
+	}
+	var targets []cat.Target
+	for _, arg := range args {
+		t, err := cat.ParseTarget(arg)
+		if err != nil {
+			return nil, err
+		}
+		if t.Range != nil || len(ranges) == 0 {
+			targets = append(targets, t)
+			continue
+		}
+		for _, lr := range ranges {
+			targets = append(targets, cat.Target{File: t.File, Range: &lr})
+		}
+	}
+	return targets, nil
+}
+
+// catPrinter prints files and ranges, with headers when there are several.
+// Under --budget, the first file that does not fit is replaced by its outline
+// and as many of the requested lines as fit; the files after it are skipped.
+type catPrinter struct {
+	out     io.Writer
+	headers bool
+	budget  budget.Budget
+	strip   cat.Strip
+	numbers bool
+	used    int
+	printed int
+	cutID   string
+	cut     string
+	skipped []string
+}
+
+func (p *catPrinter) print(entry srcjar.Entry, full []byte, lr *cat.LineRange) error {
+	if lr != nil {
+		total := cat.CountLines(full)
+		r := lr.Resolve(total)
+		if r.Start > total {
+			return fmt.Errorf("%s starts past the end of the file (%d lines). Try: ksrc cat %s --lines -20", cat.Target{File: entry.FileID(), Range: lr}, total, entry.FileID())
+		}
+		lr = &r
+	}
+	var lines []cat.Line
+	var data []byte
+	if p.strip.Enabled() || p.numbers {
+		lines = cat.InRange(cat.StripLines(full, entry.Name(), p.strip), lr)
+		data = cat.Format(lines, p.numbers)
+	} else {
+		var err error
+		if data, err = cat.Slice(full, lr); err != nil {
+			return err
+		}
+	}
+	var buf bytes.Buffer
+	if p.headers {
+		if p.printed > 0 {
+			buf.WriteByte('\n')
+		}
+		header := cat.Target{File: entry.FileID(), Range: lr}
+		// Report the lines actually printed when the range runs past the end.
+		switch {
+		case lr == nil:
+		case len(lines) > 0:
+			header.Range = &cat.LineRange{Start: lines[0].N, End: lines[len(lines)-1].N}
+		case !p.strip.Enabled() && !p.numbers:
+			header.Range = &cat.LineRange{Start: lr.Start, End: lr.Start + cat.CountLines(data) - 1}
+		}
+		fmt.Fprintf(&buf, "==> %s <==\n", header)
+	}
+	buf.Write(data)
+	if p.headers && len(data) > 0 && data[len(data)-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+	if p.budget.Enabled() && buf.Len() > p.budget.Bytes()-p.used {
+		if lines == nil {
+			lines = cat.InRange(cat.Lines(full), lr)
+		}
+		return p.outline(entry, full, lines)
+	}
+	return p.write(buf.Bytes())
+}
+
+// outline prints the outline of a file that is over budget, using at most half
+// of what is left, then the first requested lines that still fit.
+func (p *catPrinter) outline(entry srcjar.Entry, full []byte, lines []cat.Line) error {
+	left := p.budget.Bytes() - p.used
+	var buf bytes.Buffer
+	if p.printed > 0 {
+		buf.WriteByte('\n')
+	}
+	fmt.Fprintf(&buf, "==> %s (outline) <==\n", entry.FileID())
+	outline := cat.Outline(full, entry.Name())
+	shown := cat.Head(outline, left/2-buf.Len())
+	buf.Write(shown)
+
+	var region []byte
+	n := 0
+	if len(lines) > 0 {
+		header := fmt.Sprintf("\n==> %s:%d-%d <==\n", entry.FileID(), lines[0].N, lines[len(lines)-1].N)
+		room := left - buf.Len() - len(header)
+		for n < len(lines) {
+			next := cat.Format(lines[n:n+1], p.numbers)
+			if len(region)+len(next) > room {
+				break
+			}
+			region = append(region, next...)
+			n++
+		}
+	}
+	var printed string
+	if n > 0 {
+		printed = fmt.Sprintf("%d-%d", lines[0].N, lines[n-1].N)
+		fmt.Fprintf(&buf, "\n==> %s:%s <==\n", entry.FileID(), printed)
+		buf.Write(region)
+	}
+
+	p.cutID = entry.FileID()
+	p.cut = fmt.Sprintf("%s has %d lines (%d bytes): printed its outline", p.cutID, cat.CountLines(full), len(full))
+	if len(shown) < len(outline) {
+		p.cut += " (cut short)"
+	}
+	if n > 0 {
+		p.cut += " and lines " + printed
+	}
+	return p.write(cat.Head(buf.Bytes(), left))
+}
+
+func (p *catPrinter) write(data []byte) error {
+	p.used += len(data)
+	p.printed++
+	_, err := p.out.Write(data)
+	return err
+}
+
+// report tells the reader what --budget left out.
+func (p *catPrinter) report() error {
+	if p.cutID == "" {
+		return nil
+	}
+	writeTruncated(p.out, p.budget, p.cut)
+	for _, t := range p.skipped {
+		fmt.Fprintf(p.out, "omitted: %s\n", t)
+	}
+	_, err := fmt.Fprintf(p.out, "Try: ksrc cat %s:<start>-<end> with lines from the outline, or raise --budget\n", p.cutID)
+	return err
+}
+
+// catSources resolves the project once for all targets. A single coordinate
+// is resolved alone as before; several coordinates, or file-ids mixed with
+// paths, resolve all dependencies and paths are matched with the module
+// filters afterwards.
+func catSources(cmd *cobra.Command, app *App, flags ResolveFlags, targets []cat.Target) ([]resolve.SourceJar, error) {
+	coords := make(map[resolve.Coord]bool)
+	var coord resolve.Coord
+	hasPaths := false
+	for _, t := range targets {
+		if !strings.Contains(t.File, "!/") {
+			hasPaths = true
+			continue
+		}
+		c, _, err := resolve.ParseFileID(t.File)
+		if err != nil {
+			return nil, err
+		}
+		coords[c], coord = true, c
+	}
+	if hasPaths && flags.Module == "" && flags.Group == "" && flags.Artifact == "" {
+		return nil, fmt.Errorf("path requires --module or a file-id. Try: ksrc %[1]s <file-id> or ksrc %[1]s --module group:artifact[:version] <path>", cmd.Name())
+	}
+
+	switch {
+	case len(coords) == 1 && !hasPaths:
+		flags.Module = coord.String()
+		flags.Version = coord.Version
+		sources, _, _, err := resolveSources(cmd.Context(), app, flags, "", true, false)
+		if err != nil {
+			return nil, err
+		}
+		if len(sources) == 0 {
+			return nil, noSourcesErr(flags, noSourcesHintForCoord(coord))
+		}
+		return sources, nil
+	case len(coords) > 0:
+		all := flags
+		all.All = true
+		all.Module, all.Group, all.Artifact, all.Version = "", "", "", ""
+		sources, _, meta, err := resolveSources(cmd.Context(), app, all, "", true, false)
+		if err != nil {
+			return nil, err
+		}
+		emitWarnings(cmd, meta)
+		if len(sources) == 0 {
+			return nil, noSourcesErr(all, noSourcesHintForFlags(all, meta))
+		}
+		return sources, nil
+	}
+	sources, _, meta, err := resolveSources(cmd.Context(), app, flags, "", true, true)
+	if err != nil {
+		return nil, err
+	}
+	emitWarnings(cmd, meta)
+	if len(sources) == 0 {
+		return nil, noSourcesErr(flags, noSourcesHintForFlags(flags, meta))
+	}
+	return sources, nil
+}
+
+// catEntry finds a file-id in its jar, or a path in the jars selected by the
+// module filters.
+func catEntry(archives *srcjar.Archives, sources []resolve.SourceJar, flags ResolveFlags, file string) (srcjar.Entry, error) {
+	if !strings.Contains(file, "!/") {
+		jars := resolve.FilterSources(sources, flags.Module, flags.Group, flags.Artifact, flags.Version)
+		return findEntry(archives, jars, file)
+	}
+	coord, inner, err := resolve.ParseFileID(file)
+	if err != nil {
+		return srcjar.Entry{}, err
+	}
+	jarPath, err := findJarByCoord(sources, coord)
+	if err != nil {
+		return srcjar.Entry{}, err
+	}
+	return srcjar.Find(archives, resolve.SourceJar{Coord: coord, Path: jarPath}, inner)
+}
+
 func findJarByCoord(sources []resolve.SourceJar, coord resolve.Coord) (string, error) {
 	for _, s := range sources {
 		if s.Coord.Group == coord.Group && s.Coord.Artifact == coord.Artifact && s.Coord.Version == coord.Version {
@@ -115,3 +366,14 @@ func findFileInJars(sources []resolve.SourceJar, inner string) (string, string,
 	}
 	return "", "", fmt.Errorf("file not found in resolved sources: %s. Try: ksrc search --module group:artifact -q \"<pattern>\" to get a file-id", inner)
 }
+
+// findEntry returns the first jar entry at inner.
+func findEntry(archives *srcjar.Archives, jars []resolve.SourceJar, inner string) (srcjar.Entry, error) {
+	inner = strings.TrimPrefix(inner, "/")
+	for _, jar := range jars {
+		if entry, err := srcjar.Find(archives, jar, inner); err == nil {
+			return entry, nil
+		}
+	}
+	return srcjar.Entry{}, fmt.Errorf("file not found in resolved sources: %s. Try: ksrc search --module group:artifact -q \"<pattern>\" to get a file-id", inner)
+}
@@ -1,7 +1,6 @@
 package cli
 
 import (
-	"context"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -14,7 +13,7 @@ func newDepsCmd(app *App) *cobra.Command {
 		Use:   "deps",
 		Short: "List resolved dependencies and source availability",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			sources, deps, meta, err := resolveSources(context.Background(), app, flags, "", false, false)
+			sources, deps, meta, err := resolveSources(cmd.Context(), app, flags, "", false, false)
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,198 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/respawn-app/ksrc/internal/kotlin"
+	"github.com/respawn-app/ksrc/internal/resolve"
+	"github.com/respawn-app/ksrc/internal/srcjar"
+	"github.com/respawn-app/ksrc/internal/symbols"
+	"github.com/spf13/cobra"
+)
+
+func newDocCmd(app *App) *cobra.Command {
+	var flags ResolveFlags
+
+	cmd := &cobra.Command{
+		Use:   "doc <symbol>",
+		Short: "Print KDoc/Javadoc and signature for a declaration",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			symbol := strings.TrimSpace(args[0])
+			if symbol == "" {
+				return fmt.Errorf("symbol is required. Try: ksrc doc kotlinx.coroutines.flow.Flow.collect")
+			}
+			if flags.Module == "" && flags.Group == "" && flags.Artifact == "" {
+				flags.All = true
+			}
+			sources, _, meta, err := resolveSources(cmd.Context(), app, flags, "", true, true)
+			if err != nil {
+				return err
+			}
+			emitWarnings(cmd, meta)
+			if len(sources) == 0 {
+				return noSourcesErr(flags, noSourcesHintForFlags(flags, meta))
+			}
+
+			archives := srcjar.NewArchives()
+			defer archives.Close()
+			found, err := symbols.Find(archives, sources, symbol)
+			if err != nil {
+				return err
+			}
+			if len(found) == 0 {
+				return fmt.Errorf("declaration not found: %s. Try: ksrc search --all -q \"%s\"", symbol, kotlin.SimpleName(symbol))
+			}
+			out := cmd.OutOrStdout()
+			for i, s := range found {
+				if i > 0 {
+					fmt.Fprintln(out)
+				}
+				if err := writeDoc(out, archives, sources, s); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Project, "project", ".", "project root")
+	cmd.Flags().StringVar(&flags.Module, "module", "", "module selector (group:artifact[:version])")
+	cmd.Flags().StringVar(&flags.Group, "group", "", "group filter")
+	cmd.Flags().StringVar(&flags.Artifact, "artifact", "", "artifact filter")
+	cmd.Flags().StringVar(&flags.Version, "version", "", "version filter")
+	cmd.Flags().StringVar(&flags.Scope, "scope", "compile", "dependency scope (compile|runtime|test|all)")
+	cmd.Flags().StringVar(&flags.Config, "config", "", "configuration name(s) or glob patterns (comma-separated)")
+	cmd.Flags().StringVar(&flags.Targets, "targets", "", "KMP targets (comma-separated)")
+	cmd.Flags().StringSliceVar(&flags.Subprojects, "subproject", nil, "limit to subproject (repeatable)")
+	cmd.Flags().BoolVar(&flags.Offline, "offline", false, "offline mode")
+	cmd.Flags().BoolVar(&flags.Refresh, "refresh", false, "refresh dependencies")
+	cmd.Flags().BoolVar(&flags.IncludeBuildSrc, "buildsrc", true, "include buildSrc dependencies (set --buildsrc=false to disable)")
+	cmd.Flags().BoolVar(&flags.IncludeBuildscript, "buildscript", true, "include buildscript classpath dependencies (set --buildscript=false to disable)")
+	cmd.Flags().BoolVar(&flags.IncludeIncludedBuilds, "include-builds", true, "include composite builds (includeBuild) (set --include-builds=false to disable)")
+
+	return cmd
+}
+
+var docTagLabels = map[string]string{
+	"return":      "Returns",
+	"receiver":    "Receiver",
+	"constructor": "Constructor",
+	"since":       "Since",
+	"author":      "Author",
+	"suppress":    "Suppressed",
+	"deprecated":  "Deprecated",
+}
+
+func writeDoc(out io.Writer, archives *srcjar.Archives, sources []resolve.SourceJar, s symbols.Symbol) error {
+	fmt.Fprintf(out, "==> %s:%d <==\n", s.FileID, s.Line)
+	fmt.Fprintf(out, "%s\n", s.Signature)
+	if s.Decl.Doc == "" {
+		fmt.Fprintf(out, "\n(no documentation)\n")
+		return nil
+	}
+	doc := kotlin.ParseDoc(s.Decl.Doc)
+	if doc.Body != "" {
+		fmt.Fprintf(out, "\n%s\n", doc.Body)
+	}
+
+	sections := []struct {
+		title string
+		names []string
+	}{
+		{"Parameters", []string{"param", "property"}},
+		{"Throws", []string{"throws", "exception"}},
+	}
+	for _, sec := range sections {
+		var lines []string
+		for _, tag := range doc.Tags {
+			for _, name := range sec.names {
+				if tag.Name == name {
+					lines = append(lines, fmt.Sprintf("  %s: %s", tag.Subject, indentContinuation(tag.Text, "    ")))
+				}
+			}
+		}
+		if len(lines) > 0 {
+			fmt.Fprintf(out, "\n%s:\n%s\n", sec.title, strings.Join(lines, "\n"))
+		}
+	}
+	var see []string
+	for _, tag := range doc.Tags {
+		if label, ok := docTagLabels[tag.Name]; ok {
+			fmt.Fprintf(out, "\n%s: %s\n", label, indentContinuation(tag.Text, "  "))
+		}
+		if tag.Name == "see" {
+			see = append(see, strings.TrimSpace(tag.Subject+" "+tag.Text))
+		}
+	}
+	if len(see) > 0 {
+		fmt.Fprintf(out, "\nSee also: %s\n", strings.Join(see, ", "))
+	}
+
+	for _, tag := range doc.Tags {
+		if tag.Name != "sample" || tag.Subject == "" {
+			continue
+		}
+		fmt.Fprintf(out, "\nSample %s:\n", tag.Subject)
+		lines, err := sampleSource(archives, sources, s, tag.Subject)
+		if err != nil {
+			return err
+		}
+		if len(lines) == 0 {
+			fmt.Fprintf(out, "  (sample not found in sources)\n")
+			continue
+		}
+		for _, line := range lines {
+			fmt.Fprintf(out, "  %s\n", line)
+		}
+	}
+	return nil
+}
+
+// sampleSource returns the dedented source of a @sample target, preferring the
+// jar that declares the documented symbol.
+func sampleSource(archives *srcjar.Archives, sources []resolve.SourceJar, owner symbols.Symbol, name string) ([]string, error) {
+	found, err := symbols.Find(archives, []resolve.SourceJar{owner.Jar}, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		found, err = symbols.Find(archives, sources, name)
+		if err != nil || len(found) == 0 {
+			return nil, err
+		}
+	}
+	sample := found[0]
+	lines, err := sample.Lines(archives, sample.StartLine, sample.EndLine)
+	if err != nil {
+		return nil, err
+	}
+	return dedent(lines), nil
+}
+
+func dedent(lines []string) []string {
+	prefix := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if prefix < 0 || n < prefix {
+			prefix = n
+		}
+	}
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		if len(line) >= prefix && prefix > 0 {
+			line = line[prefix:]
+		}
+		out[i] = strings.TrimRight(line, " \t\r")
+	}
+	return out
+}
+
+func indentContinuation(text, indent string) string {
+	return strings.ReplaceAll(text, "\n", "\n"+indent)
+}
@@ -1,7 +1,6 @@
 package cli
 
 import (
-	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -43,7 +42,7 @@ func newDoctorCmd(app *App) *cobra.Command {
 				fmt.Fprintf(cmd.OutOrStdout(), "gradle cache: %s\n", cache)
 			}
 
-			_, err = gradle.Resolve(context.Background(), app.Runner, gradle.ResolveOptions{ProjectDir: project})
+			_, err = gradle.Resolve(cmd.Context(), app.Runner, gradle.ResolveOptions{ProjectDir: project})
 			if err != nil {
 				fmt.Fprintf(cmd.OutOrStdout(), "gradle resolve: error: %v\n", err)
 			} else {
@@ -1,7 +1,6 @@
 package cli
 
 import (
-	"context"
 	"fmt"
 
 	"github.com/respawn-app/ksrc/internal/resolve"
@@ -26,7 +25,7 @@ func newFetchCmd(app *App) *cobra.Command {
 			flags.Module = coord.String()
 			flags.Version = coord.Version
 
-			sources, _, meta, err := resolveSources(context.Background(), app, flags, coord.String(), false, false)
+			sources, _, meta, err := resolveSources(cmd.Context(), app, flags, coord.String(), false, false)
 			if err != nil {
 				return err
 			}
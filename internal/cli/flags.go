@@ -22,6 +22,9 @@ type ResolveFlags struct {
 	IncludeBuildSrc       bool
 	IncludeBuildscript    bool
 	IncludeIncludedBuilds bool
+	// WithDeps widens the module selection to its dependencies, up to this
+	// depth; negative is unlimited, 0 is off.
+	WithDeps int
 }
 
 func (f ResolveFlags) ToOptions() gradle.ResolveOptions {
@@ -41,6 +44,7 @@ func (f ResolveFlags) ToOptions() gradle.ResolveOptions {
 		IncludeBuildSrc:       f.IncludeBuildSrc,
 		IncludeBuildscript:    f.IncludeBuildscript,
 		IncludeIncludedBuilds: f.IncludeIncludedBuilds,
+		WithDeps:              f.WithDeps,
 	}
 }
 
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/respawn-app/ksrc/internal/resolve"
+	"github.com/respawn-app/ksrc/internal/srcjar"
+	"github.com/respawn-app/ksrc/internal/symbols"
+	"github.com/spf13/cobra"
+)
+
+func newGotoCmd(app *App) *cobra.Command {
+	var flags ResolveFlags
+
+	cmd := &cobra.Command{
+		Use:   "goto <file-id>:<line>:<col>",
+		Short: "Go to the definition of the identifier at a position in a dependency file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			coord, inner, line, col, err := resolve.ParseFilePosition(strings.TrimSpace(args[0]))
+			if err != nil {
+				return err
+			}
+			// Only the file's module and what it depends on can be referenced from it.
+			flags.Module = coord.String()
+			flags.WithDeps = -1
+			sources, _, meta, err := resolveSources(cmd.Context(), app, flags, "", true, false)
+			if err != nil {
+				return err
+			}
+			emitWarnings(cmd, meta)
+			if len(sources) == 0 {
+				return noSourcesErr(flags, noSourcesHintForCoord(coord))
+			}
+			jarPath, err := findJarByCoord(sources, coord)
+			if err != nil {
+				return err
+			}
+
+			archives := srcjar.NewArchives()
+			defer archives.Close()
+			entry, err := srcjar.Find(archives, resolve.SourceJar{Coord: coord, Path: jarPath}, inner)
+			if err != nil {
+				return err
+			}
+			name, defs, err := symbols.Definitions(archives, sources, entry, line, col)
+			if err != nil {
+				return err
+			}
+			if len(defs) == 0 {
+				return fmt.Errorf("definition not found for %q. Try: ksrc search --all -q \"(class|interface|object|fun|val|var|typealias) %s\\b\"", name, name)
+			}
+			for _, d := range defs {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s %d-%d:%s\n", d.FileID, d.StartLine, d.EndLine, d.Signature)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Project, "project", ".", "project root")
+	cmd.Flags().StringVar(&flags.Scope, "scope", "compile", "dependency scope (compile|runtime|test|all)")
+	cmd.Flags().StringVar(&flags.Config, "config", "", "configuration name(s) or glob patterns (comma-separated)")
+	cmd.Flags().StringVar(&flags.Targets, "targets", "", "KMP targets (comma-separated)")
+	cmd.Flags().StringSliceVar(&flags.Subprojects, "subproject", nil, "limit to subproject (repeatable)")
+	cmd.Flags().BoolVar(&flags.Offline, "offline", false, "offline mode")
+	cmd.Flags().BoolVar(&flags.Refresh, "refresh", false, "refresh dependencies")
+	cmd.Flags().BoolVar(&flags.IncludeBuildSrc, "buildsrc", true, "include buildSrc dependencies (set --buildsrc=false to disable)")
+	cmd.Flags().BoolVar(&flags.IncludeBuildscript, "buildscript", true, "include buildscript classpath dependencies (set --buildscript=false to disable)")
+	cmd.Flags().BoolVar(&flags.IncludeIncludedBuilds, "include-builds", true, "include composite builds (includeBuild) (set --include-builds=false to disable)")
+
+	return cmd
+}
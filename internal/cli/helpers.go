@@ -15,6 +15,10 @@ type ResolveMeta struct {
 	Attempts            []string
 	TriedConfigPatterns []string
 	Warnings            []string
+	// Direct lists dependencies declared by the project (not transitive).
+	Direct []resolve.Coord
+	// Edges is the dependency graph reported by the resolution attempts.
+	Edges []resolve.Edge
 }
 
 func resolveSources(ctx context.Context, app *App, flags ResolveFlags, dep string, applyFilters bool, allowCacheFallback bool) ([]resolve.SourceJar, []resolve.Coord, ResolveMeta, error) {
@@ -31,6 +35,7 @@ func resolveSources(ctx context.Context, app *App, flags ResolveFlags, dep strin
 	var mergedDeps []resolve.Coord
 	seenSources := make(map[string]struct{})
 	seenDeps := make(map[string]struct{})
+	seenDirect := make(map[string]struct{})
 	for _, attempt := range attempts {
 		res, err := gradle.Resolve(ctx, app.Runner, attempt.Options)
 		if err != nil {
@@ -39,10 +44,12 @@ func resolveSources(ctx context.Context, app *App, flags ResolveFlags, dep strin
 		meta.Attempts = append(meta.Attempts, attempt.Label)
 		meta.TriedConfigPatterns = append(meta.TriedConfigPatterns, attempt.ConfigPatterns...)
 		meta.Warnings = append(meta.Warnings, res.Warnings...)
+		mergeDeps(&meta.Direct, seenDirect, res.Direct)
+		meta.Edges = append(meta.Edges, res.Edges...)
 		lastDeps = res.Deps
 		sources := res.Sources
 		if applyFilters {
-			sources = resolve.FilterSources(sources, flags.Module, flags.Group, flags.Artifact, flags.Version)
+			sources = filterSources(sources, flags, res)
 		}
 		if flags.All {
 			mergeSources(&mergedSources, seenSources, sources)
@@ -71,6 +78,33 @@ func resolveSources(ctx context.Context, app *App, flags ResolveFlags, dep strin
 	return sources, lastDeps, meta, nil
 }
 
+// filterSources applies the module filters. With --with-deps the selection is
+// widened to the dependency closure of the matching modules.
+func filterSources(sources []resolve.SourceJar, flags ResolveFlags, res gradle.ResolveResult) []resolve.SourceJar {
+	if flags.WithDeps == 0 || flags.All {
+		return resolve.FilterSources(sources, flags.Module, flags.Group, flags.Artifact, flags.Version)
+	}
+	var roots []resolve.Coord
+	for _, c := range res.Deps {
+		if resolve.MatchCoord(c, flags.Module, flags.Group, flags.Artifact, flags.Version) {
+			roots = append(roots, c)
+		}
+	}
+	for _, s := range sources {
+		if resolve.MatchCoord(s.Coord, flags.Module, flags.Group, flags.Artifact, flags.Version) {
+			roots = append(roots, s.Coord)
+		}
+	}
+	closure := resolve.Closure(roots, res.Edges, flags.WithDeps)
+	out := make([]resolve.SourceJar, 0, len(sources))
+	for _, s := range sources {
+		if closure[s.Coord.String()] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
 func requireModuleOrAll(module string, all bool) error {
 	if strings.TrimSpace(module) == "" && !all {
 		return fmt.Errorf("E_NO_MODULE: <module> required unless --all is provided. Try: ksrc search --all -q \"<pattern>\" or ksrc search group:artifact -q \"<pattern>\"")
@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/respawn-app/ksrc/internal/kotlin"
+	"github.com/respawn-app/ksrc/internal/symbols"
+	"github.com/spf13/cobra"
+)
+
+func newImportsCmd(app *App) *cobra.Command {
+	var flags ResolveFlags
+
+	cmd := &cobra.Command{
+		Use:   "imports <path/to/File.kt>",
+		Short: "Resolve a project file's imports to dependency file-ids",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := strings.TrimSpace(args[0])
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			file := kotlin.Parse(data, kotlin.LangForPath(path))
+			if len(file.Imports) == 0 {
+				return nil
+			}
+
+			if !cmd.Flags().Changed("project") {
+				if root := findProjectRoot(filepath.Dir(path)); root != "" {
+					flags.Project = root
+				}
+			}
+			if len(flags.Subprojects) == 0 {
+				if sub := owningSubproject(flags.Project, path); sub != "" {
+					flags.Subprojects = []string{sub}
+				}
+			}
+			flags.All = true
+
+			sources, _, meta, err := resolveSources(cmd.Context(), app, flags, "", true, true)
+			if err != nil {
+				return err
+			}
+			emitWarnings(cmd, meta)
+			if len(sources) == 0 {
+				return noSourcesErr(flags, noSourcesHintForFlags(flags, meta))
+			}
+			results, err := symbols.ResolveImports(nil, sources, file.Imports)
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			var unresolved []string
+			for _, r := range results {
+				name := importLabel(r.Import)
+				if !r.Resolved() {
+					unresolved = append(unresolved, name)
+					continue
+				}
+				for _, coord := range r.Modules {
+					fmt.Fprintf(out, "%s %s\n", name, coord.String())
+				}
+				for _, s := range r.Symbols {
+					fmt.Fprintf(out, "%s %s %d:%s\n", name, s.FileID, s.Line, s.Signature)
+				}
+			}
+			if len(unresolved) > 0 {
+				fmt.Fprintf(out, "\nunresolved (project-internal or binary-only):\n")
+				for _, name := range unresolved {
+					fmt.Fprintf(out, "%s\n", name)
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Project, "project", ".", "project root (default: nearest directory with settings.gradle[.kts])")
+	cmd.Flags().StringVar(&flags.Scope, "scope", "compile", "dependency scope (compile|runtime|test|all)")
+	cmd.Flags().StringVar(&flags.Config, "config", "", "configuration name(s) or glob patterns (comma-separated)")
+	cmd.Flags().StringVar(&flags.Targets, "targets", "", "KMP targets (comma-separated)")
+	cmd.Flags().StringSliceVar(&flags.Subprojects, "subproject", nil, "limit to subproject (repeatable; default: the file's owning subproject)")
+	cmd.Flags().BoolVar(&flags.Offline, "offline", false, "offline mode")
+	cmd.Flags().BoolVar(&flags.Refresh, "refresh", false, "refresh dependencies")
+	cmd.Flags().BoolVar(&flags.IncludeBuildSrc, "buildsrc", true, "include buildSrc dependencies (set --buildsrc=false to disable)")
+	cmd.Flags().BoolVar(&flags.IncludeBuildscript, "buildscript", true, "include buildscript classpath dependencies (set --buildscript=false to disable)")
+	cmd.Flags().BoolVar(&flags.IncludeIncludedBuilds, "include-builds", true, "include composite builds (includeBuild) (set --include-builds=false to disable)")
+
+	return cmd
+}
+
+func importLabel(imp kotlin.Import) string {
+	switch {
+	case imp.Star:
+		return imp.Path + ".*"
+	case imp.Alias != "":
+		return imp.Path + " as " + imp.Alias
+	default:
+		return imp.Path
+	}
+}
+
+// findProjectRoot returns the nearest ancestor of dir containing a Gradle
+// settings file, or "" if there is none.
+func findProjectRoot(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		for _, name := range []string{"settings.gradle.kts", "settings.gradle"} {
+			if info, err := os.Stat(filepath.Join(abs, name)); err == nil && !info.IsDir() {
+				return abs
+			}
+		}
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return ""
+		}
+		abs = parent
+	}
+}
+
+// owningSubproject maps a file to the Gradle path (e.g. :app:feature) of the
+// nearest enclosing directory with a build script. The root project maps to "".
+func owningSubproject(projectDir string, filePath string) string {
+	root, err := filepath.Abs(projectDir)
+	if err != nil {
+		return ""
+	}
+	dir, err := filepath.Abs(filepath.Dir(filePath))
+	if err != nil {
+		return ""
+	}
+	for {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return ""
+		}
+		for _, name := range []string{"build.gradle.kts", "build.gradle"} {
+			if info, err := os.Stat(filepath.Join(dir, name)); err == nil && !info.IsDir() {
+				if rel == "." {
+					return ""
+				}
+				return ":" + strings.ReplaceAll(filepath.ToSlash(rel), "/", ":")
+			}
+		}
+		if rel == "." {
+			return ""
+		}
+		dir = filepath.Dir(dir)
+	}
+}
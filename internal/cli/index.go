@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/respawn-app/ksrc/internal/index"
+	"github.com/respawn-app/ksrc/internal/resolve"
+	"github.com/spf13/cobra"
+)
+
+func newIndexCmd(app *App) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "index",
+		Short: "Manage trigram indexes that speed up search",
+		Long: "Trigram indexes narrow the files ripgrep searches inside each source jar.\n" +
+			"Building an index turns indexing on: later searches index new or changed jars lazily.\n" +
+			"Clearing removes every index and turns indexing off again.",
+	}
+	cmd.AddCommand(newIndexBuildCmd(app))
+	cmd.AddCommand(newIndexStatusCmd(app))
+	cmd.AddCommand(newIndexClearCmd())
+	return cmd
+}
+
+func newIndexBuildCmd(app *App) *cobra.Command {
+	var flags ResolveFlags
+
+	cmd := &cobra.Command{
+		Use:   "build [<module>]",
+		Short: "Build or refresh the indexes of resolved source jars",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := index.DefaultStore()
+			if err != nil {
+				return err
+			}
+			sources, err := indexSources(cmd, app, &flags, args)
+			if err != nil {
+				return err
+			}
+			for _, s := range sources {
+				ix, state, err := store.Load(s.Path)
+				if err != nil {
+					return err
+				}
+				if state != index.Fresh {
+					if ix, err = store.Build(s.Path); err != nil {
+						return fmt.Errorf("index %s: %w", s.Coord.String(), err)
+					}
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s  [index: %s]  [files: %d]  [size: %d]\n", s.Coord.String(), index.Fresh, len(ix.Files), store.FileSize(s.Path))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Project, "project", ".", "project root")
+	cmd.Flags().BoolVar(&flags.All, "all", false, "index all resolved dependencies")
+	cmd.Flags().StringVar(&flags.Module, "module", "", "module selector (group:artifact[:version])")
+	cmd.Flags().StringVar(&flags.Group, "group", "", "group filter")
+	cmd.Flags().StringVar(&flags.Artifact, "artifact", "", "artifact filter")
+	cmd.Flags().StringVar(&flags.Version, "version", "", "version filter")
+	cmd.Flags().StringVar(&flags.Scope, "scope", "compile", "dependency scope (compile|runtime|test|all)")
+	cmd.Flags().StringVar(&flags.Config, "config", "", "configuration name(s) or glob patterns (comma-separated)")
+	cmd.Flags().StringVar(&flags.Targets, "targets", "", "KMP targets (comma-separated)")
+	cmd.Flags().StringSliceVar(&flags.Subprojects, "subproject", nil, "limit to subproject (repeatable)")
+	cmd.Flags().BoolVar(&flags.Offline, "offline", false, "offline mode")
+	cmd.Flags().BoolVar(&flags.Refresh, "refresh", false, "refresh dependencies")
+	cmd.Flags().BoolVar(&flags.IncludeBuildSrc, "buildsrc", true, "include buildSrc dependencies (set --buildsrc=false to disable)")
+	cmd.Flags().BoolVar(&flags.IncludeBuildscript, "buildscript", true, "include buildscript classpath dependencies (set --buildscript=false to disable)")
+	cmd.Flags().BoolVar(&flags.IncludeIncludedBuilds, "include-builds", true, "include composite builds (includeBuild) (set --include-builds=false to disable)")
+	return cmd
+}
+
+func newIndexStatusCmd(app *App) *cobra.Command {
+	var flags ResolveFlags
+
+	cmd := &cobra.Command{
+		Use:   "status [<module>]",
+		Short: "Show which resolved source jars are indexed",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := index.DefaultStore()
+			if err != nil {
+				return err
+			}
+			sources, err := indexSources(cmd, app, &flags, args)
+			if err != nil {
+				return err
+			}
+			if !store.Enabled() {
+				fmt.Fprintln(cmd.ErrOrStderr(), "warning: indexing is off. Try: ksrc index build")
+			}
+			for _, s := range sources {
+				ix, state, err := store.Load(s.Path)
+				if err != nil {
+					return err
+				}
+				files := "-"
+				if ix != nil {
+					files = fmt.Sprint(len(ix.Files))
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s  [index: %s]  [files: %s]  [size: %d]\n", s.Coord.String(), state, files, store.FileSize(s.Path))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Project, "project", ".", "project root")
+	cmd.Flags().BoolVar(&flags.All, "all", false, "show all resolved dependencies")
+	cmd.Flags().StringVar(&flags.Module, "module", "", "module selector (group:artifact[:version])")
+	cmd.Flags().StringVar(&flags.Group, "group", "", "group filter")
+	cmd.Flags().StringVar(&flags.Artifact, "artifact", "", "artifact filter")
+	cmd.Flags().StringVar(&flags.Version, "version", "", "version filter")
+	cmd.Flags().StringVar(&flags.Scope, "scope", "compile", "dependency scope (compile|runtime|test|all)")
+	cmd.Flags().StringVar(&flags.Config, "config", "", "configuration name(s) or glob patterns (comma-separated)")
+	cmd.Flags().StringVar(&flags.Targets, "targets", "", "KMP targets (comma-separated)")
+	cmd.Flags().StringSliceVar(&flags.Subprojects, "subproject", nil, "limit to subproject (repeatable)")
+	cmd.Flags().BoolVar(&flags.Offline, "offline", false, "offline mode")
+	cmd.Flags().BoolVar(&flags.Refresh, "refresh", false, "refresh dependencies")
+	cmd.Flags().BoolVar(&flags.IncludeBuildSrc, "buildsrc", true, "include buildSrc dependencies (set --buildsrc=false to disable)")
+	cmd.Flags().BoolVar(&flags.IncludeBuildscript, "buildscript", true, "include buildscript classpath dependencies (set --buildscript=false to disable)")
+	cmd.Flags().BoolVar(&flags.IncludeIncludedBuilds, "include-builds", true, "include composite builds (includeBuild) (set --include-builds=false to disable)")
+	return cmd
+}
+
+func newIndexClearCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "clear",
+		Short: "Remove every index and turn indexing off",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := index.DefaultStore()
+			if err != nil {
+				return err
+			}
+			if err := store.Clear(); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "removed %s\n", store.Dir)
+			return nil
+		},
+	}
+}
+
+// indexSources resolves the source jars named by the module argument or --all.
+func indexSources(cmd *cobra.Command, app *App, flags *ResolveFlags, args []string) ([]resolve.SourceJar, error) {
+	if len(args) == 1 {
+		if flags.Module != "" && flags.Module != args[0] {
+			return nil, fmt.Errorf("module specified twice (arg and --module). Use only one.")
+		}
+		flags.Module = args[0]
+	}
+	if flags.Module == "" && !flags.All {
+		return nil, fmt.Errorf("E_NO_MODULE: <module> required unless --all is provided. Try: ksrc index build --all or ksrc index build group:artifact")
+	}
+	sources, _, meta, err := resolveSources(cmd.Context(), app, *flags, "", true, true)
+	if err != nil {
+		return nil, err
+	}
+	emitWarnings(cmd, meta)
+	if len(sources) == 0 {
+		return nil, noSourcesErr(*flags, noSourcesHintForFlags(*flags, meta))
+	}
+	return sources, nil
+}
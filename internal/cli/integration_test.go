@@ -2,10 +2,15 @@ package cli
 
 import (
 	"archive/zip"
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
+
+	"github.com/respawn-app/ksrc/internal/search"
 )
 
 func TestSearchAndCatIntegration(t *testing.T) {
@@ -48,6 +53,338 @@ func TestSearchAndCatIntegration(t *testing.T) {
 	}
 }
 
+func TestCatMultipleAndFromSearch(t *testing.T) {
+	app := NewApp()
+	if _, err := app.Runner.LookPath("rg"); err != nil {
+		t.Skip("rg not available")
+	}
+	projectDir := filepath.Clean(filepath.Join("..", "..", "testdata", "fixture"))
+	dir := t.TempDir()
+	jarPath := filepath.Join(dir, "kotlinx-datetime-sources.jar")
+	var body strings.Builder
+	for i := 1; i <= 30; i++ {
+		if i == 10 || i == 12 || i == 25 {
+			fmt.Fprintf(&body, "val needle%d = %d\n", i, i)
+			continue
+		}
+		fmt.Fprintf(&body, "line%d\n", i)
+	}
+	if err := writeTestJarFiles(jarPath, map[string]string{
+		"kotlinx/datetime/A.kt": body.String(),
+		"kotlinx/datetime/B.kt": "b1\nb2\nb3\n",
+		"kotlinx/datetime/C.kt": "c1\nc2\nc3",
+	}); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	jvmJarPath := filepath.Join(dir, "kotlinx-datetime-jvm-sources.jar")
+	if err := writeTestJarFiles(jvmJarPath, map[string]string{"kotlinx/datetime/Jvm.kt": "jvm1\njvm2\n"}); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	t.Setenv("KSRC_TEST_JAR", jarPath)
+	t.Setenv("KSRC_TEST_JVM_JAR", jvmJarPath)
+
+	a := "org.jetbrains.kotlinx:kotlinx-datetime:0.6.1!/kotlinx/datetime/A.kt"
+	b := "org.jetbrains.kotlinx:kotlinx-datetime:0.6.1!/kotlinx/datetime/B.kt"
+	jvm := "org.jetbrains.kotlinx:kotlinx-datetime-jvm:0.6.1!/kotlinx/datetime/Jvm.kt"
+
+	out, err := runCommand(app, []string{"cat", a + ":2-3", b + "#L2-L9", jvm, "--project", projectDir})
+	if err != nil {
+		t.Fatalf("cat error: %v", err)
+	}
+	want := "==> " + a + ":2-3 <==\nline2\nline3\n\n" +
+		"==> " + b + ":2-3 <==\nb2\nb3\n\n" +
+		"==> " + jvm + " <==\njvm1\njvm2\n"
+	if out != want {
+		t.Fatalf("unexpected cat output:\n%s", out)
+	}
+
+	c := "org.jetbrains.kotlinx:kotlinx-datetime:0.6.1!/kotlinx/datetime/C.kt"
+	out, err = runCommand(app, []string{"cat", b + ":3", c + ":2-9", "--project", projectDir})
+	if err != nil {
+		t.Fatalf("cat error: %v", err)
+	}
+	if want := "==> " + b + ":3-3 <==\nb3\n\n==> " + c + ":2-3 <==\nc2\nc3\n"; out != want {
+		t.Fatalf("unexpected cat output without a trailing newline:\n%s", out)
+	}
+	if _, err := runCommand(app, []string{"cat", a + ":2-3", b + ":7-9", "--project", projectDir}); err == nil || !strings.Contains(err.Error(), "past the end of the file (3 lines)") {
+		t.Fatalf("expected an error for a range past the end, got %v", err)
+	}
+
+	out, err = runCommand(app, []string{"cat", a + "#L4", "--project", projectDir})
+	if err != nil {
+		t.Fatalf("cat error: %v", err)
+	}
+	if out != "line4\n" {
+		t.Fatalf("expected a single file without header, got %q", out)
+	}
+
+	searchOut, err := runCommand(app, []string{"search", "org.jetbrains.kotlinx:kotlinx-datetime", "-q", "needle", "--project", projectDir})
+	if err != nil {
+		t.Fatalf("search error: %v", err)
+	}
+	out, err = runCommandWithInput(app, []string{"cat", "--from-search", "-C", "1", "--project", projectDir}, searchOut)
+	if err != nil {
+		t.Fatalf("cat --from-search error: %v", err)
+	}
+	want = "==> " + a + ":9-13 <==\nline9\nval needle10 = 10\nline11\nval needle12 = 12\nline13\n\n" +
+		"==> " + a + ":24-26 <==\nline24\nval needle25 = 25\nline26\n"
+	if out != want {
+		t.Fatalf("unexpected cat --from-search output:\n%s", out)
+	}
+
+	if _, err := runCommandWithInput(app, []string{"cat", "--from-search", "--project", projectDir}, "no hits\n"); err == nil || !strings.Contains(err.Error(), "no search hits") {
+		t.Fatalf("expected no search hits error, got %v", err)
+	}
+}
+
+func TestBudgetIntegration(t *testing.T) {
+	app := NewApp()
+	if _, err := app.Runner.LookPath("rg"); err != nil {
+		t.Skip("rg not available")
+	}
+	projectDir := filepath.Clean(filepath.Join("..", "..", "testdata", "fixture"))
+	dir := t.TempDir()
+	var big strings.Builder
+	big.WriteString("package kotlinx.datetime\n\nclass Big {\n")
+	for i := 1; i <= 200; i++ {
+		fmt.Fprintf(&big, "    fun needle%d() = %d\n", i, i)
+	}
+	big.WriteString("}") // no trailing newline
+	jarPath := filepath.Join(dir, "kotlinx-datetime-sources.jar")
+	if err := writeTestJarFiles(jarPath, map[string]string{
+		"kotlinx/datetime/Big.kt":   big.String(),
+		"kotlinx/datetime/Small.kt": "package kotlinx.datetime\n\nval needle = 0\n",
+	}); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	jvmJarPath := filepath.Join(dir, "kotlinx-datetime-jvm-sources.jar")
+	if err := writeTestJarFiles(jvmJarPath, map[string]string{"kotlinx/datetime/Jvm.kt": "val needleJvm = 1\n"}); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	t.Setenv("KSRC_TEST_JAR", jarPath)
+	t.Setenv("KSRC_TEST_JVM_JAR", jvmJarPath)
+
+	out, err := runCommand(app, []string{"search", "--all", "-q", "needle", "--budget", "100", "--project", projectDir})
+	if err != nil {
+		t.Fatalf("search error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	var shown int
+	for _, line := range lines {
+		if strings.Contains(line, "!/") {
+			shown++
+		}
+	}
+	if shown == 0 || len(out) > 400+300 {
+		t.Fatalf("expected a few matches within budget, got:\n%s", out)
+	}
+	if !strings.Contains(out, fmt.Sprintf("truncated: --budget 100 tokens reached; omitted %d matches in", 202-shown)) ||
+		!strings.Contains(out, fmt.Sprintf("  org.jetbrains.kotlinx:kotlinx-datetime:0.6.1  [matches: %d]  [files: 2]\n", 202-shown)) ||
+		!strings.Contains(out, "Try: narrow the search") {
+		t.Fatalf("expected per-module truncation summary, got:\n%s", out)
+	}
+
+	out, err = runCommand(app, []string{"search", "--all", "-q", "needle", "--budget", "100", "--json", "--project", projectDir})
+	if err != nil {
+		t.Fatalf("search --json error: %v", err)
+	}
+	lines = strings.Split(strings.TrimSpace(out), "\n")
+	var last struct {
+		Truncated struct {
+			Budget  string `json:"budget"`
+			Omitted []struct {
+				Module  string `json:"module"`
+				Matches int    `json:"matches"`
+			} `json:"omitted"`
+		} `json:"truncated"`
+	}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil || last.Truncated.Budget != "100 tokens" || len(last.Truncated.Omitted) != 1 {
+		t.Fatalf("expected a JSON truncation summary, got %s (%v)", lines[len(lines)-1], err)
+	}
+
+	big1 := "org.jetbrains.kotlinx:kotlinx-datetime:0.6.1!/kotlinx/datetime/Big.kt"
+	small := "org.jetbrains.kotlinx:kotlinx-datetime:0.6.1!/kotlinx/datetime/Small.kt"
+	out, err = runCommand(app, []string{"cat", small, big1 + ":50-199", small, "--budget", "2KB", "--project", projectDir})
+	if err != nil {
+		t.Fatalf("cat error: %v", err)
+	}
+	for _, want := range []string{
+		"==> " + small + " <==\npackage kotlinx.datetime\n",
+		"==> " + big1 + " (outline) <==\npackage kotlinx.datetime\n3-204: class Big\n4:   fun needle1()\n",
+		"==> " + big1 + ":50-",
+		"    fun needle47() = 47\n",
+		"truncated: --budget 2048 bytes reached; " + big1 + " has 204 lines (",
+		"printed its outline (cut short) and lines 50-",
+		"omitted: " + small + "\n",
+		"Try: ksrc cat " + big1 + ":<start>-<end>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in cat output:\n%s", want, out)
+		}
+	}
+	if len(out) > 2048+400 {
+		t.Fatalf("cat output over budget: %d bytes", len(out))
+	}
+
+	out, err = runCommand(app, []string{"ls", "--all", "--budget", "30", "--project", projectDir})
+	if err != nil {
+		t.Fatalf("ls error: %v", err)
+	}
+	if !strings.HasPrefix(out, "org.jetbrains.kotlinx:kotlinx-datetime:0.6.1!/kotlinx/datetime/Big.kt  [lines: 204]") ||
+		!strings.Contains(out, "truncated: --budget 30 tokens reached; omitted 2 lines (") {
+		t.Fatalf("unexpected ls output:\n%s", out)
+	}
+
+	input := `{"id":1,"op":"search","module":"org.jetbrains.kotlinx:kotlinx-datetime","q":"needle"}
+{"id":2,"op":"cat","fileId":"` + big1 + `"}
+{"id":3,"op":"nope"}
+`
+	out, err = runCommandWithInput(app, []string{"batch", "--budget", "100", "--project", projectDir}, input)
+	if err != nil {
+		t.Fatalf("batch error: %v", err)
+	}
+	lines = strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected one response per request, got:\n%s", out)
+	}
+	type batchResp struct {
+		Result struct {
+			Matches []json.RawMessage `json:"matches"`
+		} `json:"result"`
+		Error     string `json:"error"`
+		Truncated struct {
+			Budget  string `json:"budget"`
+			Omitted []struct {
+				Module  string `json:"module"`
+				Matches int    `json:"matches"`
+			} `json:"omitted"`
+		} `json:"truncated"`
+	}
+	var resps []batchResp
+	for _, line := range lines {
+		var resp batchResp
+		if err := json.Unmarshal([]byte(line), &resp); err != nil {
+			t.Fatalf("invalid json %q: %v", line, err)
+		}
+		resps = append(resps, resp)
+	}
+	kept := len(resps[0].Result.Matches)
+	if kept == 0 || resps[0].Truncated.Budget != "100 tokens" || len(resps[0].Truncated.Omitted) != 1 ||
+		resps[0].Truncated.Omitted[0].Matches != 201-kept || resps[0].Truncated.Omitted[0].Module != "org.jetbrains.kotlinx:kotlinx-datetime:0.6.1" {
+		t.Fatalf("expected a cut search response, got %s", lines[0])
+	}
+	if !strings.Contains(lines[1], `"omitted":[{"module":"org.jetbrains.kotlinx:kotlinx-datetime:0.6.1","matches":0,"files":1}]`) || strings.Contains(lines[1], `"result"`) {
+		t.Fatalf("expected a cut cat response, got %s", lines[1])
+	}
+	if !strings.Contains(resps[2].Error, "unknown op") {
+		t.Fatalf("expected the error response, got %s", lines[2])
+	}
+
+	if _, err := runCommand(app, []string{"ls", "--all", "--budget", "lots", "--project", projectDir}); err == nil || !strings.Contains(err.Error(), "invalid --budget") {
+		t.Fatalf("expected invalid budget error, got %v", err)
+	}
+}
+
+func TestCatStrip(t *testing.T) {
+	app := NewApp()
+	projectDir := filepath.Clean(filepath.Join("..", "..", "testdata", "fixture"))
+	jarPath := filepath.Join(t.TempDir(), "kotlinx-datetime-sources.jar")
+	src := "/*\n * Copyright 2024 JetBrains s.r.o.\n * Licensed under the Apache License, Version 2.0\n */\n\n" +
+		"package kotlinx.datetime\n\nimport kotlin.time.Duration\nimport kotlinx.serialization.Serializable\n\n" +
+		"// Lines are counted from 1.\n@Serializable\npublic class Instant {\n    val url = \"//not/a/comment\"\n}\n"
+	if err := writeTestJarFiles(jarPath, map[string]string{"kotlinx/datetime/Instant.kt": src}); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	t.Setenv("KSRC_TEST_JAR", jarPath)
+	fileID := "org.jetbrains.kotlinx:kotlinx-datetime:0.6.1!/kotlinx/datetime/Instant.kt"
+
+	out, err := runCommand(app, []string{"cat", fileID, "--strip", "license,imports,comments", "-n", "--project", projectDir})
+	if err != nil {
+		t.Fatalf("cat --strip error: %v", err)
+	}
+	want := "     6\tpackage kotlinx.datetime\n     7\t\n    12\t@Serializable\n    13\tpublic class Instant {\n    14\t    val url = \"//not/a/comment\"\n    15\t}\n"
+	if out != want {
+		t.Fatalf("unexpected stripped output:\n%s", out)
+	}
+
+	out, err = runCommand(app, []string{"cat", fileID + ":8-13", "--strip", "imports,blank", "--project", projectDir})
+	if err != nil {
+		t.Fatalf("cat --strip range error: %v", err)
+	}
+	if out != "// Lines are counted from 1.\n@Serializable\npublic class Instant {\n" {
+		t.Fatalf("unexpected stripped range output:\n%s", out)
+	}
+
+	if _, err := runCommand(app, []string{"cat", fileID, "--strip", "whitespace", "--project", projectDir}); err == nil || !strings.Contains(err.Error(), "invalid --strip") {
+		t.Fatalf("expected invalid --strip error, got %v", err)
+	}
+}
+
+func TestCatLineRanges(t *testing.T) {
+	app := NewApp()
+	projectDir := filepath.Clean(filepath.Join("..", "..", "testdata", "fixture"))
+	jarPath := filepath.Join(t.TempDir(), "kotlinx-datetime-sources.jar")
+	var body strings.Builder
+	for i := 1; i <= 40; i++ {
+		fmt.Fprintf(&body, "line%d\n", i)
+	}
+	if err := writeTestJarFiles(jarPath, map[string]string{"kotlinx/datetime/A.kt": body.String()}); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	t.Setenv("KSRC_TEST_JAR", jarPath)
+	fileID := "org.jetbrains.kotlinx:kotlinx-datetime:0.6.1!/kotlinx/datetime/A.kt"
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--lines", "10,+2"}, "line10\nline11\nline12\n"},
+		{[]string{"--lines", "39:"}, "line39\nline40\n"},
+		{[]string{"--lines", "-2"}, "line39\nline40\n"},
+		{[]string{"--around", "20", "--context", "1", "-n"}, "    19\tline19\n    20\tline20\n    21\tline21\n"},
+		{[]string{"--lines", "1,2", "--lines", "-1", "-n"}, "==> " + fileID + ":1-2 <==\n     1\tline1\n     2\tline2\n\n==> " + fileID + ":40-40 <==\n    40\tline40\n"},
+	} {
+		out, err := runCommand(app, append([]string{"cat", fileID, "--project", projectDir}, tc.args...))
+		if err != nil {
+			t.Fatalf("cat %v error: %v", tc.args, err)
+		}
+		if out != tc.want {
+			t.Fatalf("unexpected cat %v output:\n%s", tc.args, out)
+		}
+	}
+
+	t.Setenv("PAGER", "cat")
+	out, err := runCommand(app, []string{"open", fileID + ":3-", "-n", "--project", projectDir})
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+	if !strings.HasPrefix(out, "     3\tline3\n") || !strings.HasSuffix(out, "    40\tline40\n") {
+		t.Fatalf("unexpected open output:\n%s", out)
+	}
+
+	input := `{"id":1,"op":"cat","fileId":"` + fileID + `","lines":["1,+1","-1"],"lineNumbers":true}
+{"id":2,"op":"cat","fileId":"` + fileID + `","around":5,"context":1}
+`
+	out, err = runCommandWithInput(app, []string{"batch", "--project", projectDir}, input)
+	if err != nil {
+		t.Fatalf("batch error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	var multi struct {
+		Result []batchFile `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &multi); err != nil || len(multi.Result) != 2 ||
+		multi.Result[0].Text != "     1\tline1\n     2\tline2\n" || multi.Result[1].StartLine != 40 || multi.Result[1].EndLine != 40 {
+		t.Fatalf("unexpected batch ranges response: %s (%v)", lines[0], err)
+	}
+	var single struct {
+		Result batchFile `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &single); err != nil || single.Result.Text != "line4\nline5\nline6\n" || single.Result.StartLine != 4 {
+		t.Fatalf("unexpected batch around response: %s (%v)", lines[1], err)
+	}
+}
+
 func TestSearchContextAndPassThrough(t *testing.T) {
 	app := NewApp()
 	if _, err := app.Runner.LookPath("rg"); err != nil {
@@ -69,8 +406,8 @@ func TestSearchContextAndPassThrough(t *testing.T) {
 		t.Fatalf("search error: %v", err)
 	}
 	fileID := "org.jetbrains.kotlinx:kotlinx-datetime:0.6.1!/" + inner
-	if !strings.Contains(ctxOut, fileID+" 1:0:before") || !strings.Contains(ctxOut, fileID+" 3:0:after") {
-		t.Fatalf("context lines missing: %s", ctxOut)
+	if ctxOut != fileID+" 1-3\n1-before\n2:1:public class LocalDate\n3-after\n" {
+		t.Fatalf("unexpected context block: %q", ctxOut)
 	}
 
 	filteredOut, err := runCommand(app, []string{"search", "org.jetbrains.kotlinx:kotlinx-datetime", "-q", "public class LocalDate", "--project", projectDir, "--", "-g", "!*.kt"})
@@ -107,6 +444,885 @@ func TestSearchModuleGlobArg(t *testing.T) {
 	}
 }
 
+func TestSearchEnclosing(t *testing.T) {
+	app := NewApp()
+	if _, err := app.Runner.LookPath("rg"); err != nil {
+		t.Skip("rg not available")
+	}
+
+	projectDir := filepath.Clean(filepath.Join("..", "..", "testdata", "fixture"))
+	jarPath := filepath.Join(t.TempDir(), "kotlinx-datetime-sources.jar")
+	inner := "kotlinx/datetime/LocalDate.kt"
+	content := "package kotlinx.datetime\n\n" +
+		"public class LocalDate(val value: Int) {\n" +
+		"    public fun next(): LocalDate {\n" +
+		"        return LocalDate(value + 1)\n" +
+		"    }\n" +
+		"}\n\n" +
+		"val needleTop = 1\n"
+	if err := writeTestJar(jarPath, inner, content); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	t.Setenv("KSRC_TEST_JAR", jarPath)
+
+	out, err := runCommand(app, []string{"search", "org.jetbrains.kotlinx:kotlinx-datetime", "-q", "return LocalDate", "--enclosing", "--project", projectDir})
+	if err != nil {
+		t.Fatalf("search error: %v", err)
+	}
+	fileID := "org.jetbrains.kotlinx:kotlinx-datetime:0.6.1!/" + inner
+	want := fileID + " 5:9:        return LocalDate(value + 1)\tpublic class LocalDate(val value: Int) [3-7] | public fun next(): LocalDate [4-6]\n"
+	if out != want {
+		t.Fatalf("unexpected enclosing output: %q", out)
+	}
+
+	out, err = runCommand(app, []string{"search", "org.jetbrains.kotlinx:kotlinx-datetime", "-q", "needleTop|return", "--enclosing", "--json", "--project", projectDir})
+	if err != nil {
+		t.Fatalf("search error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("unexpected json output: %s", out)
+	}
+	var first, second search.Match
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if first.FileID != fileID || first.File != "" || first.Class == nil || first.Class.Name != "LocalDate" || first.Function == nil || first.Function.Name != "LocalDate.next" || first.Function.StartLine != 4 || first.Function.EndLine != 6 {
+		t.Fatalf("unexpected first match: %s", lines[0])
+	}
+	if len(first.Submatches) != 1 || first.Submatches[0].Text != "return" || first.Column != first.Submatches[0].Start+1 {
+		t.Fatalf("unexpected submatches: %s", lines[0])
+	}
+	if second.Class != nil || second.Function == nil || second.Function.Kind != "val" {
+		t.Fatalf("unexpected top-level match: %s", lines[1])
+	}
+}
+
+func TestSearchOddFileNames(t *testing.T) {
+	app := NewApp()
+	if _, err := app.Runner.LookPath("rg"); err != nil {
+		t.Skip("rg not available")
+	}
+
+	projectDir := filepath.Clean(filepath.Join("..", "..", "testdata", "fixture"))
+	jarPath := filepath.Join(t.TempDir(), "kotlinx-datetime-sources.jar")
+	if err := writeTestJarFiles(jarPath, map[string]string{
+		"kotlinx/datetime/Foo-Bar-1.kt": "val a: Int = 1\nval x: Map<String, Int> = mapOf(\"k\" to 2)\nval b - 3\n",
+	}); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	t.Setenv("KSRC_TEST_JAR", jarPath)
+
+	out, err := runCommand(app, []string{"search", "org.jetbrains.kotlinx:kotlinx-datetime", "-q", "mapOf", "--context", "1", "--project", projectDir})
+	if err != nil {
+		t.Fatalf("search error: %v", err)
+	}
+	fileID := "org.jetbrains.kotlinx:kotlinx-datetime:0.6.1!/kotlinx/datetime/Foo-Bar-1.kt"
+	want := fileID + " 1-3\n1-val a: Int = 1\n2:27:val x: Map<String, Int> = mapOf(\"k\" to 2)\n3-val b - 3\n"
+	if out != want {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestSearchBooleanQuery(t *testing.T) {
+	app := NewApp()
+	if _, err := app.Runner.LookPath("rg"); err != nil {
+		t.Skip("rg not available")
+	}
+
+	projectDir := filepath.Clean(filepath.Join("..", "..", "testdata", "fixture"))
+	jarPath := filepath.Join(t.TempDir(), "kotlinx-datetime-sources.jar")
+	if err := writeTestJarFiles(jarPath, map[string]string{
+		"a/Both.kt":    "package a\nsuspend fun first()\nfun flow(): Flow<Int>\n",
+		"a/Suspend.kt": "package a\nsuspend fun second()\n",
+		"a/Line.kt":    "package a\nsuspend fun third(): Flow<Int>\n",
+	}); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	t.Setenv("KSRC_TEST_JAR", jarPath)
+
+	base := []string{"search", "org.jetbrains.kotlinx:kotlinx-datetime", "--project", projectDir, "-q", "suspend fun", "-q", "Flow<", "-F"}
+	lines := func(args ...string) []string {
+		out, err := runCommand(app, append(append([]string{}, base...), args...))
+		if err != nil {
+			t.Fatalf("search %v error: %v", args, err)
+		}
+		var got []string
+		for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+			if line != "" {
+				fields := strings.Fields(line)
+				got = append(got, strings.TrimPrefix(fields[0], "org.jetbrains.kotlinx:kotlinx-datetime:0.6.1!/")+" "+strings.SplitN(fields[1], ":", 2)[0])
+			}
+		}
+		sort.Strings(got)
+		return got
+	}
+
+	if got := strings.Join(lines(), ","); got != "a/Both.kt 2,a/Both.kt 3,a/Line.kt 2,a/Suspend.kt 2" {
+		t.Fatalf("or: %s", got)
+	}
+	if got := strings.Join(lines("--and"), ","); got != "a/Both.kt 2,a/Both.kt 3,a/Line.kt 2" {
+		t.Fatalf("and per file: %s", got)
+	}
+	if got := strings.Join(lines("--and", "--per", "line"), ","); got != "a/Line.kt 2" {
+		t.Fatalf("and per line: %s", got)
+	}
+	if got := strings.Join(lines("--not", "first"), ","); got != "a/Line.kt 2,a/Suspend.kt 2" {
+		t.Fatalf("not: %s", got)
+	}
+	if _, err := runCommand(app, append(append([]string{}, base...), "--and", "--or")); err == nil {
+		t.Fatal("expected error for --and with --or")
+	}
+}
+
+func TestSearchInRegions(t *testing.T) {
+	app := NewApp()
+	if _, err := app.Runner.LookPath("rg"); err != nil {
+		t.Skip("rg not available")
+	}
+
+	projectDir := filepath.Clean(filepath.Join("..", "..", "testdata", "fixture"))
+	jarPath := filepath.Join(t.TempDir(), "kotlinx-datetime-sources.jar")
+	if err := writeTestJar(jarPath, "kotlinx/datetime/Instant.kt", "/*\n * Instant license\n */\npackage kotlinx.datetime\n\nclass Instant {\n    override fun toString() = \"Instant\"\n}\n"); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	t.Setenv("KSRC_TEST_JAR", jarPath)
+
+	fileID := "org.jetbrains.kotlinx:kotlinx-datetime:0.6.1!/kotlinx/datetime/Instant.kt"
+	base := []string{"search", "org.jetbrains.kotlinx:kotlinx-datetime", "-q", "Instant", "--project", projectDir}
+	out, err := runCommand(app, append(base, "--in", "code"))
+	if err != nil {
+		t.Fatalf("search --in code error: %v", err)
+	}
+	if out != fileID+" 6:7:class Instant {\n" {
+		t.Fatalf("unexpected --in code output:\n%s", out)
+	}
+	out, err = runCommand(app, append(base, "--in", "comments,strings", "--json"))
+	if err != nil {
+		t.Fatalf("search --in comments,strings error: %v", err)
+	}
+	var regions []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var m search.Match
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("invalid json %q: %v", line, err)
+		}
+		regions = append(regions, fmt.Sprintf("%d:%d:%s", m.Line, m.Column, m.In))
+	}
+	if got := strings.Join(regions, ","); got != "2:4:comment,7:32:string" {
+		t.Fatalf("unexpected regions: %s", got)
+	}
+	if _, err := runCommand(app, append(base, "--in", "docs")); err == nil {
+		t.Fatal("expected error for unknown region")
+	}
+}
+
+func TestSearchRejectsFlagsBeforeResolving(t *testing.T) {
+	app := NewApp()
+	// No Gradle project: resolving would fail with a different error.
+	base := []string{"search", "org.jetbrains.kotlinx:kotlinx-datetime", "-q", "Instant", "--project", t.TempDir()}
+	if _, err := runCommand(app, append(base, "--jobs", "-1")); err == nil || !strings.Contains(err.Error(), "invalid --jobs") {
+		t.Fatalf("expected invalid --jobs error, got %v", err)
+	}
+	if _, err := runCommand(app, append(base, "--in", "docs")); err == nil || !strings.Contains(err.Error(), "invalid --in") {
+		t.Fatalf("expected invalid --in error, got %v", err)
+	}
+}
+
+func TestIndexIntegration(t *testing.T) {
+	app := NewApp()
+	if _, err := app.Runner.LookPath("rg"); err != nil {
+		t.Skip("rg not available")
+	}
+	t.Setenv("KSRC_CACHE_DIR", t.TempDir())
+
+	projectDir := filepath.Clean(filepath.Join("..", "..", "testdata", "fixture"))
+	jarPath := filepath.Join(t.TempDir(), "kotlinx-datetime-sources.jar")
+	if err := writeTestJarFiles(jarPath, map[string]string{
+		"kotlinx/datetime/Instant.kt": "package kotlinx.datetime\n\nclass Instant\n",
+		"kotlinx/datetime/Clock.kt":   "package kotlinx.datetime\n\ninterface Clock\n",
+	}); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	t.Setenv("KSRC_TEST_JAR", jarPath)
+
+	module := "org.jetbrains.kotlinx:kotlinx-datetime"
+	out, err := runCommand(app, []string{"index", "status", module, "--project", projectDir})
+	if err != nil {
+		t.Fatalf("index status error: %v", err)
+	}
+	if !strings.Contains(out, "[index: missing]") {
+		t.Fatalf("expected missing index, got:\n%s", out)
+	}
+	out, err = runCommand(app, []string{"index", "build", module, "--project", projectDir})
+	if err != nil {
+		t.Fatalf("index build error: %v", err)
+	}
+	if !strings.Contains(out, module+":0.6.1  [index: indexed]  [files: 2]") {
+		t.Fatalf("unexpected index build output:\n%s", out)
+	}
+
+	base := []string{"search", module, "--project", projectDir}
+	out, err = runCommand(app, append(base, "-q", "class Inst"))
+	if err != nil {
+		t.Fatalf("indexed search error: %v", err)
+	}
+	if out != module+":0.6.1!/kotlinx/datetime/Instant.kt 3:1:class Instant\n" {
+		t.Fatalf("unexpected indexed search output:\n%s", out)
+	}
+	out, err = runCommand(app, append(base, "-q", "clock", "--", "-i"))
+	if err != nil {
+		t.Fatalf("case-insensitive indexed search error: %v", err)
+	}
+	if out != module+":0.6.1!/kotlinx/datetime/Clock.kt 3:11:interface Clock\n" {
+		t.Fatalf("unexpected case-insensitive search output:\n%s", out)
+	}
+	if out, err = runCommand(app, append(base, "-q", "Missing")); err != nil || out != "" {
+		t.Fatalf("expected no matches, got %q, %v", out, err)
+	}
+	// Narrowed searches read candidates from the jar instead of extracting it.
+	if done, _ := filepath.Glob(filepath.Join(os.Getenv("KSRC_CACHE_DIR"), "extract", "*.done")); len(done) != 0 {
+		t.Fatalf("expected no extracted jars after indexed searches, got %v", done)
+	}
+
+	if _, err := runCommand(app, []string{"index", "clear"}); err != nil {
+		t.Fatalf("index clear error: %v", err)
+	}
+	out, err = runCommand(app, []string{"index", "status", module, "--project", projectDir})
+	if err != nil {
+		t.Fatalf("index status error: %v", err)
+	}
+	if !strings.Contains(out, "[index: missing]") {
+		t.Fatalf("expected missing index after clear, got:\n%s", out)
+	}
+}
+
+func TestSearchShowExtractedPath(t *testing.T) {
+	app := NewApp()
+	if _, err := app.Runner.LookPath("rg"); err != nil {
+		t.Skip("rg not available")
+	}
+	cacheDir := t.TempDir()
+	t.Setenv("KSRC_CACHE_DIR", cacheDir)
+
+	projectDir := filepath.Clean(filepath.Join("..", "..", "testdata", "fixture"))
+	jarPath := filepath.Join(t.TempDir(), "kotlinx-datetime-sources.jar")
+	if err := writeTestJarFiles(jarPath, map[string]string{
+		"commonMain/kotlinx/datetime/Instant.kt": "package kotlinx.datetime\n\nclass Instant\n",
+		"jvmMain/kotlinx/datetime/Clock.kt":      "package kotlinx.datetime\n\nclass Clock(val now: Instant)\n",
+	}); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	t.Setenv("KSRC_TEST_JAR", jarPath)
+
+	base := []string{"search", "org.jetbrains.kotlinx:kotlinx-datetime", "-q", "class Instant", "--project", projectDir, "--show-extracted-path"}
+	path := func(args []string) string {
+		out, err := runCommand(app, args)
+		if err != nil {
+			t.Fatalf("search error: %v", err)
+		}
+		fields := strings.Fields(out)
+		if len(fields) < 2 {
+			t.Fatalf("unexpected output:\n%s", out)
+		}
+		return strings.TrimSuffix(fields[1], ":3:1:class")
+	}
+	first := path(base)
+	if strings.Contains(first, ".jar:") {
+		t.Skip("rg searches zips directly; nothing is extracted")
+	}
+	if !strings.HasPrefix(first, cacheDir) || !strings.HasSuffix(first, filepath.FromSlash("commonMain/kotlinx/datetime/Instant.kt")) {
+		t.Fatalf("expected a path in the extraction cache, got %s", first)
+	}
+	if data, err := os.ReadFile(first); err != nil || !strings.Contains(string(data), "class Instant") {
+		t.Fatalf("expected extracted file to stay readable: %v", err)
+	}
+	if again := path(base); again != first {
+		t.Fatalf("expected a stable path, got %s then %s", first, again)
+	}
+	if filtered := path(append(base, "--source-set", "commonMain")); filtered != first {
+		t.Fatalf("expected filtered search to report %s, got %s", first, filtered)
+	}
+}
+
+func TestLsIntegration(t *testing.T) {
+	app := NewApp()
+	projectDir := filepath.Clean(filepath.Join("..", "..", "testdata", "fixture"))
+	jarPath := filepath.Join(t.TempDir(), "kotlinx-datetime-sources.jar")
+	if err := writeTestJarFiles(jarPath, map[string]string{
+		"commonMain/kotlinx/datetime/Instant.kt":       "package kotlinx.datetime\n\nclass Instant\n",
+		"commonMain/kotlinx/datetime/format/Parser.kt": "package kotlinx.datetime.format\n",
+		"jvmMain/kotlinx/datetime/Clock.kt":            "package kotlinx.datetime\n\nclass Clock\n",
+		"META-INF/MANIFEST.MF":                         "Manifest-Version: 1.0\n",
+	}); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	t.Setenv("KSRC_TEST_JAR", jarPath)
+
+	module := "org.jetbrains.kotlinx:kotlinx-datetime"
+	prefix := module + ":0.6.1!/"
+	out, err := runCommand(app, []string{"ls", module, "--project", projectDir})
+	if err != nil {
+		t.Fatalf("ls error: %v", err)
+	}
+	want := prefix + "commonMain/kotlinx/datetime/Instant.kt  [lines: 3]  [bytes: 40]\n" +
+		prefix + "commonMain/kotlinx/datetime/format/Parser.kt  [lines: 1]  [bytes: 32]\n" +
+		prefix + "jvmMain/kotlinx/datetime/Clock.kt  [lines: 3]  [bytes: 38]\n"
+	if out != want {
+		t.Fatalf("unexpected ls output:\n%s", out)
+	}
+
+	out, err = runCommand(app, []string{"ls", module, "--project", projectDir, "--glob", "kotlinx/datetime/*.kt", "--tree"})
+	if err != nil {
+		t.Fatalf("ls --tree error: %v", err)
+	}
+	want = module + ":0.6.1\n" +
+		"  commonMain/kotlinx/datetime/  [files: 1]  [lines: 3]\n" +
+		"    Instant.kt  [lines: 3]  [bytes: 40]\n" +
+		"  jvmMain/kotlinx/datetime/  [files: 1]  [lines: 3]\n" +
+		"    Clock.kt  [lines: 3]  [bytes: 38]\n"
+	if out != want {
+		t.Fatalf("unexpected ls --tree output:\n%s", out)
+	}
+
+	out, err = runCommand(app, []string{"ls", "--find", "InstantKt", "--project", projectDir, "--max-results", "1"})
+	if err != nil {
+		t.Fatalf("ls --find error: %v", err)
+	}
+	if !strings.HasPrefix(out, prefix+"commonMain/kotlinx/datetime/Instant.kt ") || strings.Count(out, "\n") != 1 {
+		t.Fatalf("unexpected ls --find output:\n%s", out)
+	}
+	if _, err := runCommand(app, []string{"ls", "--project", projectDir}); err == nil || !strings.Contains(err.Error(), "E_NO_MODULE") {
+		t.Fatalf("expected E_NO_MODULE, got %v", err)
+	}
+}
+
+func TestSearchWithDeps(t *testing.T) {
+	app := NewApp()
+	if _, err := app.Runner.LookPath("rg"); err != nil {
+		t.Skip("rg not available")
+	}
+
+	projectDir := filepath.Clean(filepath.Join("..", "..", "testdata", "fixture"))
+	dir := t.TempDir()
+	jarPath := filepath.Join(dir, "kotlinx-datetime-sources.jar")
+	if err := writeTestJar(jarPath, "kotlinx/datetime/Instant.kt", "package kotlinx.datetime\n@Serializable class Instant\n"); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	depJarPath := filepath.Join(dir, "kotlinx-serialization-core-sources.jar")
+	if err := writeTestJar(depJarPath, "kotlinx/serialization/Serializable.kt", "package kotlinx.serialization\nannotation class Serializable\n"); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	t.Setenv("KSRC_TEST_JAR", jarPath)
+	t.Setenv("KSRC_TEST_DEP_JAR", depJarPath)
+
+	args := []string{"search", "org.jetbrains.kotlinx:kotlinx-datetime", "-q", "Serializable", "--project", projectDir}
+	out, err := runCommand(app, args)
+	if err != nil {
+		t.Fatalf("search error: %v", err)
+	}
+	if strings.Contains(out, "kotlinx-serialization-core") {
+		t.Fatalf("dependency searched without --with-deps:\n%s", out)
+	}
+	for _, flags := range [][]string{{"--with-deps"}, {"--with-deps", "--deps-depth", "1"}} {
+		out, err = runCommand(app, append(args, flags...))
+		if err != nil {
+			t.Fatalf("search %v error: %v", flags, err)
+		}
+		if !strings.Contains(out, "org.jetbrains.kotlinx:kotlinx-serialization-core:1.6.3!/kotlinx/serialization/Serializable.kt 2:") {
+			t.Fatalf("search %v missing dependency match:\n%s", flags, out)
+		}
+	}
+	if _, err := runCommand(app, append(args, "--deps-depth", "1")); err == nil || !strings.Contains(err.Error(), "requires --with-deps") {
+		t.Fatalf("expected --deps-depth without --with-deps to fail, got %v", err)
+	}
+}
+
+func TestSearchFilters(t *testing.T) {
+	app := NewApp()
+	if _, err := app.Runner.LookPath("rg"); err != nil {
+		t.Skip("rg not available")
+	}
+
+	projectDir := filepath.Clean(filepath.Join("..", "..", "testdata", "fixture"))
+	jarPath := filepath.Join(t.TempDir(), "kotlinx-datetime-sources.jar")
+	if err := writeTestJarFiles(jarPath, map[string]string{
+		"commonMain/flow/Flow.kt":          "package kotlinx.coroutines.flow\nexpect class Needle\n",
+		"jvmMain/flow/Flow.kt":             "package kotlinx.coroutines.flow\nactual class Needle\n",
+		"commonMain/channels/Channel.kt":   "package kotlinx.coroutines.channels\nclass Needle\n",
+		"commonMain/flow/internal/Impl.kt": "package kotlinx.coroutines.flow.internal\nclass Needle\n",
+	}); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	t.Setenv("KSRC_TEST_JAR", jarPath)
+
+	prefix := "org.jetbrains.kotlinx:kotlinx-datetime:0.6.1!/"
+	for _, tc := range []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"--package", "kotlinx.coroutines.flow", "--source-set", "commonMain"}, []string{"commonMain/flow/Flow.kt", "commonMain/flow/internal/Impl.kt"}},
+		{[]string{"--source-set", "jvmMain"}, []string{"jvmMain/flow/Flow.kt"}},
+		{[]string{"--path", "flow/*.kt"}, []string{"commonMain/flow/Flow.kt", "jvmMain/flow/Flow.kt"}},
+		{[]string{"--path", "**/channels/**", "--package", "kotlinx.coroutines.flow"}, nil},
+	} {
+		args := append([]string{"search", "org.jetbrains.kotlinx:kotlinx-datetime", "-q", "class Needle", "--project", projectDir}, tc.args...)
+		out, err := runCommand(app, args)
+		if err != nil {
+			t.Fatalf("search %v error: %v", tc.args, err)
+		}
+		var got []string
+		for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+			if line == "" {
+				continue
+			}
+			id := strings.Fields(line)[0]
+			got = append(got, strings.TrimPrefix(id, prefix))
+		}
+		sort.Strings(got)
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Fatalf("search %v: got %v, want %v", tc.args, got, tc.want)
+		}
+	}
+}
+
+func TestExtensionsIntegration(t *testing.T) {
+	app := NewApp()
+	projectDir := filepath.Clean(filepath.Join("..", "..", "testdata", "fixture"))
+	jarPath := filepath.Join(t.TempDir(), "kotlinx-datetime-sources.jar")
+	inner := "kotlinx/datetime/Extensions.kt"
+	content := "package kotlinx.datetime\n\n" +
+		"public fun LocalDate.plusDays(days: Int): LocalDate = this\n" +
+		"public val LocalDate?.isSet: Boolean get() = this != null\n" +
+		"public fun <T : LocalDate> T.generic(): T = this\n" +
+		"public fun <T> T.anything(): T = this\n" +
+		"public fun Instant.other(): Instant = this\n"
+	if err := writeTestJar(jarPath, inner, content); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	t.Setenv("KSRC_TEST_JAR", jarPath)
+
+	out, err := runCommand(app, []string{"extensions", "LocalDate", "org.jetbrains.kotlinx:kotlinx-datetime", "--project", projectDir})
+	if err != nil {
+		t.Fatalf("extensions error: %v", err)
+	}
+	fileID := "org.jetbrains.kotlinx:kotlinx-datetime:0.6.1!/" + inner
+	for _, want := range []string{
+		fileID + " 3:public fun LocalDate.plusDays(days: Int): LocalDate",
+		fileID + " 4:public val LocalDate?.isSet: Boolean",
+		fileID + " 5:public fun <T : LocalDate> T.generic(): T",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in output: %s", want, out)
+		}
+	}
+	if strings.Contains(out, "anything") || strings.Contains(out, "other") {
+		t.Fatalf("unexpected extensions in output: %s", out)
+	}
+}
+
+func TestAnnotatedIntegration(t *testing.T) {
+	app := NewApp()
+	projectDir := filepath.Clean(filepath.Join("..", "..", "testdata", "fixture"))
+	jarPath := filepath.Join(t.TempDir(), "kotlinx-datetime-sources.jar")
+	inner := "kotlinx/datetime/Annotated.kt"
+	content := "package kotlinx.datetime\n\n" +
+		"import kotlinx.other.Experimental as Exp\n\n" +
+		"@ExperimentalTime\n" +
+		"public fun simple() {}\n\n" +
+		"@Deprecated(\n" +
+		"    message = \"use other\",\n" +
+		"    level = DeprecationLevel.ERROR,\n" +
+		")\n" +
+		"@ExperimentalTime public class MultiLine {\n" +
+		"    @ExperimentalTime\n" +
+		"    val nested: Int = 1\n" +
+		"}\n\n" +
+		"@Exp\n" +
+		"public fun aliased() {}\n\n" +
+		"// @ExperimentalTime in a comment\n" +
+		"public fun plain() {}\n"
+	if err := writeTestJar(jarPath, inner, content); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	t.Setenv("KSRC_TEST_JAR", jarPath)
+
+	out, err := runCommand(app, []string{"annotated", "@ExperimentalTime", "org.jetbrains.kotlinx:kotlinx-datetime", "--project", projectDir})
+	if err != nil {
+		t.Fatalf("annotated error: %v", err)
+	}
+	fileID := "org.jetbrains.kotlinx:kotlinx-datetime:0.6.1!/" + inner
+	want := fileID + " 6:public fun simple()\n" +
+		fileID + " 12:public class MultiLine\n" +
+		fileID + " 14:val nested: Int\n"
+	if out != want {
+		t.Fatalf("unexpected annotated output:\n%s", out)
+	}
+
+	out, err = runCommand(app, []string{"annotated", "kotlin.Deprecated", "org.jetbrains.kotlinx:kotlinx-datetime", "--project", projectDir})
+	if err != nil {
+		t.Fatalf("annotated error: %v", err)
+	}
+	if out != fileID+" 12:public class MultiLine\n" {
+		t.Fatalf("unexpected annotated output for FQN:\n%s", out)
+	}
+
+	out, err = runCommand(app, []string{"annotated", "kotlinx.other.Experimental", "org.jetbrains.kotlinx:kotlinx-datetime", "--project", projectDir})
+	if err != nil {
+		t.Fatalf("annotated error: %v", err)
+	}
+	if out != fileID+" 18:public fun aliased()\n" {
+		t.Fatalf("unexpected annotated output for alias:\n%s", out)
+	}
+}
+
+func TestImportsIntegration(t *testing.T) {
+	app := NewApp()
+	projectDir := filepath.Clean(filepath.Join("..", "..", "testdata", "fixture"))
+	jarPath := filepath.Join(t.TempDir(), "kotlinx-datetime-sources.jar")
+	inner := "kotlinx/datetime/LocalDate.kt"
+	if err := writeTestJar(jarPath, inner, "package kotlinx.datetime\n\npublic class LocalDate\n"); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	t.Setenv("KSRC_TEST_JAR", jarPath)
+
+	src := filepath.Join(t.TempDir(), "Main.kt")
+	content := "package com.example\n\n" +
+		"import kotlinx.datetime.LocalDate as Date\n" +
+		"import kotlinx.datetime.*\n" +
+		"import com.example.internal.Helper\n"
+	if err := os.WriteFile(src, []byte(content), 0o644); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+
+	out, err := runCommand(app, []string{"imports", src, "--project", projectDir})
+	if err != nil {
+		t.Fatalf("imports error: %v", err)
+	}
+	fileID := "org.jetbrains.kotlinx:kotlinx-datetime:0.6.1!/" + inner
+	for _, want := range []string{
+		"kotlinx.datetime.LocalDate as Date " + fileID + " 3:public class LocalDate",
+		"kotlinx.datetime.* org.jetbrains.kotlinx:kotlinx-datetime:0.6.1",
+		"unresolved (project-internal or binary-only):\ncom.example.internal.Helper",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in output: %s", want, out)
+		}
+	}
+}
+
+func TestOwningSubproject(t *testing.T) {
+	root := t.TempDir()
+	module := filepath.Join(root, "feature", "api")
+	srcDir := filepath.Join(module, "src", "main", "kotlin")
+	if err := os.MkdirAll(srcDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(module, "build.gradle.kts"), nil, 0o644); err != nil {
+		t.Fatalf("write build file: %v", err)
+	}
+	if got := owningSubproject(root, filepath.Join(srcDir, "Api.kt")); got != ":feature:api" {
+		t.Fatalf("unexpected subproject: %q", got)
+	}
+	if got := owningSubproject(root, filepath.Join(root, "Root.kt")); got != "" {
+		t.Fatalf("expected root project, got %q", got)
+	}
+}
+
+func TestGotoIntegration(t *testing.T) {
+	app := NewApp()
+	projectDir := filepath.Clean(filepath.Join("..", "..", "testdata", "fixture"))
+	dir := t.TempDir()
+	jarPath := filepath.Join(dir, "kotlinx-datetime-sources.jar")
+	if err := writeTestJarFiles(jarPath, map[string]string{
+		"kotlinx/datetime/LocalDate.kt": "package kotlinx.datetime\n\npublic class LocalDate {\n    fun plus(): Instant = TODO()\n}\n",
+		"kotlinx/datetime/Instant.kt":   "package kotlinx.datetime\n\n/** doc */\npublic class Instant\n",
+		"kotlinx/datetime/Format.kt":    "package kotlinx.datetime\n\nimport kotlinx.serialization.Serializer\n\nclass Serializer\n\nfun format(s: Serializer) = s\n",
+	}); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	// kotlinx-datetime depends on the dep jar but not on the jvm jar.
+	depJarPath := filepath.Join(dir, "kotlinx-serialization-core-sources.jar")
+	if err := writeTestJarFiles(depJarPath, map[string]string{
+		"kotlinx/serialization/Serializer.kt": "package kotlinx.serialization\n\npublic annotation class Serializer\n",
+	}); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	jvmJarPath := filepath.Join(dir, "kotlinx-datetime-jvm-sources.jar")
+	if err := writeTestJarFiles(jvmJarPath, map[string]string{
+		"kotlinx/datetime/Instant.kt": "package kotlinx.datetime\n\npublic class Instant\n",
+	}); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	t.Setenv("KSRC_TEST_JAR", jarPath)
+	t.Setenv("KSRC_TEST_DEP_JAR", depJarPath)
+	t.Setenv("KSRC_TEST_JVM_JAR", jvmJarPath)
+
+	prefix := "org.jetbrains.kotlinx:kotlinx-datetime:0.6.1!/kotlinx/datetime/"
+	out, err := runCommand(app, []string{"goto", prefix + "LocalDate.kt:4:17", "--project", projectDir})
+	if err != nil {
+		t.Fatalf("goto error: %v", err)
+	}
+	if strings.TrimSpace(out) != prefix+"Instant.kt 4-4:public class Instant" {
+		t.Fatalf("unexpected goto output: %q", out)
+	}
+
+	out, err = runCommand(app, []string{"goto", prefix + "Format.kt:7:15", "--project", projectDir})
+	if err != nil {
+		t.Fatalf("goto imported error: %v", err)
+	}
+	if want := "org.jetbrains.kotlinx:kotlinx-serialization-core:1.6.3!/kotlinx/serialization/Serializer.kt 3-3:public annotation class Serializer"; strings.TrimSpace(out) != want {
+		t.Fatalf("expected the explicit import to win over the same-file class, got %q", out)
+	}
+
+	if _, err := runCommand(app, []string{"goto", prefix + "LocalDate.kt:4:1", "--project", projectDir}); err == nil {
+		t.Fatal("expected error for position without identifier")
+	}
+}
+
+func TestDocIntegration(t *testing.T) {
+	app := NewApp()
+	projectDir := filepath.Clean(filepath.Join("..", "..", "testdata", "fixture"))
+	jarPath := filepath.Join(t.TempDir(), "kotlinx-datetime-sources.jar")
+	if err := writeTestJarFiles(jarPath, map[string]string{
+		"kotlinx/datetime/Clock.kt":           "package kotlinx.datetime\n\npublic interface Clock {\n    /**\n     * Returns the current [Instant].\n     *\n     * @param zone the [TimeZone] to use\n     * @return the instant\n     * @sample kotlinx.datetime.samples.Samples.nowSample\n     */\n    public fun now(zone: TimeZone): Instant\n}\n",
+		"kotlinx/datetime/samples/Samples.kt": "package kotlinx.datetime.samples\n\nclass Samples {\n    fun nowSample() {\n        println(Clock.System.now())\n    }\n}\n",
+		"kotlinx/datetime/JavaClock.java":     "package kotlinx.datetime;\n\npublic class JavaClock {\n    /**\n     * Returns the {@code epoch} millis.\n     *\n     * @param offset added to the result\n     */\n    public long millis(long offset) {\n        return offset;\n    }\n}\n",
+	}); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	t.Setenv("KSRC_TEST_JAR", jarPath)
+
+	out, err := runCommand(app, []string{"doc", "Clock.now", "--project", projectDir})
+	if err != nil {
+		t.Fatalf("doc error: %v", err)
+	}
+	for _, want := range []string{
+		"==> org.jetbrains.kotlinx:kotlinx-datetime:0.6.1!/kotlinx/datetime/Clock.kt:11 <==",
+		"public fun now(zone: TimeZone): Instant",
+		"Returns the current Instant.",
+		"  zone: the TimeZone to use",
+		"Returns: the instant",
+		"  fun nowSample() {",
+		"      println(Clock.System.now())",
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Fatalf("doc output missing %q:\n%s", want, out)
+		}
+	}
+
+	out, err = runCommand(app, []string{"doc", "JavaClock.millis", "--project", projectDir})
+	if err != nil {
+		t.Fatalf("doc of a Java method error: %v", err)
+	}
+	for _, want := range []string{
+		"==> org.jetbrains.kotlinx:kotlinx-datetime:0.6.1!/kotlinx/datetime/JavaClock.java:9 <==",
+		"public long millis(long offset)",
+		"Returns the `epoch` millis.",
+		"  offset: added to the result",
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Fatalf("Java doc output missing %q:\n%s", want, out)
+		}
+	}
+
+	if _, err := runCommand(app, []string{"doc", "Clock.missing", "--project", projectDir}); err == nil {
+		t.Fatal("expected error for unknown symbol")
+	}
+}
+
+func TestClassIntegration(t *testing.T) {
+	app := NewApp()
+	projectDir := filepath.Clean(filepath.Join("..", "..", "testdata", "fixture"))
+	jarPath := filepath.Join(t.TempDir(), "kotlinx-datetime-sources.jar")
+	if err := writeTestJarFiles(jarPath, map[string]string{
+		"commonMain/kotlinx/datetime/Instant.kt":  "package kotlinx.datetime\n\npublic expect class Instant {\n    public class Builder\n}\n\npublic class InstantRange\n",
+		"jvmMain/kotlinx/datetime/InstantJvm.kt":  "package kotlinx.datetime\n\npublic actual class Instant\n",
+		"commonMain/src/Formats.kt":               "package kotlinx.datetime.format\n\npublic sealed interface DateTimeFormat<T>\n",
+		"commonMain/kotlinx/datetime/TimeZone.kt": "package kotlinx.datetime\n\npublic typealias ZoneId = String\n",
+	}); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	t.Setenv("KSRC_TEST_JAR", jarPath)
+
+	prefix := "org.jetbrains.kotlinx:kotlinx-datetime:0.6.1!/"
+	for fqn, want := range map[string]string{
+		"kotlinx.datetime.Instant": prefix + "commonMain/kotlinx/datetime/Instant.kt 3:public expect class Instant\n" +
+			prefix + "jvmMain/kotlinx/datetime/InstantJvm.kt 3:public actual class Instant\n",
+		"kotlinx.datetime.Instant.Builder":       prefix + "commonMain/kotlinx/datetime/Instant.kt 4:public class Builder\n",
+		"kotlinx.datetime.InstantRange":          prefix + "commonMain/kotlinx/datetime/Instant.kt 7:public class InstantRange\n",
+		"kotlinx.datetime.format.DateTimeFormat": prefix + "commonMain/src/Formats.kt 3:public sealed interface DateTimeFormat<T>\n",
+		"kotlinx.datetime.ZoneId":                prefix + "commonMain/kotlinx/datetime/TimeZone.kt 3:public typealias ZoneId = String\n",
+	} {
+		out, err := runCommand(app, []string{"class", fqn, "--project", projectDir})
+		if err != nil {
+			t.Fatalf("class %s error: %v", fqn, err)
+		}
+		if out != want {
+			t.Fatalf("unexpected class %s output:\n%s", fqn, out)
+		}
+	}
+
+	if _, err := runCommand(app, []string{"class", "kotlinx.datetime.Missing", "--project", projectDir}); err == nil || !strings.Contains(err.Error(), "class not found") {
+		t.Fatalf("expected class not found error, got %v", err)
+	}
+}
+
+func TestBatchIntegration(t *testing.T) {
+	app := NewApp()
+	projectDir := filepath.Clean(filepath.Join("..", "..", "testdata", "fixture"))
+	dir := t.TempDir()
+	jarPath := filepath.Join(dir, "kotlinx-datetime-sources.jar")
+	if err := writeTestJarFiles(jarPath, map[string]string{
+		"commonMain/kotlinx/datetime/Instant.kt": "package kotlinx.datetime\n\npublic class Instant {\n    fun now(): Instant = Instant()\n}\n",
+	}); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	jvmJarPath := filepath.Join(dir, "kotlinx-datetime-jvm-sources.jar")
+	if err := writeTestJarFiles(jvmJarPath, map[string]string{
+		"jvmMain/kotlinx/datetime/InstantJvm.kt": "package kotlinx.datetime\n",
+	}); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	t.Setenv("KSRC_TEST_JAR", jarPath)
+	t.Setenv("KSRC_TEST_JVM_JAR", jvmJarPath)
+
+	fileID := "org.jetbrains.kotlinx:kotlinx-datetime:0.6.1!/commonMain/kotlinx/datetime/Instant.kt"
+	input := `{"id":1,"op":"cat","fileId":"` + fileID + `","lines":"3,4"}
+{"id":2,"op":"class","fqn":"kotlinx.datetime.Instant"}
+
+not json
+{"id":4,"op":"search","q":"now"}
+{"id":5,"op":"ls","module":"org.jetbrains.kotlinx:kotlinx-datetime"}
+{"id":6,"op":"where","coord":"org.jetbrains.kotlinx:kotlinx-datetime"}
+{"id":7,"op":"where","coord":"org.jetbrains.kotlinx:kotlinx-datetime*"}
+{"id":8,"op":"nope"}
+`
+	resps, lines := runBatch(t, app, projectDir, input, 8)
+
+	cat, _ := resps[0]["result"].(map[string]any)
+	if cat["text"] != "public class Instant {\n    fun now(): Instant = Instant()\n" || cat["fileId"] != fileID {
+		t.Fatalf("unexpected cat response: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], `"line":3`) || !strings.Contains(lines[1], "public class Instant") {
+		t.Fatalf("unexpected class response: %s", lines[1])
+	}
+	if !strings.Contains(resps[2]["error"].(string), "invalid request") {
+		t.Fatalf("expected invalid request error, got %s", lines[2])
+	}
+	if !strings.Contains(resps[3]["error"].(string), "E_NO_MODULE") {
+		t.Fatalf("expected E_NO_MODULE error, got %s", lines[3])
+	}
+	if !strings.Contains(lines[4], `"lines":5`) {
+		t.Fatalf("unexpected ls response: %s", lines[4])
+	}
+	if !strings.Contains(lines[5], jarPath) {
+		t.Fatalf("unexpected where response: %s", lines[5])
+	}
+	if errMsg, _ := resps[6]["error"].(string); !strings.Contains(errMsg, "E_AMBIGUOUS") || !strings.Contains(errMsg, "kotlinx-datetime-jvm:0.6.1") {
+		t.Fatalf("expected E_AMBIGUOUS error, got %s", lines[6])
+	}
+	if !strings.Contains(resps[7]["error"].(string), "unknown op") {
+		t.Fatalf("expected unknown op error, got %s", lines[7])
+	}
+
+	t.Run("search", func(t *testing.T) {
+		if _, err := app.Runner.LookPath("rg"); err != nil {
+			t.Skip("rg not available")
+		}
+		input := `{"id":"s","op":"search","module":"org.jetbrains.kotlinx:kotlinx-datetime","q":"fun now"}
+`
+		resps, lines := runBatch(t, app, projectDir, input, 1)
+		if resps[0]["id"] != "s" || !strings.Contains(lines[0], `"line":4`) {
+			t.Fatalf("unexpected search response: %s", lines[0])
+		}
+	})
+}
+
+// runBatch feeds input to ksrc batch and decodes want response lines.
+func runBatch(t *testing.T, app *App, projectDir, input string, want int) ([]map[string]any, []string) {
+	t.Helper()
+	out, err := runCommandWithInput(app, []string{"batch", "--project", projectDir}, input)
+	if err != nil {
+		t.Fatalf("batch error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != want {
+		t.Fatalf("expected %d responses, got %d:\n%s", want, len(lines), out)
+	}
+	var resps []map[string]any
+	for _, line := range lines {
+		var r map[string]any
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("invalid json %q: %v", line, err)
+		}
+		resps = append(resps, r)
+	}
+	return resps, lines
+}
+
+func TestActualsIntegration(t *testing.T) {
+	app := NewApp()
+	projectDir := filepath.Clean(filepath.Join("..", "..", "testdata", "fixture"))
+	dir := t.TempDir()
+	jarPath := filepath.Join(dir, "kotlinx-datetime-sources.jar")
+	if err := writeTestJarFiles(jarPath, map[string]string{
+		"commonMain/kotlinx/datetime/TimeZone.kt": "package kotlinx.datetime\n\npublic expect open class TimeZone {\n    public val id: String\n}\n",
+		"jsMain/kotlinx/datetime/TimeZone.kt":     "package kotlinx.datetime\n\npublic actual open class TimeZone {\n    public actual val id: String = \"UTC\"\n}\n",
+	}); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	jvmJarPath := filepath.Join(dir, "kotlinx-datetime-jvm-sources.jar")
+	if err := writeTestJarFiles(jvmJarPath, map[string]string{
+		"jvmMain/kotlinx/datetime/TimeZoneJvm.kt": "package kotlinx.datetime\n\nimport java.time.ZoneId\n\npublic actual open class TimeZone(internal val zoneId: ZoneId) {\n    public actual val id: String get() = zoneId.id\n}\n",
+	}); err != nil {
+		t.Fatalf("write jar: %v", err)
+	}
+	t.Setenv("KSRC_TEST_JAR", jarPath)
+	t.Setenv("KSRC_TEST_JVM_JAR", jvmJarPath)
+
+	common := "org.jetbrains.kotlinx:kotlinx-datetime:0.6.1!/"
+	jvm := "org.jetbrains.kotlinx:kotlinx-datetime-jvm:0.6.1!/"
+	want := "commonMain " + common + "commonMain/kotlinx/datetime/TimeZone.kt 3:public expect open class TimeZone\n" +
+		"jsMain " + common + "jsMain/kotlinx/datetime/TimeZone.kt 3:public actual open class TimeZone\n" +
+		"jvmMain " + jvm + "jvmMain/kotlinx/datetime/TimeZoneJvm.kt 5:public actual open class TimeZone(internal val zoneId: ZoneId)\n"
+
+	out, err := runCommand(app, []string{"actuals", "TimeZone", "org.jetbrains.kotlinx:kotlinx-datetime", "--project", projectDir})
+	if err != nil {
+		t.Fatalf("actuals error: %v", err)
+	}
+	if out != want {
+		t.Fatalf("unexpected actuals output:\n%s", out)
+	}
+
+	// Reverse lookup from a member of the jvm actual.
+	out, err = runCommand(app, []string{"actuals", jvm + "jvmMain/kotlinx/datetime/TimeZoneJvm.kt:6", "--project", projectDir})
+	if err != nil {
+		t.Fatalf("actuals reverse error: %v", err)
+	}
+	for _, line := range []string{
+		"commonMain " + common + "commonMain/kotlinx/datetime/TimeZone.kt 4:public val id: String",
+		"jvmMain " + jvm + "jvmMain/kotlinx/datetime/TimeZoneJvm.kt 6:public actual val id: String",
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Fatalf("reverse lookup missing %q:\n%s", line, out)
+		}
+	}
+	if !strings.HasPrefix(out, "commonMain ") {
+		t.Fatalf("expect should come first:\n%s", out)
+	}
+
+	if _, err := runCommand(app, []string{"actuals", "TimeZone", "org.jetbrains.kotlinx:kotlinx-datetime-jvm", "--project", projectDir}); err != nil {
+		t.Fatalf("actuals for platform module error: %v", err)
+	}
+	if _, err := runCommand(app, []string{"actuals", "Missing", "--project", projectDir}); err == nil {
+		t.Fatal("expected error for unknown symbol")
+	}
+}
+
 func writeTestJar(path, inner, content string) error {
 	f, err := os.Create(path)
 	if err != nil {
@@ -130,3 +1346,34 @@ func writeTestJar(path, inner, content string) error {
 	}
 	return f.Close()
 }
+
+func writeTestJarFiles(path string, files map[string]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	zw := zip.NewWriter(f)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			_ = zw.Close()
+			_ = f.Close()
+			return err
+		}
+		if _, err := w.Write([]byte(files[name])); err != nil {
+			_ = zw.Close()
+			_ = f.Close()
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/respawn-app/ksrc/internal/ls"
+	"github.com/respawn-app/ksrc/internal/search"
+	"github.com/respawn-app/ksrc/internal/srcjar"
+	"github.com/spf13/cobra"
+)
+
+func newLsCmd(app *App) *cobra.Command {
+	var flags ResolveFlags
+	var glob string
+	var tree bool
+	var find string
+	var maxResults int
+
+	cmd := &cobra.Command{
+		Use:   "ls [<module>]",
+		Short: "List source files inside dependency source jars",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				if flags.Module != "" && flags.Module != args[0] {
+					return fmt.Errorf("module specified twice (arg and --module). Use only one.")
+				}
+				flags.Module = args[0]
+			}
+			find = strings.TrimSpace(find)
+			if flags.Module == "" && !flags.All && find == "" {
+				return fmt.Errorf("E_NO_MODULE: <module> required unless --all or --find is provided. Try: ksrc ls group:artifact or ksrc ls --find FlowKt")
+			}
+			if find != "" && flags.Module == "" {
+				flags.All = true
+			}
+
+			sources, _, meta, err := resolveSources(cmd.Context(), app, flags, "", true, true)
+			if err != nil {
+				return err
+			}
+			emitWarnings(cmd, meta)
+			if len(sources) == 0 {
+				return noSourcesErr(flags, noSourcesHintForFlags(flags, meta))
+			}
+
+			var keep func(string) bool
+			if globs := splitCSV(glob); len(globs) > 0 {
+				keep = search.Filter{Paths: globs}.KeepPath
+			}
+			archives := srcjar.NewArchives()
+			defer archives.Close()
+			files, err := ls.List(archives, sources, keep)
+			if err != nil {
+				return err
+			}
+			if find != "" {
+				files = ls.Find(files, find)
+			}
+			if maxResults > 0 && len(files) > maxResults {
+				fmt.Fprintf(cmd.ErrOrStderr(), "showing %d of %d files (--max-results %d)\n", maxResults, len(files), maxResults)
+				files = files[:maxResults]
+			}
+			if len(files) == 0 {
+				return fmt.Errorf("no source files matched. Try: a broader --glob or --find pattern")
+			}
+			if err := ls.CountLines(files); err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			if tree {
+				ls.WriteTree(out, files)
+				return nil
+			}
+			for _, f := range files {
+				fmt.Fprintf(out, "%s  [lines: %d]  [bytes: %d]\n", f.FileID(), f.Lines, f.Bytes)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&glob, "glob", "", "only paths matching these globs; ** spans directories (comma-separated)")
+	cmd.Flags().BoolVar(&tree, "tree", false, "print a directory tree with file counts and line totals")
+	cmd.Flags().StringVar(&find, "find", "", "fuzzy-match file paths, best first (e.g. FlowKt, flow/Channel)")
+	cmd.Flags().IntVar(&maxResults, "max-results", 0, "limit output to N files (0 = no limit)")
+	cmd.Flags().StringVar(&flags.Project, "project", ".", "project root")
+	cmd.Flags().BoolVar(&flags.All, "all", false, "list files of all resolved dependencies")
+	cmd.Flags().StringVar(&flags.Module, "module", "", "module selector (group:artifact[:version])")
+	cmd.Flags().StringVar(&flags.Group, "group", "", "group filter")
+	cmd.Flags().StringVar(&flags.Artifact, "artifact", "", "artifact filter")
+	cmd.Flags().StringVar(&flags.Version, "version", "", "version filter")
+	cmd.Flags().StringVar(&flags.Scope, "scope", "compile", "dependency scope (compile|runtime|test|all)")
+	cmd.Flags().StringVar(&flags.Config, "config", "", "configuration name(s) or glob patterns (comma-separated)")
+	cmd.Flags().StringVar(&flags.Targets, "targets", "", "KMP targets (comma-separated)")
+	cmd.Flags().StringSliceVar(&flags.Subprojects, "subproject", nil, "limit to subproject (repeatable)")
+	cmd.Flags().BoolVar(&flags.Offline, "offline", false, "offline mode")
+	cmd.Flags().BoolVar(&flags.Refresh, "refresh", false, "refresh dependencies")
+	cmd.Flags().BoolVar(&flags.IncludeBuildSrc, "buildsrc", true, "include buildSrc dependencies (set --buildsrc=false to disable)")
+	cmd.Flags().BoolVar(&flags.IncludeBuildscript, "buildscript", true, "include buildscript classpath dependencies (set --buildscript=false to disable)")
+	cmd.Flags().BoolVar(&flags.IncludeIncludedBuilds, "include-builds", true, "include composite builds (includeBuild) (set --include-builds=false to disable)")
+
+	return cmd
+}
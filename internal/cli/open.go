@@ -1,73 +1,48 @@
 package cli
 
 import (
-	"context"
-	"fmt"
+	"bytes"
 	"os"
 	"os/exec"
-	"strings"
 
-	"github.com/respawn-app/ksrc/internal/cat"
-	"github.com/respawn-app/ksrc/internal/resolve"
+	"github.com/respawn-app/ksrc/internal/srcjar"
 	"github.com/spf13/cobra"
 )
 
 func newOpenCmd(app *App) *cobra.Command {
 	var flags ResolveFlags
-	var lines string
+	var lines []string
+	var around int
+	var context int
+	var numbers bool
 
 	cmd := &cobra.Command{
 		Use:   "open <file-id|path>",
 		Short: "Open a file in $PAGER",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			arg := strings.TrimSpace(args[0])
-			lr, err := cat.ParseLineRange(lines)
+			targets, err := catTargets(args, lines, around, context)
 			if err != nil {
 				return err
 			}
-
-			var data []byte
-			if strings.Contains(arg, "!/") {
-				coord, inner, err := resolve.ParseFileID(arg)
-				if err != nil {
-					return err
-				}
-				flags.Module = coord.String()
-				flags.Version = coord.Version
-				sources, _, _, err := resolveSources(context.Background(), app, flags, "", true, false)
-				if err != nil {
-					return err
-				}
-				if len(sources) == 0 {
-					return noSourcesErr(flags, noSourcesHintForCoord(coord))
-				}
-				jarPath, err := findJarByCoord(sources, coord)
-				if err != nil {
-					return err
-				}
-				data, err = cat.ReadFileFromZip(jarPath, inner, lr)
-				if err != nil {
-					return err
-				}
-			} else {
-				if flags.Module == "" && flags.Group == "" && flags.Artifact == "" {
-					return fmt.Errorf("path requires --module or a file-id. Try: ksrc open <file-id> or ksrc open --module group:artifact[:version] <path>")
-				}
-				sources, _, meta, err := resolveSources(context.Background(), app, flags, "", true, true)
+			sources, err := catSources(cmd, app, flags, targets)
+			if err != nil {
+				return err
+			}
+			archives := srcjar.NewArchives()
+			defer archives.Close()
+			var buf bytes.Buffer
+			p := &catPrinter{out: &buf, headers: len(targets) > 1, numbers: numbers}
+			for _, t := range targets {
+				entry, err := catEntry(archives, sources, flags, t.File)
 				if err != nil {
 					return err
 				}
-				emitWarnings(cmd, meta)
-				if len(sources) == 0 {
-					return noSourcesErr(flags, noSourcesHintForFlags(flags, meta))
-				}
-				jarPath, inner, err := findFileInJars(sources, arg)
+				data, err := entry.Read()
 				if err != nil {
 					return err
 				}
-				data, err = cat.ReadFileFromZip(jarPath, inner, lr)
-				if err != nil {
+				if err := p.print(entry, data, t.Range); err != nil {
 					return err
 				}
 			}
@@ -77,7 +52,7 @@ func newOpenCmd(app *App) *cobra.Command {
 				pager = "less -R"
 			}
 			cmdExec := exec.Command("sh", "-c", pager)
-			cmdExec.Stdin = strings.NewReader(string(data))
+			cmdExec.Stdin = &buf
 			cmdExec.Stdout = cmd.OutOrStdout()
 			cmdExec.Stderr = cmd.ErrOrStderr()
 			return cmdExec.Run()
@@ -98,7 +73,10 @@ func newOpenCmd(app *App) *cobra.Command {
 	cmd.Flags().BoolVar(&flags.IncludeBuildSrc, "buildsrc", true, "include buildSrc dependencies (set --buildsrc=false to disable)")
 	cmd.Flags().BoolVar(&flags.IncludeBuildscript, "buildscript", true, "include buildscript classpath dependencies (set --buildscript=false to disable)")
 	cmd.Flags().BoolVar(&flags.IncludeIncludedBuilds, "include-builds", true, "include composite builds (includeBuild) (set --include-builds=false to disable)")
-	cmd.Flags().StringVar(&lines, "lines", "", "line range (start,end)")
+	cmd.Flags().StringArrayVar(&lines, "lines", nil, "line range: start,end | start,+count | start: | -count (repeatable)")
+	cmd.Flags().IntVar(&around, "around", 0, "open the lines around this line (see --context)")
+	cmd.Flags().IntVarP(&context, "context", "C", 5, "lines around --around")
+	cmd.Flags().BoolVarP(&numbers, "line-numbers", "n", false, "prefix lines with their line numbers in the original file")
 
 	return cmd
 }
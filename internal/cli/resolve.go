@@ -1,7 +1,6 @@
 package cli
 
 import (
-	"context"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -14,7 +13,7 @@ func newResolveCmd(app *App) *cobra.Command {
 		Use:   "resolve",
 		Short: "Resolve dependency sources",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			sources, _, meta, err := resolveSources(context.Background(), app, flags, "", true, true)
+			sources, _, meta, err := resolveSources(cmd.Context(), app, flags, "", true, true)
 			if err != nil {
 				return err
 			}
@@ -1,10 +1,14 @@
 package cli
 
 import (
+	"github.com/respawn-app/ksrc/internal/budget"
 	"github.com/spf13/cobra"
 )
 
 func NewRootCommand(app *App) *cobra.Command {
+	var budgetValue string
+	report := func() error { return nil }
+
 	cmd := &cobra.Command{
 		Use:   "ksrc",
 		Short: "Kotlin dependency source search",
@@ -16,15 +20,37 @@ func NewRootCommand(app *App) *cobra.Command {
 			"If E_NO_SOURCES: try --project <root>, --config \"*debugCompileClasspath\", or --subproject :module.",
 		SilenceUsage:  true,
 		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			if app.Budget, err = budget.Parse(budgetValue); err != nil {
+				return err
+			}
+			report = limitOutput(cmd, app.Budget)
+			return nil
+		},
+		PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+			return report()
+		},
 	}
+	cmd.PersistentFlags().StringVar(&budgetValue, "budget", "", "cap output size: tokens (2000, 8k) or bytes (16KB); truncation is reported")
 
 	cmd.AddCommand(newSearchCmd(app))
 	cmd.AddCommand(newCatCmd(app))
+	cmd.AddCommand(newLsCmd(app))
+	cmd.AddCommand(newClassCmd(app))
 	cmd.AddCommand(newOpenCmd(app))
 	cmd.AddCommand(newDepsCmd(app))
 	cmd.AddCommand(newResolveCmd(app))
 	cmd.AddCommand(newFetchCmd(app))
 	cmd.AddCommand(newWhereCmd(app))
+	cmd.AddCommand(newExtensionsCmd(app))
+	cmd.AddCommand(newAnnotatedCmd(app))
+	cmd.AddCommand(newImportsCmd(app))
+	cmd.AddCommand(newGotoCmd(app))
+	cmd.AddCommand(newDocCmd(app))
+	cmd.AddCommand(newActualsCmd(app))
+	cmd.AddCommand(newIndexCmd(app))
+	cmd.AddCommand(newBatchCmd(app))
 	cmd.AddCommand(newDoctorCmd(app))
 
 	return cmd
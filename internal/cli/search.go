@@ -1,26 +1,51 @@
 package cli
 
 import (
-	"context"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 
+	"github.com/respawn-app/ksrc/internal/budget"
+	"github.com/respawn-app/ksrc/internal/extract"
+	"github.com/respawn-app/ksrc/internal/index"
+	"github.com/respawn-app/ksrc/internal/resolve"
 	"github.com/respawn-app/ksrc/internal/search"
+	"github.com/respawn-app/ksrc/internal/srcjar"
 	"github.com/spf13/cobra"
 )
 
 func newSearchCmd(app *App) *cobra.Command {
 	var flags ResolveFlags
-	var query string
+	var queries []string
+	var and, or bool
+	var not []string
+	var per string
+	var fixedStrings, word bool
 	var rgArgs string
 	var showExtractedPath bool
 	var contextLines int
+	var enclosing bool
+	var rank bool
+	var maxResults int
+	var jsonOut bool
+	var packages string
+	var sourceSets string
+	var paths string
+	var in string
+	var noIndex bool
+	var jobs int
+	var withDeps bool
+	var depsDepth int
 
 	cmd := &cobra.Command{
-		Use:     "search [<module>] [-- <rg-args>]",
-		Aliases: []string{"rg"},
-		Short:   "Search dependency sources",
+		Use:         "search [<module>] [-- <rg-args>]",
+		Aliases:     []string{"rg"},
+		Short:       "Search dependency sources",
+		Annotations: map[string]string{ownsBudget: "matches"},
 		Args: func(cmd *cobra.Command, args []string) error {
 			dash := cmd.Flags().ArgsLenAtDash()
 			if dash == -1 {
@@ -55,10 +80,28 @@ func newSearchCmd(app *App) *cobra.Command {
 			if err := requireModuleOrAll(flags.Module, flags.All); err != nil {
 				return err
 			}
-			if strings.TrimSpace(query) == "" {
-				return fmt.Errorf("query is required. Try: ksrc search --all -q \"<pattern>\"")
+			switch {
+			case depsDepth < 0:
+				return fmt.Errorf("invalid --deps-depth %d. Try: --deps-depth 1 for direct dependencies only", depsDepth)
+			case depsDepth > 0 && !withDeps:
+				return fmt.Errorf("--deps-depth requires --with-deps. Try: --with-deps --deps-depth %d", depsDepth)
+			case withDeps && depsDepth > 0:
+				flags.WithDeps = depsDepth
+			case withDeps:
+				flags.WithDeps = -1
 			}
-			ctx := context.Background()
+			if jobs < 0 {
+				return fmt.Errorf("invalid --jobs %d. Try: --jobs 4, or 0 for one per CPU", jobs)
+			}
+			query, err := buildQuery(queries, and, or, not, per, fixedStrings, word)
+			if err != nil {
+				return err
+			}
+			regions, err := search.ParseRegions(splitCSV(in))
+			if err != nil {
+				return err
+			}
+			ctx := cmd.Context()
 			sources, _, meta, err := resolveSources(ctx, app, flags, "", true, true)
 			if err != nil {
 				return err
@@ -72,27 +115,86 @@ func newSearchCmd(app *App) *cobra.Command {
 				rgExtra = append(rgExtra, "-C", strconv.Itoa(contextLines))
 			}
 			rgExtra = append(rgExtra, passArgs...)
-			matches, err := search.Run(ctx, app.Runner, search.Options{
-				Pattern: query,
+			opts := search.Options{
+				Query:   query,
 				Jars:    sources,
 				RGArgs:  rgExtra,
 				WorkDir: flags.Project,
-			})
-			if err != nil {
-				return err
+				Filter: search.Filter{
+					Packages:   splitCSV(packages),
+					SourceSets: splitCSV(sourceSets),
+					Paths:      splitCSV(paths),
+				},
+				In:       regions,
+				Classify: jsonOut,
+				Jobs:     jobs,
+				Extracts: extract.DefaultCache,
 			}
-			for _, m := range matches {
-				if showExtractedPath {
-					fmt.Fprintf(cmd.OutOrStdout(), "%s %s:%d:%d:%s\n", m.FileID, m.File, m.Line, m.Column, m.Text)
-					continue
+			if !noIndex {
+				if store, err := index.DefaultStore(); err == nil {
+					opts.Index = store
 				}
-				fmt.Fprintf(cmd.OutOrStdout(), "%s %d:%d:%s\n", m.FileID, m.Line, m.Column, m.Text)
 			}
-			return nil
+			w := &matchWriter{
+				out:      cmd.OutOrStdout(),
+				json:     jsonOut,
+				showPath: showExtractedPath,
+				blocks:   search.HasContext(rgExtra),
+				budget:   app.Budget,
+			}
+			if enclosing {
+				archives := srcjar.NewArchives()
+				defer archives.Close()
+				w.encloser = search.NewEncloser(archives, sources)
+			}
+
+			if rank {
+				// Ranking needs every match before the first one can be printed.
+				matches, err := search.Run(ctx, app.Runner, opts)
+				if err != nil {
+					return err
+				}
+				matches = search.Rank(matches, search.RankOptions{Direct: directDeps(meta.Direct)})
+				var total int
+				matches, total = search.Limit(matches, maxResults)
+				if maxResults > 0 && total > maxResults {
+					fmt.Fprintf(cmd.ErrOrStderr(), "showing %d of %d matches (--max-results %d)\n", maxResults, total, maxResults)
+				}
+				w.buffered = true
+				for _, m := range matches {
+					if err := w.Write(m); err != nil {
+						return err
+					}
+				}
+				if err := w.Flush(); err != nil {
+					return err
+				}
+				return w.reportTruncation()
+			}
+
+			opts.MaxResults = maxResults
+			err = search.Stream(ctx, app.Runner, opts, w.Write)
+			limited := errors.Is(err, search.ErrLimitReached)
+			if err != nil && !limited {
+				return err
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			if limited {
+				fmt.Fprintf(cmd.ErrOrStderr(), "showing first %d matches (--max-results %d)\n", maxResults, maxResults)
+			}
+			return w.reportTruncation()
 		},
 	}
 
-	cmd.Flags().StringVarP(&query, "query", "q", "", "search query (required)")
+	cmd.Flags().StringArrayVarP(&queries, "query", "q", nil, "search pattern (required; repeatable, any may match unless --and)")
+	cmd.Flags().BoolVar(&and, "and", false, "require every -q pattern to match (see --per)")
+	cmd.Flags().BoolVar(&or, "or", false, "match any -q pattern (default)")
+	cmd.Flags().StringArrayVar(&not, "not", nil, "exclude files (or lines, see --per) matching this pattern (repeatable)")
+	cmd.Flags().StringVar(&per, "per", "file", "evaluate --and/--not per file or per line (file|line)")
+	cmd.Flags().BoolVarP(&fixedStrings, "fixed-strings", "F", false, "treat patterns as literal strings (rg -F)")
+	cmd.Flags().BoolVarP(&word, "word", "w", false, "only match whole words (rg -w)")
 	cmd.Flags().StringVar(&flags.Project, "project", ".", "project root")
 	cmd.Flags().BoolVar(&flags.All, "all", false, "search all resolved dependencies")
 	cmd.Flags().StringVar(&flags.Module, "module", "", "module selector (group:artifact[:version])")
@@ -108,9 +210,269 @@ func newSearchCmd(app *App) *cobra.Command {
 	cmd.Flags().BoolVar(&flags.IncludeBuildSrc, "buildsrc", true, "include buildSrc dependencies (set --buildsrc=false to disable)")
 	cmd.Flags().BoolVar(&flags.IncludeBuildscript, "buildscript", true, "include buildscript classpath dependencies (set --buildscript=false to disable)")
 	cmd.Flags().BoolVar(&flags.IncludeIncludedBuilds, "include-builds", true, "include composite builds (includeBuild) (set --include-builds=false to disable)")
+	cmd.Flags().BoolVar(&withDeps, "with-deps", false, "also search the module's transitive dependencies")
+	cmd.Flags().IntVar(&depsDepth, "deps-depth", 0, "limit --with-deps to N levels of dependencies (1 = direct deps only, 0 = no limit)")
 	cmd.Flags().StringVar(&rgArgs, "rg-args", "", "extra args for rg (comma-separated)")
-	cmd.Flags().BoolVar(&showExtractedPath, "show-extracted-path", false, "include temp extracted path in output")
+	cmd.Flags().BoolVar(&showExtractedPath, "show-extracted-path", false, "include the path of the extracted file (stable, in the ksrc cache) in output")
 	cmd.Flags().IntVar(&contextLines, "context", 0, "show N lines before/after matches (rg -C)")
+	cmd.Flags().StringVar(&packages, "package", "", "only files in these packages or their subpackages (comma-separated)")
+	cmd.Flags().StringVar(&sourceSets, "source-set", "", "only files in these source sets, e.g. commonMain,jvmMain (comma-separated)")
+	cmd.Flags().StringVar(&paths, "path", "", "only files whose in-jar path matches these globs; ** spans directories (comma-separated)")
+	cmd.Flags().StringVar(&in, "in", "", "only matches in code, comments or strings (comma-separated)")
+	cmd.Flags().IntVar(&jobs, "jobs", 0, "number of jars searched in parallel (0 = one per CPU)")
+	cmd.Flags().BoolVar(&noIndex, "no-index", false, "do not use or build trigram indexes (see ksrc index)")
+	cmd.Flags().BoolVar(&rank, "rank", false, "order matches by relevance (declarations, public API, commonMain, direct deps first)")
+	cmd.Flags().IntVar(&maxResults, "max-results", 0, "limit output to N matches (0 = no limit)")
+	cmd.Flags().BoolVar(&enclosing, "enclosing", false, "annotate matches with the enclosing class and function")
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "output matches as JSON lines")
 
 	return cmd
 }
+
+// directDeps reports whether a module is a direct dependency. KMP platform
+// artifacts (foo-jvm) count as direct when their root module (foo) is. Without
+// direct dependency information every module is treated as direct.
+func directDeps(direct []resolve.Coord) func(resolve.Coord) bool {
+	if len(direct) == 0 {
+		return nil
+	}
+	return func(c resolve.Coord) bool {
+		for _, d := range direct {
+			if d.Group != c.Group {
+				continue
+			}
+			if d.Artifact == c.Artifact || strings.HasPrefix(c.Artifact, d.Artifact+"-") {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// buildQuery validates the pattern flags of search.
+func buildQuery(patterns []string, and, or bool, not []string, per string, fixedStrings, word bool) (search.Query, error) {
+	q := search.Query{All: and, FixedStrings: fixedStrings, Word: word}
+	for _, p := range patterns {
+		if strings.TrimSpace(p) != "" {
+			q.Patterns = append(q.Patterns, p)
+		}
+	}
+	if len(q.Patterns) == 0 {
+		return q, fmt.Errorf("query is required. Try: ksrc search --all -q \"<pattern>\"")
+	}
+	if and && or {
+		return q, fmt.Errorf("--and and --or are mutually exclusive. Try: ksrc search --all -q \"suspend fun\" -q \"Flow<\" --and")
+	}
+	for _, p := range not {
+		if strings.TrimSpace(p) != "" {
+			q.Not = append(q.Not, p)
+		}
+	}
+	switch per {
+	case "file":
+	case "line":
+		q.PerLine = true
+	default:
+		return q, fmt.Errorf("invalid --per %q. Try: --per file or --per line", per)
+	}
+	return q, nil
+}
+
+// matchWriter prints matches as they arrive. Without context each match is a
+// line (or JSON object). With context, matches are grouped into blocks, flushed
+// whenever ripgrep moves on to another file, or only at the end when buffered.
+type matchWriter struct {
+	out      io.Writer
+	json     bool
+	showPath bool
+	blocks   bool
+	buffered bool
+	encloser *search.Encloser
+	pending  []search.Match
+	written  int
+	budget   budget.Budget
+	used     int
+	omitted  *omissions
+}
+
+func (w *matchWriter) Write(m search.Match) error {
+	if w.encloser != nil && !m.Context {
+		if err := w.encloser.Annotate(&m); err != nil {
+			return err
+		}
+	}
+	if !w.showPath {
+		m.File = ""
+	}
+	if !w.blocks {
+		return w.writeMatch(m)
+	}
+	if !w.buffered && len(w.pending) > 0 && w.pending[0].FileID != m.FileID {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	w.pending = append(w.pending, m)
+	return nil
+}
+
+// Flush prints pending blocks.
+func (w *matchWriter) Flush() error {
+	blocks := search.Blocks(w.pending)
+	w.pending = nil
+	for _, b := range blocks {
+		if err := w.writeBlock(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *matchWriter) writeMatch(m search.Match) error {
+	var buf bytes.Buffer
+	if w.json {
+		json.NewEncoder(&buf).Encode(m)
+	} else {
+		text := m.Text
+		if w.encloser != nil {
+			text += "\t" + formatEnclosing(m.Class, m.Function)
+		}
+		if w.showPath {
+			fmt.Fprintf(&buf, "%s %s:%d:%d:%s\n", m.FileID, m.File, m.Line, m.Column, text)
+		} else {
+			fmt.Fprintf(&buf, "%s %d:%d:%s\n", m.FileID, m.Line, m.Column, text)
+		}
+	}
+	hits := 1
+	if m.Context {
+		hits = 0
+	}
+	return w.emit(m.FileID, hits, buf.Bytes())
+}
+
+// writeBlock prints a block once: a "<file-id> <start>-<end>" header, then
+// hits as "<line>:<col>:<text>" and context as "<line>-<text>" (grep style),
+// with a blank line between blocks.
+func (w *matchWriter) writeBlock(b search.Block) error {
+	hits := 0
+	for _, line := range b.Lines {
+		if line.Hit {
+			hits++
+		}
+	}
+	var buf bytes.Buffer
+	if w.json {
+		json.NewEncoder(&buf).Encode(b)
+		return w.emit(b.FileID, hits, buf.Bytes())
+	}
+	if w.written > 0 {
+		fmt.Fprintln(&buf)
+	}
+	if w.showPath {
+		fmt.Fprintf(&buf, "%s %s:%d-%d\n", b.FileID, b.File, b.StartLine, b.EndLine)
+	} else {
+		fmt.Fprintf(&buf, "%s %d-%d\n", b.FileID, b.StartLine, b.EndLine)
+	}
+	for _, line := range b.Lines {
+		if !line.Hit {
+			fmt.Fprintf(&buf, "%d-%s\n", line.Line, line.Text)
+			continue
+		}
+		text := line.Text
+		if w.encloser != nil {
+			text += "\t" + formatEnclosing(line.Class, line.Function)
+		}
+		fmt.Fprintf(&buf, "%d:%d:%s\n", line.Line, line.Column, text)
+	}
+	return w.emit(b.FileID, hits, buf.Bytes())
+}
+
+// emit writes the rendered output of a match or block. Under --budget, once
+// one does not fit, it and everything after it are only counted per module.
+func (w *matchWriter) emit(fileID string, hits int, data []byte) error {
+	if w.budget.Enabled() {
+		if w.omitted == nil && len(data) > w.budget.Bytes()-w.used {
+			w.omitted = &omissions{}
+		}
+		if w.omitted != nil {
+			w.omitted.add(fileID, hits)
+			return nil
+		}
+		w.used += len(data)
+	}
+	w.written++
+	_, err := w.out.Write(data)
+	return err
+}
+
+// reportTruncation tells the reader what --budget left out, per module, so it
+// can narrow the search.
+func (w *matchWriter) reportTruncation() error {
+	if w.omitted == nil {
+		return nil
+	}
+	if w.json {
+		return json.NewEncoder(w.out).Encode(map[string]any{"truncated": map[string]any{
+			"budget":  w.budget.String(),
+			"omitted": w.omitted.modules,
+		}})
+	}
+	o := w.omitted
+	writeTruncated(w.out, w.budget, fmt.Sprintf("omitted %d matches in %d files", o.matches, o.files))
+	for _, m := range o.modules {
+		fmt.Fprintf(w.out, "  %s  [matches: %d]  [files: %d]\n", m.Module, m.Matches, m.Files)
+	}
+	_, err := fmt.Fprintln(w.out, "Try: narrow the search (a module, --path, --package, --in code, --max-results) or raise --budget")
+	return err
+}
+
+// omissions counts matches dropped by --budget.
+type omissions struct {
+	modules  []*omittedModule
+	matches  int
+	files    int
+	seen     map[string]bool
+	byModule map[string]*omittedModule
+}
+
+type omittedModule struct {
+	Module  string `json:"module"`
+	Matches int    `json:"matches"`
+	Files   int    `json:"files"`
+}
+
+func (o *omissions) add(fileID string, hits int) {
+	if o.seen == nil {
+		o.seen = make(map[string]bool)
+		o.byModule = make(map[string]*omittedModule)
+	}
+	module, _, _ := strings.Cut(fileID, "!/")
+	m, ok := o.byModule[module]
+	if !ok {
+		m = &omittedModule{Module: module}
+		o.byModule[module] = m
+		o.modules = append(o.modules, m)
+	}
+	m.Matches += hits
+	o.matches += hits
+	if !o.seen[fileID] {
+		o.seen[fileID] = true
+		m.Files++
+		o.files++
+	}
+}
+
+// formatEnclosing renders the enclosing column: "<class> [start-end] | <function> [start-end]",
+// omitting missing scopes, or "-" for top-level matches.
+func formatEnclosing(class, function *search.Scope) string {
+	var parts []string
+	for _, scope := range []*search.Scope{class, function} {
+		if scope != nil {
+			parts = append(parts, fmt.Sprintf("%s [%d-%d]", scope.Signature, scope.StartLine, scope.EndLine))
+		}
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, " | ")
+}
@@ -1,6 +1,23 @@
 package cli
 
-import "bytes"
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Keep indexes and other cache files of test runs out of the user's cache.
+	dir, err := os.MkdirTemp("", "ksrc-cache-")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("KSRC_CACHE_DIR", dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
 
 func runCommand(app *App, args []string) (string, error) {
 	cmd := NewRootCommand(app)
@@ -11,3 +28,14 @@ func runCommand(app *App, args []string) (string, error) {
 	err := cmd.Execute()
 	return out.String(), err
 }
+
+func runCommandWithInput(app *App, args []string, input string) (string, error) {
+	cmd := NewRootCommand(app)
+	cmd.SetArgs(args)
+	cmd.SetIn(strings.NewReader(input))
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	err := cmd.Execute()
+	return out.String(), err
+}
@@ -1,7 +1,6 @@
 package cli
 
 import (
-	"context"
 	"fmt"
 	"strings"
 
@@ -25,7 +24,7 @@ func newWhereCmd(app *App) *cobra.Command {
 				}
 				flags.Module = coord.String()
 				flags.Version = coord.Version
-				sources, _, _, err := resolveSources(context.Background(), app, flags, coord.String(), true, false)
+				sources, _, _, err := resolveSources(cmd.Context(), app, flags, coord.String(), true, false)
 				if err != nil {
 					return err
 				}
@@ -44,7 +43,7 @@ func newWhereCmd(app *App) *cobra.Command {
 				if coord.Version != "" {
 					dep = coord.String()
 				}
-				sources, _, meta, err := resolveSources(context.Background(), app, flags, dep, true, true)
+				sources, _, meta, err := resolveSources(cmd.Context(), app, flags, dep, true, true)
 				if err != nil {
 					return err
 				}
@@ -64,7 +63,7 @@ func newWhereCmd(app *App) *cobra.Command {
 				return fmt.Errorf("path requires --module or a file-id")
 			}
 
-			sources, _, meta, err := resolveSources(context.Background(), app, flags, "", true, true)
+			sources, _, meta, err := resolveSources(cmd.Context(), app, flags, "", true, true)
 			if err != nil {
 				return err
 			}
@@ -1,9 +1,12 @@
 package executil
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"io"
 	"os/exec"
+	"strings"
 )
 
 // Runner executes external commands.
@@ -12,6 +15,13 @@ type Runner interface {
 	LookPath(file string) (string, error)
 }
 
+// LineRunner is implemented by runners that can hand stdout to the caller line
+// by line while the command is still running. An error returned by onLine
+// kills the command and is returned as is.
+type LineRunner interface {
+	RunLines(ctx context.Context, dir string, name string, args []string, onLine func(line string) error) (stderr string, err error)
+}
+
 // OSRunner uses os/exec.
 type OSRunner struct{}
 
@@ -25,6 +35,50 @@ func (OSRunner) Run(ctx context.Context, dir string, name string, args ...string
 	return outBuf.String(), errBuf.String(), err
 }
 
+func (OSRunner) RunLines(ctx context.Context, dir string, name string, args []string, onLine func(line string) error) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	var errBuf bytes.Buffer
+	cmd.Stderr = &errBuf
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+
+	var cbErr error
+	reader := bufio.NewReaderSize(stdout, 64*1024)
+	for {
+		line, readErr := reader.ReadString('\n')
+		if line != "" {
+			if cbErr = onLine(strings.TrimRight(line, "\r\n")); cbErr != nil {
+				break
+			}
+		}
+		if readErr != nil {
+			if readErr != io.EOF {
+				cbErr = readErr
+			}
+			break
+		}
+	}
+	if cbErr != nil {
+		// Stop the command and let Wait reap it; its exit status is moot.
+		cancel()
+		_ = cmd.Wait()
+		return errBuf.String(), cbErr
+	}
+	err = cmd.Wait()
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return errBuf.String(), ctxErr
+	}
+	return errBuf.String(), err
+}
+
 func (OSRunner) LookPath(file string) (string, error) {
 	return exec.LookPath(file)
 }
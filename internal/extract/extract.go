@@ -0,0 +1,376 @@
+package extract
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/respawn-app/ksrc/internal/bytesize"
+	"github.com/respawn-app/ksrc/internal/cache"
+)
+
+// DefaultMaxBytes is the default size limit of the extraction cache.
+const DefaultMaxBytes = 2 << 30
+
+// Cache keeps source jars extracted below Dir, one directory per jar checksum,
+// so repeated searches reuse them and reported paths stay valid for editors.
+// Each directory has a sibling "<sum>.done" file recording its size; the
+// file's modification time is the last use. While a process uses a
+// directory, a "<sum>.use-<pid>" marker keeps it from being evicted.
+type Cache struct {
+	Dir string
+	// MaxBytes caps the total size of extracted files; the least recently
+	// used jars are evicted beyond it. 0 disables eviction.
+	MaxBytes int64
+
+	// locks serializes extractions of the same checksum within the process.
+	locks sync.Map
+	mu    sync.Mutex
+	// users counts the uses of each directory within the process.
+	users map[string]int
+}
+
+const (
+	// evictGrace is how long an extraction is kept after its last use even
+	// when the cache is over its limit, so printed paths stay valid.
+	evictGrace = time.Hour
+	// staleLock is the age after which the eviction lock of a process that
+	// did not remove it is ignored.
+	staleLock = 10 * time.Minute
+	// staleUse is the age after which a use marker of a process that did not
+	// remove it is ignored.
+	staleUse = 24 * time.Hour
+)
+
+// DefaultCache returns the cache below the ksrc cache directory. Its size
+// limit is read from $KSRC_EXTRACT_CACHE_SIZE (e.g. 500M, 4G).
+func DefaultCache() (*Cache, error) {
+	dir, err := cache.Dir()
+	if err != nil {
+		return nil, err
+	}
+	c := &Cache{Dir: filepath.Join(dir, "extract"), MaxBytes: DefaultMaxBytes}
+	if value := os.Getenv("KSRC_EXTRACT_CACHE_SIZE"); value != "" {
+		if c.MaxBytes, err = bytesize.Parse(value); err != nil {
+			return nil, fmt.Errorf("invalid KSRC_EXTRACT_CACHE_SIZE %q. Try: a byte count like 500M or 4G", value)
+		}
+	}
+	return c, nil
+}
+
+// Extract returns the directory holding the files of jar, extracting it on
+// first use, and marks it in use until Release. Jars with identical content
+// share a directory.
+func (c *Cache) Extract(jar string) (string, error) {
+	sum, err := c.checksum(jar)
+	if err != nil {
+		return "", err
+	}
+	lock, _ := c.locks.LoadOrStore(sum, new(sync.Mutex))
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	dir := filepath.Join(c.Dir, sum)
+	done := dir + ".done"
+	// Mark the use before looking at the done file, so Evict either sees the
+	// marker or has already removed the done file.
+	if err := c.acquire(dir); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	if err := os.Chtimes(done, now, now); err == nil {
+		return dir, nil
+	}
+
+	tmp, err := os.MkdirTemp(c.Dir, "tmp-")
+	if err != nil {
+		c.Release(dir)
+		return "", err
+	}
+	size, err := unzip(jar, tmp)
+	if err == nil {
+		var placed bool
+		if placed, err = place(tmp, dir, done); err == nil && placed {
+			err = os.WriteFile(done, []byte(strconv.FormatInt(size, 10)), 0o644)
+		}
+	}
+	_ = os.RemoveAll(tmp)
+	if err != nil {
+		c.Release(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// place renames the extraction tmp to dir and reports whether it did. A
+// directory already at dir was renamed there complete; it is used once its
+// done file exists, which another process writes right after renaming. One
+// that stays without a done file is left over from an interrupted run and is
+// moved aside rather than deleted in place.
+func place(tmp, dir, done string) (bool, error) {
+	err := os.Rename(tmp, dir)
+	for i := 0; err != nil && i < 10; i++ {
+		if _, statErr := os.Stat(done); statErr == nil {
+			return false, nil
+		}
+		time.Sleep(100 * time.Millisecond)
+		err = os.Rename(tmp, dir)
+	}
+	if err == nil {
+		return true, nil
+	}
+	if _, statErr := os.Stat(done); statErr == nil {
+		return false, nil
+	}
+	aside := filepath.Join(filepath.Dir(dir), fmt.Sprintf("tmp-stale-%s-%d", filepath.Base(dir), time.Now().UnixNano()))
+	if os.Rename(dir, aside) != nil {
+		return false, err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// acquire records a use of dir, creating the process's marker for the first.
+func (c *Cache) acquire(dir string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.users == nil {
+		c.users = make(map[string]int)
+	}
+	if c.users[dir] == 0 {
+		if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(c.marker(dir), nil, 0o644); err != nil {
+			return err
+		}
+	}
+	c.users[dir]++
+	return nil
+}
+
+// Release ends a use of dir started by Extract.
+func (c *Cache) Release(dir string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.users[dir] == 0 {
+		return
+	}
+	c.users[dir]--
+	if c.users[dir] == 0 {
+		delete(c.users, dir)
+		_ = os.Remove(c.marker(dir))
+	}
+}
+
+func (c *Cache) marker(dir string) string {
+	return fmt.Sprintf("%s.use-%d", dir, os.Getpid())
+}
+
+// checksum returns the hex SHA-256 prefix of the jar's content. Sums are
+// remembered per jar path, size and modification time so unchanged jars are
+// not read again.
+func (c *Cache) checksum(jar string) (string, error) {
+	info, err := os.Stat(jar)
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(jar)
+	if err != nil {
+		abs = jar
+	}
+	key := sha256.Sum256([]byte(abs))
+	memo := filepath.Join(c.Dir, "sums", hex.EncodeToString(key[:12]))
+	stamp := fmt.Sprintf("%d %d ", info.Size(), info.ModTime().UnixNano())
+	if data, err := os.ReadFile(memo); err == nil && strings.HasPrefix(string(data), stamp) {
+		return strings.TrimPrefix(string(data), stamp), nil
+	}
+
+	f, err := os.Open(jar)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))[:32]
+	if err := os.MkdirAll(filepath.Dir(memo), 0o755); err == nil {
+		_ = os.WriteFile(memo, []byte(stamp+sum), 0o644)
+	}
+	return sum, nil
+}
+
+// unzip writes every file of src below dest and returns their total size.
+func unzip(src, dest string) (int64, error) {
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		return 0, err
+	}
+	defer zr.Close()
+
+	var total int64
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		path := filepath.Clean(filepath.Join(dest, filepath.FromSlash(f.Name)))
+		if !strings.HasPrefix(path, dest+string(filepath.Separator)) {
+			return 0, fmt.Errorf("invalid path in archive: %s", f.Name)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return 0, err
+		}
+		n, err := writeEntry(f, path)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+func writeEntry(f *zip.File, path string) (int64, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	out, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, rc)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// Evict removes the least recently used extractions until the cache fits in
+// MaxBytes. Extractions in use by any process or used within the last hour
+// are kept. Only one process evicts at a time; the others skip eviction.
+func (c *Cache) Evict() error {
+	if c.MaxBytes <= 0 {
+		return nil
+	}
+	unlock, ok := c.lockEviction()
+	if !ok {
+		return nil
+	}
+	defer unlock()
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	type extraction struct {
+		dir  string
+		size int64
+		used time.Time
+	}
+	var all []extraction
+	var total int64
+	for _, e := range entries {
+		name := e.Name()
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(name, "tmp-"):
+			// Left over from a crashed extraction once it is old enough.
+			if time.Since(info.ModTime()) > time.Hour {
+				_ = os.RemoveAll(filepath.Join(c.Dir, name))
+			}
+		case strings.Contains(name, ".use-"):
+			if time.Since(info.ModTime()) > staleUse {
+				_ = os.Remove(filepath.Join(c.Dir, name))
+			}
+		case strings.HasSuffix(name, ".done"):
+			data, err := os.ReadFile(filepath.Join(c.Dir, name))
+			if err != nil {
+				continue
+			}
+			size, _ := strconv.ParseInt(string(data), 10, 64)
+			all = append(all, extraction{dir: filepath.Join(c.Dir, strings.TrimSuffix(name, ".done")), size: size, used: info.ModTime()})
+			total += size
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].used.Before(all[j].used) })
+	for _, e := range all {
+		if total <= c.MaxBytes {
+			break
+		}
+		if time.Since(e.used) < evictGrace || c.inUse(e.dir) {
+			continue
+		}
+		// Remove the done file first so no new search picks the directory
+		// up, then back off if one marked it in use meanwhile.
+		done := e.dir + ".done"
+		if err := os.Remove(done); err != nil {
+			return err
+		}
+		if c.inUse(e.dir) {
+			if err := os.WriteFile(done, []byte(strconv.FormatInt(e.size, 10)), 0o644); err != nil {
+				return err
+			}
+			continue
+		}
+		// Move the directory aside so the final path never holds a
+		// half-deleted extraction.
+		aside := filepath.Join(c.Dir, fmt.Sprintf("tmp-evict-%s-%d", filepath.Base(e.dir), time.Now().UnixNano()))
+		if err := os.Rename(e.dir, aside); err != nil {
+			return err
+		}
+		if err := os.RemoveAll(aside); err != nil {
+			return err
+		}
+		total -= e.size
+	}
+	return nil
+}
+
+// inUse reports whether a process holds a fresh use marker for dir.
+func (c *Cache) inUse(dir string) bool {
+	markers, _ := filepath.Glob(dir + ".use-*")
+	for _, m := range markers {
+		if info, err := os.Stat(m); err == nil && time.Since(info.ModTime()) <= staleUse {
+			return true
+		}
+	}
+	return false
+}
+
+// lockEviction creates the eviction lock file. It returns false when another
+// process holds it; a lock older than staleLock is taken over.
+func (c *Cache) lockEviction() (func(), bool) {
+	path := filepath.Join(c.Dir, "evict.lock")
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			_ = f.Close()
+			return func() { _ = os.Remove(path) }, true
+		}
+		info, statErr := os.Stat(path)
+		if !os.IsExist(err) || statErr != nil || time.Since(info.ModTime()) <= staleLock {
+			return nil, false
+		}
+		_ = os.Remove(path)
+	}
+	return nil, false
+}
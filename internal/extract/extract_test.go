@@ -0,0 +1,153 @@
+package extract
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExtractReusesDirectoryByChecksum(t *testing.T) {
+	tmp := t.TempDir()
+	jar := filepath.Join(tmp, "a", "foo-sources.jar")
+	copyJar := filepath.Join(tmp, "b", "foo-sources.jar")
+	writeZip(t, jar, map[string]string{"com/foo/A.kt": "class A\n"})
+	writeZip(t, copyJar, map[string]string{"com/foo/A.kt": "class A\n"})
+	c := &Cache{Dir: filepath.Join(tmp, "cache")}
+
+	dir, err := c.Extract(jar)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "com", "foo", "A.kt"))
+	if err != nil || string(data) != "class A\n" {
+		t.Fatalf("unexpected extracted file: %q, %v", data, err)
+	}
+	// A marker proves the second call does not extract again.
+	if err := os.WriteFile(filepath.Join(dir, "marker"), nil, 0o644); err != nil {
+		t.Fatalf("write marker: %v", err)
+	}
+	again, err := c.Extract(jar)
+	if err != nil || again != dir {
+		t.Fatalf("expected %s to be reused, got %s, %v", dir, again, err)
+	}
+	if other, err := c.Extract(copyJar); err != nil || other != dir {
+		t.Fatalf("expected identical jars to share %s, got %s, %v", dir, other, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "marker")); err != nil {
+		t.Fatal("expected the extraction to be reused")
+	}
+
+	writeZip(t, jar, map[string]string{"com/foo/A.kt": "class A2\n"})
+	changed, err := c.Extract(jar)
+	if err != nil || changed == dir {
+		t.Fatalf("expected a new directory for changed content, got %s, %v", changed, err)
+	}
+}
+
+func TestEvictRemovesLeastRecentlyUsed(t *testing.T) {
+	tmp := t.TempDir()
+	c := &Cache{Dir: filepath.Join(tmp, "cache")}
+	var dirs []string
+	for i, content := range []string{"class Old\n", "class Used\n", "class New\n", "class Recent\n"} {
+		jar := filepath.Join(tmp, string(rune('a'+i))+".jar")
+		writeZip(t, jar, map[string]string{"A.kt": content})
+		dir, err := c.Extract(jar)
+		if err != nil {
+			t.Fatalf("Extract: %v", err)
+		}
+		if i != 1 {
+			c.Release(dir)
+		}
+		if i < 3 {
+			used := time.Now().Add(time.Duration(i-5) * time.Hour)
+			if err := os.Chtimes(dir+".done", used, used); err != nil {
+				t.Fatalf("chtimes: %v", err)
+			}
+		}
+		dirs = append(dirs, dir)
+	}
+
+	c.MaxBytes = 1
+	lock := filepath.Join(c.Dir, "evict.lock")
+	if err := os.WriteFile(lock, nil, 0o644); err != nil {
+		t.Fatalf("write lock: %v", err)
+	}
+	if err := c.Evict(); err != nil {
+		t.Fatalf("Evict: %v", err)
+	}
+	if _, err := os.Stat(dirs[0]); err != nil {
+		t.Fatal("expected no eviction while another process holds the lock")
+	}
+	stale := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(lock, stale, stale); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if err := c.Evict(); err != nil {
+		t.Fatalf("Evict: %v", err)
+	}
+	// Old and New are evicted; Used is in use and Recent within the grace period.
+	for i, want := range []bool{false, true, false, true} {
+		if _, err := os.Stat(dirs[i]); (err == nil) != want {
+			t.Fatalf("dir %d: expected present=%v, got err %v", i, want, err)
+		}
+	}
+	if _, err := os.Stat(lock); !os.IsNotExist(err) {
+		t.Fatalf("expected the lock to be removed, got %v", err)
+	}
+}
+
+func TestExtractMovesAsideLeftoverDirectory(t *testing.T) {
+	tmp := t.TempDir()
+	jar := filepath.Join(tmp, "foo.jar")
+	writeZip(t, jar, map[string]string{"A.kt": "class A\n"})
+	c := &Cache{Dir: filepath.Join(tmp, "cache")}
+	dir, err := c.Extract(jar)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	c.Release(dir)
+	// An interrupted run renamed its directory into place but wrote no done file.
+	if err := os.Remove(dir + ".done"); err != nil {
+		t.Fatalf("remove done: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "partial"), nil, 0o644); err != nil {
+		t.Fatalf("write marker: %v", err)
+	}
+	again, err := c.Extract(jar)
+	if err != nil || again != dir {
+		t.Fatalf("expected %s, got %s, %v", dir, again, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "partial")); !os.IsNotExist(err) {
+		t.Fatal("expected the leftover directory to be replaced")
+	}
+	if _, err := os.Stat(dir + ".done"); err != nil {
+		t.Fatalf("expected a done file: %v", err)
+	}
+}
+
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create zip: %v", err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip entry: %v", err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("zip write: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+}
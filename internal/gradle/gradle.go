@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/respawn-app/ksrc/internal/executil"
@@ -29,11 +30,19 @@ type ResolveOptions struct {
 	IncludeBuildSrc       bool
 	IncludeBuildscript    bool
 	IncludeIncludedBuilds bool
+	// WithDeps also resolves sources for the dependencies of the selected
+	// modules, up to this many edges away; negative is unlimited, 0 is off.
+	WithDeps int
 }
 
 type ResolveResult struct {
-	Sources        []resolve.SourceJar
-	Deps           []resolve.Coord
+	Sources []resolve.SourceJar
+	Deps    []resolve.Coord
+	// Direct lists the dependencies declared by the project itself; the rest
+	// of Deps are transitive.
+	Direct []resolve.Coord
+	// Edges is the resolved dependency graph between modules.
+	Edges          []resolve.Edge
 	IncludedBuilds []string
 	Warnings       []string
 }
@@ -154,6 +163,7 @@ func resolveOnce(ctx context.Context, runner executil.Runner, opts ResolveOption
 	result := ResolveResult{}
 	seen := make(map[string]struct{})
 	seenIncludes := make(map[string]struct{})
+	seenEdges := make(map[string]struct{})
 	for _, line := range strings.Split(stdout, "\n") {
 		line = strings.TrimSpace(line)
 		if line == "" {
@@ -179,6 +189,29 @@ func resolveOnce(ctx context.Context, runner executil.Runner, opts ResolveOption
 			}
 			result.Deps = append(result.Deps, coord)
 		}
+		if strings.HasPrefix(line, "KSRCDIRECT|") {
+			coord, _, ok := parseLine(line, "KSRCDIRECT|")
+			if !ok {
+				continue
+			}
+			result.Direct = append(result.Direct, coord)
+		}
+		if strings.HasPrefix(line, "KSRCEDGE|") {
+			from, rest, ok := parseLine(line, "KSRCEDGE|")
+			if !ok {
+				continue
+			}
+			to, err := resolve.ParseCoord(rest)
+			if err != nil {
+				continue
+			}
+			key := from.String() + "|" + to.String()
+			if _, exists := seenEdges[key]; exists {
+				continue
+			}
+			seenEdges[key] = struct{}{}
+			result.Edges = append(result.Edges, resolve.Edge{From: from, To: to})
+		}
 		if strings.HasPrefix(line, "KSRCINCLUDE|") {
 			path := strings.TrimSpace(strings.TrimPrefix(line, "KSRCINCLUDE|"))
 			if path == "" {
@@ -237,7 +270,7 @@ func samePath(a string, b string) bool {
 }
 
 func mergeResults(base ResolveResult, extra ResolveResult) ResolveResult {
-	if len(extra.Sources) == 0 && len(extra.Deps) == 0 && len(extra.IncludedBuilds) == 0 && len(extra.Warnings) == 0 {
+	if len(extra.Sources) == 0 && len(extra.Deps) == 0 && len(extra.Direct) == 0 && len(extra.Edges) == 0 && len(extra.IncludedBuilds) == 0 && len(extra.Warnings) == 0 {
 		return base
 	}
 	seenSources := make(map[string]struct{}, len(base.Sources))
@@ -266,6 +299,31 @@ func mergeResults(base ResolveResult, extra ResolveResult) ResolveResult {
 		base.Deps = append(base.Deps, d)
 	}
 
+	seenDirect := make(map[string]struct{}, len(base.Direct))
+	for _, d := range base.Direct {
+		seenDirect[d.String()] = struct{}{}
+	}
+	for _, d := range extra.Direct {
+		key := d.String()
+		if _, ok := seenDirect[key]; ok {
+			continue
+		}
+		seenDirect[key] = struct{}{}
+		base.Direct = append(base.Direct, d)
+	}
+
+	seenEdges := make(map[resolve.Edge]struct{}, len(base.Edges))
+	for _, e := range base.Edges {
+		seenEdges[e] = struct{}{}
+	}
+	for _, e := range extra.Edges {
+		if _, ok := seenEdges[e]; ok {
+			continue
+		}
+		seenEdges[e] = struct{}{}
+		base.Edges = append(base.Edges, e)
+	}
+
 	if len(extra.IncludedBuilds) > 0 {
 		seenIncludes := make(map[string]struct{}, len(base.IncludedBuilds))
 		for _, inc := range base.IncludedBuilds {
@@ -318,6 +376,11 @@ func buildProps(opts ResolveOptions) []string {
 		add("ksrcSubprojects", strings.Join(opts.Subprojects, ","))
 	}
 	add("ksrcDep", opts.Dep)
+	if opts.WithDeps < 0 {
+		add("ksrcWithDeps", "all")
+	} else if opts.WithDeps > 0 {
+		add("ksrcWithDeps", strconv.Itoa(opts.WithDeps))
+	}
 	if opts.IncludeBuildscript {
 		add("ksrcBuildscript", "true")
 	} else {
@@ -96,6 +96,60 @@ func TestResolveStopsAfterRootSources(t *testing.T) {
 	}
 }
 
+func TestResolveParsesDirectDeps(t *testing.T) {
+	root := t.TempDir()
+	runner := &scriptedRunner{
+		responses: map[string]runResult{
+			root: {
+				stdout: "KSRCDEP|com.example:demo:1.0.0\nKSRCDIRECT|com.example:demo:1.0.0\nKSRCDEP|com.example:transitive:2.0.0\nKSRC|com.example:demo:1.0.0|/tmp/demo-sources.jar\n",
+			},
+		},
+	}
+	res, err := Resolve(context.Background(), runner, ResolveOptions{ProjectDir: root})
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if len(res.Deps) != 2 {
+		t.Fatalf("expected 2 deps, got %+v", res.Deps)
+	}
+	if len(res.Direct) != 1 || res.Direct[0].Artifact != "demo" {
+		t.Fatalf("unexpected direct deps: %+v", res.Direct)
+	}
+}
+
+func TestResolveParsesEdges(t *testing.T) {
+	root := t.TempDir()
+	runner := &scriptedRunner{
+		responses: map[string]runResult{
+			root: {
+				stdout: "KSRCEDGE|io.ktor:ktor-client-core:2.3.0|io.ktor:ktor-utils:2.3.0\nKSRCEDGE|io.ktor:ktor-client-core:2.3.0|io.ktor:ktor-utils:2.3.0\nKSRCEDGE|io.ktor:ktor-utils:2.3.0|io.ktor:ktor-io:2.3.0\nKSRCEDGE|broken\nKSRC|io.ktor:ktor-client-core:2.3.0|/tmp/core-sources.jar\n",
+			},
+		},
+	}
+	res, err := Resolve(context.Background(), runner, ResolveOptions{ProjectDir: root, WithDeps: -1})
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if len(res.Edges) != 2 {
+		t.Fatalf("expected 2 edges, got %+v", res.Edges)
+	}
+	if res.Edges[1].From.Artifact != "ktor-utils" || res.Edges[1].To.Artifact != "ktor-io" {
+		t.Fatalf("unexpected edge: %+v", res.Edges[1])
+	}
+}
+
+func TestBuildPropsWithDeps(t *testing.T) {
+	for _, tc := range []struct {
+		depth int
+		want  string
+	}{{-1, "-PksrcWithDeps=all"}, {2, "-PksrcWithDeps=2"}, {0, ""}} {
+		props := strings.Join(buildProps(ResolveOptions{WithDeps: tc.depth}), " ")
+		if tc.want == "" && strings.Contains(props, "ksrcWithDeps") || !strings.Contains(props, tc.want) {
+			t.Fatalf("depth %d: unexpected props %q", tc.depth, props)
+		}
+	}
+}
+
 func TestResolveFallsBackToBuildSrc(t *testing.T) {
 	dir := t.TempDir()
 	buildSrcDir := filepath.Join(dir, "buildSrc")
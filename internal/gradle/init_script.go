@@ -2,6 +2,7 @@ package gradle
 
 const initScript = `
 import org.gradle.api.artifacts.component.ModuleComponentIdentifier
+import org.gradle.api.artifacts.result.ResolvedDependencyResult
 
 def splitCsv = { String value ->
     if (value == null) return [] as Set
@@ -74,6 +75,7 @@ def targetsProp = props['ksrcTargets']
 def subprojectsProp = props['ksrcSubprojects']
 def scopeProp = props['ksrcScope'] ?: 'compile'
 def depProp = props['ksrcDep']
+def withDepsProp = props['ksrcWithDeps'] as String
 def includeBuildscript = (props['ksrcBuildscript'] ?: 'true').toString().toBoolean()
 def includeIncludedBuilds = (props['ksrcIncludeBuilds'] ?: 'true').toString().toBoolean()
 
@@ -106,10 +108,24 @@ gradle.rootProject { root ->
         }
 
         def moduleIds = [] as Set
+        def directIds = [] as Set
+        def edges = [:].withDefault { [] as Set }
         selectedConfigs.each { cfg ->
-            cfg.incoming.resolutionResult.allComponents.each { comp ->
+            def result = cfg.incoming.resolutionResult
+            result.allComponents.each { comp ->
                 def id = comp.id
-                if (id instanceof ModuleComponentIdentifier) moduleIds << id
+                if (!(id instanceof ModuleComponentIdentifier)) return
+                moduleIds << id
+                comp.dependencies.each { dep ->
+                    if (!(dep instanceof ResolvedDependencyResult)) return
+                    def to = dep.selected.id
+                    if (to instanceof ModuleComponentIdentifier) edges[id] << to
+                }
+            }
+            result.root.dependencies.each { dep ->
+                if (!(dep instanceof ResolvedDependencyResult)) return
+                def id = dep.selected.id
+                if (id instanceof ModuleComponentIdentifier) directIds << id
             }
         }
 
@@ -138,8 +154,29 @@ gradle.rootProject { root ->
             matchesGlob(versionProp as String, id.version)
         }
 
+        if (withDepsProp) {
+            def depth = withDepsProp == 'all' ? Integer.MAX_VALUE : withDepsProp.toInteger()
+            def closure = new LinkedHashSet(filteredIds)
+            def frontier = filteredIds
+            for (int level = 0; level < depth && !frontier.isEmpty(); level++) {
+                def next = [] as Set
+                frontier.each { id ->
+                    edges[id].each { to -> if (closure.add(to)) next << to }
+                }
+                frontier = next
+            }
+            filteredIds = closure
+        }
+
+        edges.each { from, tos ->
+            tos.each { to ->
+                println "KSRCEDGE|${from.group}:${from.module}:${from.version}|${to.group}:${to.module}:${to.version}"
+            }
+        }
+
         filteredIds.each { id ->
             println "KSRCDEP|${id.group}:${id.module}:${id.version}"
+            if (directIds.contains(id)) println "KSRCDIRECT|${id.group}:${id.module}:${id.version}"
         }
 
         if (filteredIds.isEmpty()) return
@@ -0,0 +1,273 @@
+package index
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/respawn-app/ksrc/internal/cache"
+)
+
+// format is bumped whenever the on-disk layout changes; older files are rebuilt.
+const format = 1
+
+// maxIndexedFile is the largest entry whose trigrams are recorded. Larger
+// entries are always candidates.
+const maxIndexedFile = 4 << 20
+
+// Index maps the trigrams of a source jar's entries to the entries containing
+// them. Trigrams are ASCII-lowercased so one index serves case-sensitive and
+// case-insensitive searches.
+type Index struct {
+	Format  int
+	Jar     string
+	Size    int64
+	ModTime time.Time
+	Files   []string
+	// Unindexed lists files that are too large to index.
+	Unindexed []uint32
+	Postings  map[uint32][]uint32
+}
+
+// Build indexes every file in the jar at path.
+func Build(path string) (*Index, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	ix := &Index{Format: format, Jar: path, Size: info.Size(), ModTime: info.ModTime(), Postings: make(map[uint32][]uint32)}
+	seen := make(map[uint32]bool)
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		id := uint32(len(ix.Files))
+		ix.Files = append(ix.Files, f.Name)
+		if f.UncompressedSize64 > maxIndexedFile {
+			ix.Unindexed = append(ix.Unindexed, id)
+			continue
+		}
+		data, err := readEntry(f)
+		if err != nil {
+			return nil, err
+		}
+		clear(seen)
+		for _, gram := range trigrams(data) {
+			if !seen[gram] {
+				seen[gram] = true
+				ix.Postings[gram] = append(ix.Postings[gram], id)
+			}
+		}
+	}
+	return ix, nil
+}
+
+func readEntry(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// Candidates returns the files that may match any of the plans. It returns
+// false when a plan is nil, meaning every file is a candidate.
+func (ix *Index) Candidates(plans []Plan) (map[string]bool, bool) {
+	ids := make(map[uint32]bool)
+	for _, plan := range plans {
+		if plan == nil {
+			return nil, false
+		}
+		for _, alt := range plan {
+			for _, id := range ix.intersect(alt) {
+				ids[id] = true
+			}
+		}
+	}
+	for _, id := range ix.Unindexed {
+		ids[id] = true
+	}
+	out := make(map[string]bool, len(ids))
+	for id := range ids {
+		out[ix.Files[id]] = true
+	}
+	return out, true
+}
+
+// intersect returns the files containing every trigram in grams.
+func (ix *Index) intersect(grams []uint32) []uint32 {
+	lists := make([][]uint32, 0, len(grams))
+	for _, gram := range grams {
+		list := ix.Postings[gram]
+		if len(list) == 0 {
+			return nil
+		}
+		lists = append(lists, list)
+	}
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+	out := lists[0]
+	for _, list := range lists[1:] {
+		var next []uint32
+		i, j := 0, 0
+		for i < len(out) && j < len(list) {
+			switch {
+			case out[i] < list[j]:
+				i++
+			case out[i] > list[j]:
+				j++
+			default:
+				next = append(next, out[i])
+				i++
+				j++
+			}
+		}
+		out = next
+		if len(out) == 0 {
+			return nil
+		}
+	}
+	return out
+}
+
+// Store keeps indexes in a cache directory, one file per jar path. An index
+// is stale once the jar's size or modification time changes.
+type Store struct {
+	Dir string
+}
+
+// DefaultStore returns the store below the ksrc cache directory.
+func DefaultStore() (*Store, error) {
+	dir, err := cache.Dir()
+	if err != nil {
+		return nil, err
+	}
+	return &Store{Dir: filepath.Join(dir, "index")}, nil
+}
+
+// Enabled reports whether indexing was turned on by building an index.
+// Searches only build missing indexes lazily once it is.
+func (s *Store) Enabled() bool {
+	info, err := os.Stat(s.Dir)
+	return err == nil && info.IsDir()
+}
+
+// Path returns where the index of jar is saved.
+func (s *Store) Path(jar string) string {
+	abs, err := filepath.Abs(jar)
+	if err != nil {
+		abs = jar
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(s.Dir, hex.EncodeToString(sum[:12])+".idx")
+}
+
+// State describes the index of one jar.
+type State int
+
+const (
+	Missing State = iota
+	Stale
+	Fresh
+)
+
+func (s State) String() string {
+	switch s {
+	case Fresh:
+		return "indexed"
+	case Stale:
+		return "stale"
+	default:
+		return "missing"
+	}
+}
+
+// Load returns the index of jar and its state; the index is nil unless Fresh.
+func (s *Store) Load(jar string) (*Index, State, error) {
+	info, err := os.Stat(jar)
+	if err != nil {
+		return nil, Missing, err
+	}
+	f, err := os.Open(s.Path(jar))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, Missing, nil
+	}
+	if err != nil {
+		return nil, Missing, err
+	}
+	defer f.Close()
+	var ix Index
+	if err := gob.NewDecoder(f).Decode(&ix); err != nil {
+		return nil, Stale, nil
+	}
+	if ix.Format != format || ix.Size != info.Size() || !ix.ModTime.Equal(info.ModTime()) {
+		return nil, Stale, nil
+	}
+	return &ix, Fresh, nil
+}
+
+// Get loads the index of jar, building and saving it when missing or stale.
+func (s *Store) Get(jar string) (*Index, error) {
+	ix, state, err := s.Load(jar)
+	if err != nil || state == Fresh {
+		return ix, err
+	}
+	return s.Build(jar)
+}
+
+// Build indexes jar and saves the index, replacing any previous one.
+func (s *Store) Build(jar string) (*Index, error) {
+	ix, err := Build(jar)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(s.Dir, "tmp-*.idx")
+	if err != nil {
+		return nil, err
+	}
+	if err := gob.NewEncoder(tmp).Encode(ix); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), s.Path(jar)); err != nil {
+		_ = os.Remove(tmp.Name())
+		return nil, err
+	}
+	return ix, nil
+}
+
+// FileSize returns the size of the saved index of jar, or 0.
+func (s *Store) FileSize(jar string) int64 {
+	info, err := os.Stat(s.Path(jar))
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// Clear removes every saved index, which also disables lazy indexing.
+func (s *Store) Clear() error {
+	return os.RemoveAll(s.Dir)
+}
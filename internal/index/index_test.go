@@ -0,0 +1,131 @@
+package index
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestPlanPattern(t *testing.T) {
+	if PlanPattern(".*", false, false) != nil {
+		t.Fatal("expected no plan for .*")
+	}
+	if PlanPattern("a|bcd", false, false) != nil {
+		t.Fatal("expected no plan when an alternative has no trigram")
+	}
+	if PlanPattern("(", false, false) != nil {
+		t.Fatal("expected no plan for an invalid pattern")
+	}
+	if plan := PlanPattern("foo|barBaz", false, false); len(plan) != 2 {
+		t.Fatalf("expected two alternatives, got %v", plan)
+	}
+	if plan := PlanPattern("fun (foo|barBaz)", false, false); !reflect.DeepEqual(plan, PlanPattern("fun ", true, false)) {
+		t.Fatalf("expected the alternation to be skipped inside a concatenation, got %v", plan)
+	}
+	if fixed := PlanPattern("a.b(", true, false); len(fixed) != 1 || len(fixed[0]) != 2 {
+		t.Fatalf("unexpected fixed-string plan: %v", fixed)
+	}
+	if !reflect.DeepEqual(PlanPattern("FOO", false, true), PlanPattern("foo", false, false)) {
+		t.Fatal("expected trigrams to be case-folded")
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	jar := filepath.Join(t.TempDir(), "foo-sources.jar")
+	writeZip(t, jar, map[string]string{
+		"com/foo/A.kt": "suspend fun load(): Flow<Int>\n",
+		"com/foo/B.kt": "fun save() = Unit\n",
+		"com/foo/C.kt": "class Loader\n",
+	})
+	ix, err := Build(jar)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	got := func(plans ...Plan) []string {
+		files, ok := ix.Candidates(plans)
+		if !ok {
+			t.Fatal("expected narrowing")
+		}
+		var out []string
+		for name := range files {
+			out = append(out, name)
+		}
+		sort.Strings(out)
+		return out
+	}
+	if files := got(PlanPattern("fun", false, false)); !reflect.DeepEqual(files, []string{"com/foo/A.kt", "com/foo/B.kt"}) {
+		t.Fatalf("unexpected candidates: %v", files)
+	}
+	if files := got(PlanPattern("load", false, true)); !reflect.DeepEqual(files, []string{"com/foo/A.kt", "com/foo/C.kt"}) {
+		t.Fatalf("unexpected case-insensitive candidates: %v", files)
+	}
+	if files := got(PlanPattern("missing", false, false)); len(files) != 0 {
+		t.Fatalf("expected no candidates, got %v", files)
+	}
+	if _, ok := ix.Candidates([]Plan{PlanPattern("fun", false, false), nil}); ok {
+		t.Fatal("expected a nil plan to disable narrowing")
+	}
+}
+
+func TestStoreStates(t *testing.T) {
+	jar := filepath.Join(t.TempDir(), "foo-sources.jar")
+	writeZip(t, jar, map[string]string{"com/foo/A.kt": "fun a() = 1\n"})
+	store := &Store{Dir: filepath.Join(t.TempDir(), "index")}
+	if store.Enabled() {
+		t.Fatal("expected store to be disabled before the first build")
+	}
+	if _, state, err := store.Load(jar); err != nil || state != Missing {
+		t.Fatalf("expected missing index, got %v, %v", state, err)
+	}
+	if _, err := store.Get(jar); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	ix, state, err := store.Load(jar)
+	if err != nil || state != Fresh || len(ix.Files) != 1 {
+		t.Fatalf("expected fresh index, got %v, %v", state, err)
+	}
+	if !store.Enabled() || store.FileSize(jar) == 0 {
+		t.Fatal("expected saved index")
+	}
+
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(jar, later, later); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if _, state, _ := store.Load(jar); state != Stale {
+		t.Fatalf("expected stale index after the jar changed, got %v", state)
+	}
+
+	if err := store.Clear(); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+	if store.Enabled() {
+		t.Fatal("expected Clear to disable the store")
+	}
+}
+
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create zip: %v", err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip entry: %v", err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("zip write: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+}
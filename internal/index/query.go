@@ -0,0 +1,137 @@
+package index
+
+import (
+	"regexp/syntax"
+	"strings"
+	"unicode/utf8"
+)
+
+// Plan says which trigrams a file must contain to possibly match a pattern:
+// all trigrams of at least one alternative. A nil Plan matches every file.
+type Plan [][]uint32
+
+// PlanPattern derives a Plan from a ripgrep pattern. It only relies on
+// literal text the pattern requires, so it never rules out a matching file;
+// patterns it cannot analyze give a nil Plan. foldCase must be set when the
+// search ignores case.
+func PlanPattern(pattern string, fixed, foldCase bool) Plan {
+	if fixed {
+		return planLiterals([][]string{{pattern}}, foldCase)
+	}
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	return planLiterals(required(re.Simplify(), foldCase), foldCase)
+}
+
+// required returns alternatives of literal strings that a match must contain,
+// or nil when nothing is required.
+func required(re *syntax.Regexp, foldCase bool) [][]string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if (foldCase || re.Flags&syntax.FoldCase != 0) && !isASCII(re.Rune) {
+			// Unicode case folding does not map onto the ASCII-folded index.
+			return nil
+		}
+		return [][]string{{string(re.Rune)}}
+	case syntax.OpCapture, syntax.OpPlus:
+		return required(re.Sub[0], foldCase)
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return required(re.Sub[0], foldCase)
+		}
+		return nil
+	case syntax.OpAlternate:
+		var out [][]string
+		for _, sub := range re.Sub {
+			alts := required(sub, foldCase)
+			if alts == nil {
+				return nil
+			}
+			out = append(out, alts...)
+		}
+		return out
+	case syntax.OpConcat:
+		// Adjacent literals are merged by the parser. Sub-expressions with a
+		// single alternative add their literals; others are skipped, which
+		// only makes the plan less selective.
+		var all []string
+		for _, sub := range re.Sub {
+			alts := required(sub, foldCase)
+			if len(alts) == 1 {
+				all = append(all, alts[0]...)
+			}
+		}
+		if len(all) == 0 {
+			return nil
+		}
+		return [][]string{all}
+	}
+	return nil
+}
+
+func planLiterals(alts [][]string, foldCase bool) Plan {
+	if len(alts) == 0 {
+		return nil
+	}
+	plan := make(Plan, 0, len(alts))
+	for _, lits := range alts {
+		var grams []uint32
+		for _, lit := range lits {
+			if foldCase && !isASCII([]rune(lit)) {
+				continue
+			}
+			grams = append(grams, trigrams([]byte(lit))...)
+		}
+		if len(grams) == 0 {
+			return nil
+		}
+		plan = append(plan, grams)
+	}
+	return plan
+}
+
+// trigrams returns the ASCII-lowercased trigrams of data.
+func trigrams(data []byte) []uint32 {
+	if len(data) < 3 {
+		return nil
+	}
+	out := make([]uint32, 0, len(data)-2)
+	for i := 0; i+3 <= len(data); i++ {
+		out = append(out, uint32(lower(data[i]))<<16|uint32(lower(data[i+1]))<<8|uint32(lower(data[i+2])))
+	}
+	return out
+}
+
+func lower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+func isASCII(runes []rune) bool {
+	for _, r := range runes {
+		if r >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// Searchable reports whether ripgrep arguments keep trigram narrowing sound.
+// Inverted matches, extra patterns and other regex engines disable it.
+func Searchable(rgArgs []string) bool {
+	for _, arg := range rgArgs {
+		switch {
+		case arg == "-v", arg == "--invert-match",
+			arg == "-e", strings.HasPrefix(arg, "--regexp"),
+			arg == "-f", strings.HasPrefix(arg, "--file"),
+			arg == "-P", arg == "--pcre2", strings.HasPrefix(arg, "--engine"),
+			arg == "--files", arg == "--files-without-match":
+			return false
+		}
+	}
+	return true
+}
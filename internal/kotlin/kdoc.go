@@ -0,0 +1,114 @@
+package kotlin
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Doc is a parsed KDoc or Javadoc comment.
+type Doc struct {
+	Body string
+	Tags []DocTag
+}
+
+// DocTag is a block tag such as @param, @return, @throws or @sample. Subject
+// holds the tag's first word for tags that name something (@param x, @sample a.b).
+type DocTag struct {
+	Name    string
+	Subject string
+	Text    string
+}
+
+var subjectTags = map[string]bool{
+	"param": true, "property": true, "throws": true, "exception": true, "sample": true, "see": true,
+}
+
+var (
+	kdocLabeledLink = regexp.MustCompile(`\[([^\[\]]+)\]\[([^\[\]]+)\]`)
+	kdocLink        = regexp.MustCompile(`\[([A-Za-z_][\w.#]*)\]`)
+	javadocInline   = regexp.MustCompile(`\{@(link|linkplain|code|literal|value)\s+([^}]*)\}`)
+	htmlTag         = regexp.MustCompile(`(?i)</?(p|br|b|i|em|strong|code|pre|ul|ol|li|tt|a|h\d)(\s[^>]*)?/?>`)
+)
+
+// ParseDoc strips comment delimiters and splits a doc comment into its body and block tags.
+func ParseDoc(comment string) Doc {
+	text := strings.TrimSpace(comment)
+	text = strings.TrimPrefix(text, "/**")
+	text = strings.TrimSuffix(text, "*/")
+
+	var doc Doc
+	var body []string
+	var cur *DocTag
+	inFence := false
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		trimmed := strings.TrimLeft(line, " \t")
+		if strings.HasPrefix(trimmed, "*") {
+			trimmed = strings.TrimPrefix(trimmed, "*")
+			trimmed = strings.TrimPrefix(trimmed, " ")
+		}
+		if strings.HasPrefix(strings.TrimSpace(trimmed), "```") {
+			inFence = !inFence
+		}
+		if !inFence && strings.HasPrefix(trimmed, "@") {
+			name, rest, _ := strings.Cut(trimmed[1:], " ")
+			tag := DocTag{Name: name}
+			rest = strings.TrimSpace(rest)
+			if subjectTags[name] {
+				tag.Subject, rest, _ = strings.Cut(rest, " ")
+				rest = strings.TrimSpace(rest)
+			}
+			tag.Text = rest
+			doc.Tags = append(doc.Tags, tag)
+			cur = &doc.Tags[len(doc.Tags)-1]
+			continue
+		}
+		if cur != nil {
+			if cur.Text != "" {
+				cur.Text += "\n"
+			}
+			if inFence {
+				cur.Text += trimmed
+			} else {
+				cur.Text += strings.TrimSpace(trimmed)
+			}
+			continue
+		}
+		body = append(body, trimmed)
+	}
+	doc.Body = cleanDocText(strings.Join(body, "\n"))
+	for i := range doc.Tags {
+		doc.Tags[i].Text = cleanDocText(doc.Tags[i].Text)
+		doc.Tags[i].Subject = cleanDocText(doc.Tags[i].Subject)
+	}
+	return doc
+}
+
+// cleanDocText renders KDoc links and Javadoc inline tags as plain text.
+func cleanDocText(s string) string {
+	s = kdocLabeledLink.ReplaceAllString(s, "$1")
+	s = kdocLink.ReplaceAllString(s, "$1")
+	s = javadocInline.ReplaceAllStringFunc(s, func(m string) string {
+		parts := javadocInline.FindStringSubmatch(m)
+		arg := strings.TrimSpace(parts[2])
+		if parts[1] == "code" || parts[1] == "literal" {
+			return "`" + arg + "`"
+		}
+		if _, label, ok := strings.Cut(arg, " "); ok && strings.TrimSpace(label) != "" {
+			return strings.TrimSpace(label)
+		}
+		return arg
+	})
+	s = htmlTag.ReplaceAllStringFunc(s, func(m string) string {
+		lower := strings.ToLower(m)
+		switch {
+		case strings.HasPrefix(lower, "<li"):
+			return "- "
+		case strings.HasPrefix(lower, "<p"), strings.HasPrefix(lower, "<br"):
+			return "\n"
+		}
+		return ""
+	})
+	s = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&", "&quot;", `"`, "&nbsp;", " ").Replace(s)
+	return strings.TrimSpace(s)
+}
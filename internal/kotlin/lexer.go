@@ -0,0 +1,344 @@
+package kotlin
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type TokenKind int
+
+const (
+	Ident TokenKind = iota
+	Number
+	String
+	Char
+	LineComment
+	BlockComment
+	DocComment
+	Punct
+)
+
+// Token is a lexical token. Offsets are byte offsets into the source; lines and
+// columns are 1-based. Backticked identifiers are stored without backticks.
+type Token struct {
+	Kind    TokenKind
+	Text    string
+	Start   int
+	End     int
+	Line    int
+	Col     int
+	EndLine int
+	// Templates holds the byte ranges of a Kotlin string's template
+	// expressions: the name of $name and the body of ${...}.
+	Templates [][2]int
+}
+
+func (t Token) IsComment() bool {
+	return t.Kind == LineComment || t.Kind == BlockComment || t.Kind == DocComment
+}
+
+type Lang int
+
+const (
+	LangKotlin Lang = iota
+	LangJava
+)
+
+// LangForPath picks the lexer dialect from a file name.
+func LangForPath(name string) Lang {
+	if strings.HasSuffix(name, ".java") {
+		return LangJava
+	}
+	return LangKotlin
+}
+
+var multiPunct = []string{"->", "::", "?.", "?:", "..", "!!", "==", "!=", "&&", "||"}
+
+type lexer struct {
+	src   []byte
+	lang  Lang
+	pos   int
+	line  int
+	col   int
+	out   []Token
+	start int
+	sLine int
+	sCol  int
+	// templates collects the template ranges of the string being lexed.
+	templates [][2]int
+}
+
+// Lex splits source into tokens, including comments. Whitespace is dropped.
+// Unterminated literals and comments extend to the end of input.
+func Lex(src []byte, lang Lang) []Token {
+	l := &lexer{src: src, lang: lang, line: 1, col: 1}
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' {
+			l.advance(1)
+			continue
+		}
+		l.mark()
+		switch {
+		case c == '/' && l.peek(1) == '/':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.advance(1)
+			}
+			l.emit(LineComment)
+		case c == '/' && l.peek(1) == '*':
+			kind := BlockComment
+			if l.peek(2) == '*' && l.peek(3) != '/' {
+				kind = DocComment
+			}
+			l.blockComment()
+			l.emit(kind)
+		case c == '"':
+			l.stringLiteral()
+			l.emit(String)
+		case c == '\'':
+			l.charLiteral()
+			l.emit(Char)
+		case c == '`':
+			l.advance(1)
+			for l.pos < len(l.src) && l.src[l.pos] != '`' && l.src[l.pos] != '\n' {
+				l.advance(1)
+			}
+			if l.pos < len(l.src) && l.src[l.pos] == '`' {
+				l.advance(1)
+			}
+			l.emit(Ident)
+			tok := &l.out[len(l.out)-1]
+			tok.Text = strings.Trim(tok.Text, "`")
+		case c >= '0' && c <= '9':
+			l.number()
+			l.emit(Number)
+		case isIdentStart(l.runeAt(l.pos)):
+			for l.pos < len(l.src) && isIdentPart(l.runeAt(l.pos)) {
+				_, size := utf8.DecodeRune(l.src[l.pos:])
+				l.advance(size)
+			}
+			l.emit(Ident)
+		default:
+			size := 1
+			for _, p := range multiPunct {
+				if bytes.HasPrefix(l.src[l.pos:], []byte(p)) {
+					size = len(p)
+					break
+				}
+			}
+			if size == 1 {
+				_, size = utf8.DecodeRune(l.src[l.pos:])
+			}
+			l.advance(size)
+			l.emit(Punct)
+		}
+	}
+	return l.out
+}
+
+func (l *lexer) mark() {
+	l.start = l.pos
+	l.sLine = l.line
+	l.sCol = l.col
+}
+
+func (l *lexer) emit(kind TokenKind) {
+	l.out = append(l.out, Token{
+		Kind:    kind,
+		Text:    string(l.src[l.start:l.pos]),
+		Start:   l.start,
+		End:     l.pos,
+		Line:    l.sLine,
+		Col:     l.sCol,
+		EndLine: l.line,
+	})
+	if kind == String {
+		l.out[len(l.out)-1].Templates = l.templates
+		l.templates = nil
+	}
+}
+
+func (l *lexer) advance(n int) {
+	for i := 0; i < n && l.pos < len(l.src); i++ {
+		if l.src[l.pos] == '\n' {
+			l.line++
+			l.col = 1
+		} else {
+			l.col++
+		}
+		l.pos++
+	}
+}
+
+func (l *lexer) peek(offset int) byte {
+	if l.pos+offset < len(l.src) {
+		return l.src[l.pos+offset]
+	}
+	return 0
+}
+
+func (l *lexer) runeAt(pos int) rune {
+	r, _ := utf8.DecodeRune(l.src[pos:])
+	return r
+}
+
+func (l *lexer) blockComment() {
+	l.advance(2)
+	depth := 1
+	for l.pos < len(l.src) {
+		if l.src[l.pos] == '*' && l.peek(1) == '/' {
+			l.advance(2)
+			depth--
+			if depth == 0 {
+				return
+			}
+			continue
+		}
+		// Kotlin block comments nest; Java ones do not.
+		if l.lang == LangKotlin && l.src[l.pos] == '/' && l.peek(1) == '*' {
+			l.advance(2)
+			depth++
+			continue
+		}
+		l.advance(1)
+	}
+}
+
+func (l *lexer) stringLiteral() {
+	if l.peek(1) == '"' && l.peek(2) == '"' {
+		l.advance(3)
+		for l.pos < len(l.src) {
+			if l.src[l.pos] == '"' && l.peek(1) == '"' && l.peek(2) == '"' {
+				l.advance(3)
+				for l.pos < len(l.src) && l.src[l.pos] == '"' {
+					l.advance(1)
+				}
+				return
+			}
+			if l.lang == LangKotlin && l.src[l.pos] == '$' && l.peek(1) == '{' {
+				l.template()
+				continue
+			}
+			if l.lang == LangKotlin && l.src[l.pos] == '$' {
+				l.simpleTemplate()
+				continue
+			}
+			l.advance(1)
+		}
+		return
+	}
+	l.advance(1)
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\\':
+			l.advance(2)
+		case c == '"':
+			l.advance(1)
+			return
+		case c == '\n':
+			return
+		case l.lang == LangKotlin && c == '$' && l.peek(1) == '{':
+			l.template()
+		case l.lang == LangKotlin && c == '$':
+			l.simpleTemplate()
+		default:
+			l.advance(1)
+		}
+	}
+}
+
+// template skips a ${...} expression, including nested string literals, and
+// records its body. Templates of nested literals are left to whoever lexes
+// the body again.
+func (l *lexer) template() {
+	l.advance(2)
+	start, nested := l.pos, len(l.templates)
+	defer func() {
+		end := l.pos
+		if end > start && l.src[end-1] == '}' {
+			end--
+		}
+		l.templates = append(l.templates[:nested], [2]int{start, end})
+	}()
+	depth := 1
+	for l.pos < len(l.src) {
+		switch l.src[l.pos] {
+		case '{':
+			depth++
+			l.advance(1)
+		case '}':
+			depth--
+			l.advance(1)
+			if depth == 0 {
+				return
+			}
+		case '"':
+			l.stringLiteral()
+		case '\'':
+			l.charLiteral()
+		default:
+			l.advance(1)
+		}
+	}
+}
+
+// simpleTemplate skips a $ and records the name following it, if any.
+func (l *lexer) simpleTemplate() {
+	l.advance(1)
+	start := l.pos
+	for l.pos < len(l.src) {
+		r := l.runeAt(l.pos)
+		if r == '$' || !isIdentPart(r) || l.pos == start && !isIdentStart(r) {
+			break
+		}
+		_, size := utf8.DecodeRune(l.src[l.pos:])
+		l.advance(size)
+	}
+	if l.pos > start {
+		l.templates = append(l.templates, [2]int{start, l.pos})
+	}
+}
+
+func (l *lexer) charLiteral() {
+	l.advance(1)
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch c {
+		case '\\':
+			l.advance(2)
+		case '\'':
+			l.advance(1)
+			return
+		case '\n':
+			return
+		default:
+			l.advance(1)
+		}
+	}
+}
+
+func (l *lexer) number() {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if c == '.' && l.peek(1) >= '0' && l.peek(1) <= '9' {
+			l.advance(1)
+			continue
+		}
+		if c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+			l.advance(1)
+			continue
+		}
+		return
+	}
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r)
+}
+
+func isIdentPart(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,993 @@
+package kotlin
+
+import (
+	"strings"
+)
+
+// File is the declaration outline of a single source file.
+type File struct {
+	Package string
+	Imports []Import
+	Decls   []*Decl
+}
+
+type Import struct {
+	Path  string
+	Alias string
+	Star  bool
+	Line  int
+}
+
+// Name returns the name an import introduces into file scope.
+func (i Import) Name() string {
+	if i.Alias != "" {
+		return i.Alias
+	}
+	if i.Star {
+		return ""
+	}
+	return lastSegment(i.Path)
+}
+
+type Annotation struct {
+	// Name is the annotation type as written, e.g. "Composable" or "kotlin.Deprecated".
+	Name string
+	Text string
+	Line int
+}
+
+type TypeParam struct {
+	Name   string
+	Bounds []string
+}
+
+// Decl is a class-like, function, property, typealias or constructor declaration.
+// Lines are 1-based. StartLine covers annotations and modifiers, Line is the line
+// of the declaring keyword and EndLine is the last line of the body, if any.
+type Decl struct {
+	Kind        string
+	Name        string
+	Receiver    string
+	TypeParams  []TypeParam
+	Modifiers   []string
+	Annotations []Annotation
+	Signature   string
+	Doc         string
+	DocLine     int
+	StartLine   int
+	Line        int
+	Col         int
+	EndLine     int
+	Parent      *Decl
+	Children    []*Decl
+}
+
+func (d *Decl) HasModifier(name string) bool {
+	for _, m := range d.Modifiers {
+		if m == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IsType reports whether the declaration introduces a class-like scope.
+func (d *Decl) IsType() bool {
+	switch d.Kind {
+	case "class", "interface", "object":
+		return true
+	}
+	return false
+}
+
+// QualifiedName returns the dotted name of the declaration relative to its package.
+func (d *Decl) QualifiedName() string {
+	parts := []string{d.Name}
+	for p := d.Parent; p != nil; p = p.Parent {
+		parts = append(parts, p.Name)
+	}
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+	return strings.Join(parts, ".")
+}
+
+// FQN returns the fully qualified name of a declaration in f.
+func (f *File) FQN(d *Decl) string {
+	if f.Package == "" {
+		return d.QualifiedName()
+	}
+	return f.Package + "." + d.QualifiedName()
+}
+
+// Walk visits every declaration depth-first. Returning false skips children.
+func (f *File) Walk(fn func(d *Decl) bool) {
+	var walk func(decls []*Decl)
+	walk = func(decls []*Decl) {
+		for _, d := range decls {
+			if fn(d) {
+				walk(d.Children)
+			}
+		}
+	}
+	walk(f.Decls)
+}
+
+// Enclosing returns the declarations containing line, outermost first.
+func (f *File) Enclosing(line int) []*Decl {
+	var chain []*Decl
+	decls := f.Decls
+	for {
+		var next *Decl
+		for _, d := range decls {
+			if d.StartLine <= line && line <= d.EndLine {
+				next = d
+				break
+			}
+		}
+		if next == nil {
+			return chain
+		}
+		chain = append(chain, next)
+		decls = next.Children
+	}
+}
+
+var modifiers = map[string]bool{
+	"public": true, "private": true, "internal": true, "protected": true,
+	"expect": true, "actual": true, "override": true, "open": true, "abstract": true,
+	"final": true, "sealed": true, "data": true, "inline": true, "value": true,
+	"enum": true, "annotation": true, "companion": true, "inner": true, "suspend": true,
+	"operator": true, "infix": true, "tailrec": true, "external": true, "const": true,
+	"lateinit": true, "vararg": true, "noinline": true, "crossinline": true,
+	"static": true, "synchronized": true, "native": true, "default": true, "transient": true,
+	"volatile": true, "strictfp": true,
+}
+
+var declKeywords = map[string]bool{
+	"class": true, "interface": true, "object": true, "fun": true, "val": true,
+	"var": true, "typealias": true, "constructor": true, "init": true,
+}
+
+var useSiteTargets = map[string]bool{
+	"file": true, "field": true, "property": true, "get": true, "set": true,
+	"receiver": true, "param": true, "setparam": true, "delegate": true,
+}
+
+type parser struct {
+	src  []byte
+	lang Lang
+	toks []Token
+	docs map[int]Token
+	pos  int
+}
+
+// Parse builds a best-effort declaration outline of Kotlin (or Java) source.
+// Function bodies and initializers are skipped, so local declarations are not
+// reported. Malformed input never fails; unknown constructs are skipped.
+func Parse(src []byte, lang Lang) *File {
+	all := Lex(src, lang)
+	p := &parser{src: src, lang: lang, docs: make(map[int]Token)}
+	var pendingDoc *Token
+	for i := range all {
+		tok := all[i]
+		if tok.IsComment() {
+			if tok.Kind == DocComment {
+				pendingDoc = &all[i]
+			}
+			continue
+		}
+		if pendingDoc != nil {
+			p.docs[len(p.toks)] = *pendingDoc
+			pendingDoc = nil
+		}
+		p.toks = append(p.toks, tok)
+	}
+	f := &File{}
+	p.parseHeader(f)
+	f.Decls = p.parseDecls(nil)
+	return f
+}
+
+// ParseDeclarationLine parses a single line, such as a search hit, and returns
+// the declaration it starts, or nil for statements and expressions.
+func ParseDeclarationLine(line string) *Decl {
+	src := []byte(line)
+	p := &parser{src: src, docs: make(map[int]Token)}
+	for _, tok := range Lex(src, LangKotlin) {
+		if !tok.IsComment() {
+			p.toks = append(p.toks, tok)
+		}
+	}
+	if len(p.toks) == 0 || p.declKeywordAt(0) == "" {
+		return nil
+	}
+	return p.parseDecl(nil)
+}
+
+// ParsePackage returns only the package name, which is cheaper than Parse.
+func ParsePackage(src []byte) string {
+	toks := Lex(headerPrefix(src), LangKotlin)
+	for i, tok := range toks {
+		if tok.IsComment() {
+			continue
+		}
+		if tok.Kind == Punct && tok.Text == "@" {
+			continue
+		}
+		if tok.Kind == Ident && tok.Text == "package" {
+			var parts []string
+			for j := i + 1; j < len(toks); j++ {
+				t := toks[j]
+				if t.Kind == Ident && (len(parts) == 0 || toks[j-1].Text == ".") {
+					parts = append(parts, t.Text)
+					continue
+				}
+				if t.Kind == Punct && t.Text == "." {
+					continue
+				}
+				break
+			}
+			return strings.Join(parts, ".")
+		}
+		if tok.Kind == Ident && (declKeywords[tok.Text] || tok.Text == "import") {
+			return ""
+		}
+	}
+	return ""
+}
+
+// headerPrefix trims source to the part that can contain a package directive.
+func headerPrefix(src []byte) []byte {
+	const limit = 64 * 1024
+	if len(src) > limit {
+		return src[:limit]
+	}
+	return src
+}
+
+func (p *parser) peek(offset int) *Token {
+	if p.pos+offset < len(p.toks) && p.pos+offset >= 0 {
+		return &p.toks[p.pos+offset]
+	}
+	return nil
+}
+
+func (p *parser) is(offset int, kind TokenKind, text string) bool {
+	t := p.peek(offset)
+	return t != nil && t.Kind == kind && t.Text == text
+}
+
+func (p *parser) punct(offset int, text string) bool {
+	return p.is(offset, Punct, text)
+}
+
+func (p *parser) ident(offset int, text string) bool {
+	return p.is(offset, Ident, text)
+}
+
+func (p *parser) qualifiedName() (string, bool) {
+	var parts []string
+	for {
+		t := p.peek(0)
+		if t == nil || t.Kind != Ident {
+			break
+		}
+		parts = append(parts, t.Text)
+		p.pos++
+		if p.punct(0, ".") && p.peek(1) != nil && p.peek(1).Kind == Ident {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return strings.Join(parts, "."), len(parts) > 0
+}
+
+func (p *parser) parseHeader(f *File) {
+	for p.pos < len(p.toks) {
+		if p.punct(0, "@") && p.peek(1) != nil && useSiteTargets[p.peek(1).Text] && p.punct(2, ":") {
+			p.parseAnnotation()
+			continue
+		}
+		if p.punct(0, ";") {
+			p.pos++
+			continue
+		}
+		if p.ident(0, "package") {
+			p.pos++
+			f.Package, _ = p.qualifiedName()
+			continue
+		}
+		if p.ident(0, "import") {
+			line := p.peek(0).Line
+			p.pos++
+			if p.ident(0, "static") {
+				p.pos++
+			}
+			path, ok := p.qualifiedName()
+			if !ok {
+				continue
+			}
+			imp := Import{Path: path, Line: line}
+			if p.punct(0, ".") && p.punct(1, "*") {
+				imp.Star = true
+				p.pos += 2
+			} else if p.ident(0, "as") && p.peek(1) != nil && p.peek(1).Kind == Ident {
+				imp.Alias = p.peek(1).Text
+				p.pos += 2
+			}
+			f.Imports = append(f.Imports, imp)
+			continue
+		}
+		return
+	}
+}
+
+// parseDecls reads declarations until the closing brace of parent (or EOF).
+func (p *parser) parseDecls(parent *Decl) []*Decl {
+	var out []*Decl
+	for p.pos < len(p.toks) {
+		tok := p.peek(0)
+		if tok.Kind == Punct && tok.Text == "}" {
+			if parent != nil {
+				return out
+			}
+			p.pos++
+			continue
+		}
+		if p.startsDecl(0) {
+			if d := p.parseDecl(parent); d != nil {
+				out = append(out, d)
+			}
+			continue
+		}
+		if p.lang == LangJava && parent != nil && parent.IsType() && p.javaMethodAt(parent) {
+			out = append(out, p.parseJavaMethod(parent))
+			continue
+		}
+		p.skipToken()
+	}
+	return out
+}
+
+// javaMethodAt reports whether a Java method or constructor of parent starts
+// at the current token: annotations and modifiers, optional type parameters,
+// a return type (none for constructors), then the name and "(".
+func (p *parser) javaMethodAt(parent *Decl) bool {
+	// Members start after another member or the opening brace, never inside
+	// an initializer such as "= new Foo() {...}".
+	if prev := p.peek(-1); prev == nil || prev.Kind != Punct || prev.Text != ";" && prev.Text != "{" && prev.Text != "}" {
+		return false
+	}
+	save := p.pos
+	defer func() { p.pos = save }()
+	p.skipJavaModifiers(nil)
+	p.skipTypeArgs()
+	if t := p.peek(0); t != nil && t.Kind == Ident && p.punct(1, "(") {
+		return t.Text == parent.Name
+	}
+	if !p.skipJavaType() {
+		return false
+	}
+	t := p.peek(0)
+	return t != nil && t.Kind == Ident && p.punct(1, "(")
+}
+
+// skipJavaModifiers skips annotations and modifiers, adding them to d when
+// it is not nil.
+func (p *parser) skipJavaModifiers(d *Decl) {
+	for p.pos < len(p.toks) {
+		if p.punct(0, "@") && !p.ident(1, "interface") {
+			annotations := p.parseAnnotation()
+			if d != nil {
+				d.Annotations = append(d.Annotations, annotations...)
+			}
+			continue
+		}
+		t := p.peek(0)
+		if t.Kind != Ident || !modifiers[t.Text] {
+			return
+		}
+		if d != nil {
+			d.Modifiers = append(d.Modifiers, t.Text)
+		}
+		p.pos++
+	}
+}
+
+// skipJavaType skips a type such as int, java.util.List<String> or byte[].
+func (p *parser) skipJavaType() bool {
+	if _, ok := p.qualifiedName(); !ok {
+		return false
+	}
+	p.skipTypeArgs()
+	for p.punct(0, "[") && p.punct(1, "]") {
+		p.pos += 2
+	}
+	return true
+}
+
+// parseJavaMethod parses a method or constructor found by javaMethodAt. Like
+// Kotlin functions, methods are of kind "fun"; constructors are named
+// "constructor".
+func (p *parser) parseJavaMethod(parent *Decl) *Decl {
+	d := &Decl{Parent: parent, Kind: "fun", StartLine: p.peek(0).Line}
+	if doc, ok := p.docs[p.pos]; ok {
+		d.Doc = doc.Text
+		d.DocLine = doc.Line
+	}
+	for p.punct(0, "@") && !p.ident(1, "interface") {
+		d.Annotations = append(d.Annotations, p.parseAnnotation()...)
+	}
+	sigStart := p.pos
+	p.skipJavaModifiers(d)
+	if p.punct(0, "<") {
+		d.TypeParams = p.parseTypeParams()
+	}
+	if p.punct(1, "(") {
+		d.Kind, d.Name = "constructor", "constructor"
+	} else {
+		p.skipJavaType()
+		d.Name = p.peek(0).Text
+	}
+	d.Line, d.Col = p.peek(0).Line, p.peek(0).Col
+	p.pos++
+	sigEnd := -1
+	for p.pos < len(p.toks) && sigEnd < 0 {
+		t := p.peek(0)
+		switch {
+		case t.Kind == Punct && t.Text == "(":
+			p.skipGroup()
+		case t.Kind == Punct && t.Text == "{":
+			sigEnd = p.pos
+			p.skipGroup()
+		case t.Kind == Punct && t.Text == "}":
+			sigEnd = p.pos
+		case t.Kind == Punct && t.Text == ";":
+			sigEnd = p.pos
+			p.pos++
+		default:
+			p.pos++
+		}
+	}
+	if sigEnd < 0 {
+		sigEnd = p.pos
+	}
+	d.Signature = p.text(sigStart, sigEnd)
+	d.EndLine = max(p.prevLine(), d.Line)
+	return d
+}
+
+// startsDecl reports whether annotations/modifiers at offset lead to a declaration keyword.
+func (p *parser) startsDecl(offset int) bool {
+	return p.declKeywordAt(offset) != ""
+}
+
+// declKeywordAt returns the declaration keyword reached by skipping annotations
+// and modifiers at offset, or "" if the tokens do not start a declaration.
+func (p *parser) declKeywordAt(offset int) string {
+	save := p.pos
+	defer func() { p.pos = save }()
+	p.pos += offset
+	for p.pos < len(p.toks) {
+		if p.punct(0, "@") {
+			p.parseAnnotation()
+			continue
+		}
+		t := p.peek(0)
+		if t.Kind != Ident {
+			return ""
+		}
+		if t.Text == "fun" && p.ident(1, "interface") {
+			p.pos++
+			continue
+		}
+		if declKeywords[t.Text] {
+			if p.punct(1, ".") || p.punct(1, "=") {
+				return ""
+			}
+			return t.Text
+		}
+		if modifiers[t.Text] {
+			p.pos++
+			continue
+		}
+		return ""
+	}
+	return ""
+}
+
+func (p *parser) parseDecl(parent *Decl) *Decl {
+	d := &Decl{Parent: parent, StartLine: p.peek(0).Line}
+	if doc, ok := p.docs[p.pos]; ok {
+		d.Doc = doc.Text
+		d.DocLine = doc.Line
+	}
+	sigStart := -1
+	for {
+		if p.punct(0, "@") {
+			d.Annotations = append(d.Annotations, p.parseAnnotation()...)
+			continue
+		}
+		t := p.peek(0)
+		if sigStart < 0 {
+			sigStart = p.pos
+		}
+		if t.Text == "fun" && p.ident(1, "interface") {
+			d.Modifiers = append(d.Modifiers, "fun")
+			p.pos++
+			continue
+		}
+		if declKeywords[t.Text] {
+			break
+		}
+		d.Modifiers = append(d.Modifiers, t.Text)
+		p.pos++
+	}
+	kw := p.peek(0)
+	d.Kind = kw.Text
+	d.Line = kw.Line
+	d.Col = kw.Col
+	p.pos++
+
+	var sigEnd int
+	switch d.Kind {
+	case "init":
+		p.skipBody()
+		return nil
+	case "class", "interface":
+		sigEnd = p.parseTypeHeader(d)
+	case "object":
+		if t := p.peek(0); t != nil && t.Kind == Ident && !p.atBoundary(0) {
+			d.Name = t.Text
+			p.pos++
+		} else if d.HasModifier("companion") {
+			d.Name = "Companion"
+		}
+		sigEnd = p.parseTypeHeader(d)
+	case "fun", "constructor":
+		sigEnd = p.parseFunHeader(d)
+	case "val", "var":
+		sigEnd = p.parsePropertyHeader(d)
+	case "typealias":
+		if t := p.peek(0); t != nil && t.Kind == Ident {
+			d.Name = t.Text
+		}
+		p.skipUntilBoundary()
+		sigEnd = p.pos
+	}
+	if d.Name == "" {
+		return nil
+	}
+	d.Signature = p.text(sigStart, sigEnd)
+	if d.EndLine == 0 {
+		d.EndLine = max(p.prevLine(), d.Line)
+	}
+	return d
+}
+
+func (p *parser) parseAnnotation() []Annotation {
+	start := p.pos
+	line := p.peek(0).Line
+	p.pos++ // @
+	if t := p.peek(0); t != nil && useSiteTargets[t.Text] && p.punct(1, ":") {
+		p.pos += 2
+	}
+	if p.punct(0, "[") {
+		var out []Annotation
+		p.pos++
+		for p.pos < len(p.toks) && !p.punct(0, "]") {
+			s := p.pos
+			name, ok := p.qualifiedName()
+			if !ok {
+				p.skipToken()
+				continue
+			}
+			p.skipTypeArgs()
+			if p.punct(0, "(") {
+				p.skipGroup()
+			}
+			out = append(out, Annotation{Name: name, Text: p.text(s, p.pos), Line: line})
+		}
+		if p.punct(0, "]") {
+			p.pos++
+		}
+		return out
+	}
+	name, ok := p.qualifiedName()
+	if !ok {
+		return nil
+	}
+	p.skipTypeArgs()
+	if p.punct(0, "(") && p.peek(0).Line == p.toks[p.pos-1].EndLine {
+		p.skipGroup()
+	}
+	return []Annotation{{Name: name, Text: p.text(start, p.pos), Line: line}}
+}
+
+func (p *parser) parseTypeHeader(d *Decl) int {
+	if d.Name == "" {
+		if t := p.peek(0); t != nil && t.Kind == Ident {
+			d.Name = t.Text
+			p.pos++
+		}
+	}
+	if p.punct(0, "<") {
+		d.TypeParams = p.parseTypeParams()
+	}
+	for p.pos < len(p.toks) {
+		t := p.peek(0)
+		if t.Kind == Punct && t.Text == "{" {
+			sigEnd := p.pos
+			p.pos++
+			d.Children = p.parseDecls(d)
+			if p.punct(0, "}") {
+				d.EndLine = p.peek(0).Line
+				p.pos++
+			} else {
+				d.EndLine = p.prevLine()
+			}
+			return sigEnd
+		}
+		if p.declKeywordAt(0) == "constructor" {
+			// A primary constructor may carry annotations on its own line.
+			for !p.ident(0, "constructor") {
+				p.skipToken()
+			}
+			p.pos++
+			continue
+		}
+		if p.atBoundary(0) || t.Kind == Punct && t.Text == ";" {
+			break
+		}
+		if t.Kind == Ident && t.Text == "where" {
+			p.pos++
+			p.parseWhere(d)
+			continue
+		}
+		p.skipToken()
+	}
+	return p.pos
+}
+
+func (p *parser) parseFunHeader(d *Decl) int {
+	if p.punct(0, "<") {
+		d.TypeParams = p.parseTypeParams()
+	}
+	recvStart := p.pos
+	for p.pos < len(p.toks) {
+		t := p.peek(0)
+		if t.Kind == Punct && t.Text == "(" {
+			if p.pos > recvStart && p.peek(-1).Kind == Ident {
+				break
+			}
+			p.skipGroup()
+			continue
+		}
+		if t.Kind == Punct && t.Text == "<" {
+			p.skipTypeArgs()
+			continue
+		}
+		if p.atBoundary(0) || t.Kind == Punct && (t.Text == "{" || t.Text == "=" || t.Text == ":") {
+			break
+		}
+		p.pos++
+	}
+	if d.Kind == "constructor" {
+		d.Name = "constructor"
+	} else if p.pos > recvStart && p.peek(-1).Kind == Ident {
+		d.Name = p.peek(-1).Text
+		d.Receiver = p.receiverText(recvStart, p.pos-2)
+	}
+	return p.parseCallableTail(d, true)
+}
+
+func (p *parser) parsePropertyHeader(d *Decl) int {
+	if p.punct(0, "<") {
+		d.TypeParams = p.parseTypeParams()
+	}
+	if p.punct(0, "(") {
+		// Destructuring declarations do not introduce a named property.
+		p.skipUntilBoundary()
+		return p.pos
+	}
+	recvStart := p.pos
+	for p.pos < len(p.toks) {
+		t := p.peek(0)
+		if t.Kind == Punct && t.Text == "<" {
+			p.skipTypeArgs()
+			continue
+		}
+		if t.Kind == Punct && t.Text == "(" {
+			p.skipGroup()
+			continue
+		}
+		if p.pos > recvStart && p.atBoundary(0) {
+			break
+		}
+		if t.Kind == Punct && (t.Text == ":" || t.Text == "=" || t.Text == "{" || t.Text == ";") {
+			break
+		}
+		if t.Kind == Ident && (t.Text == "by" || t.Text == "get" || t.Text == "set") && p.pos > recvStart {
+			break
+		}
+		p.pos++
+	}
+	if p.pos > recvStart && p.peek(-1).Kind == Ident {
+		d.Name = p.peek(-1).Text
+		d.Receiver = p.receiverText(recvStart, p.pos-2)
+	}
+	return p.parseCallableTail(d, false)
+}
+
+// parseCallableTail consumes parameters, return type, where clauses, body or
+// initializer and accessors. It returns the token index where the signature ends.
+func (p *parser) parseCallableTail(d *Decl, isFun bool) int {
+	sigEnd := -1
+	for p.pos < len(p.toks) {
+		t := p.peek(0)
+		if t.Kind == Punct && t.Text == "(" {
+			p.skipGroup()
+			continue
+		}
+		if t.Kind == Punct && t.Text == "<" {
+			p.skipTypeArgs()
+			continue
+		}
+		if t.Kind == Ident && t.Text == "where" && sigEnd < 0 {
+			p.pos++
+			p.parseWhere(d)
+			continue
+		}
+		if p.atBoundary(0) || t.Kind == Punct && t.Text == ";" {
+			break
+		}
+		if t.Kind == Punct && t.Text == "{" {
+			if sigEnd < 0 {
+				sigEnd = p.pos
+			}
+			p.skipGroup()
+			if isFun {
+				break
+			}
+			continue
+		}
+		if t.Kind == Punct && t.Text == "=" || t.Kind == Ident && (t.Text == "by" || !isFun && (t.Text == "get" || t.Text == "set")) {
+			if sigEnd < 0 {
+				sigEnd = p.pos
+			}
+			p.pos++
+			p.skipUntilBoundary()
+			if isFun {
+				break
+			}
+			continue
+		}
+		p.pos++
+	}
+	if sigEnd < 0 {
+		sigEnd = p.pos
+	}
+	return sigEnd
+}
+
+func (p *parser) parseTypeParams() []TypeParam {
+	open := p.pos
+	p.skipTypeArgs()
+	closeIdx := p.pos - 1
+	var out []TypeParam
+	var cur *TypeParam
+	depth := 0
+	boundStart := -1
+	flush := func(end int) {
+		if cur != nil && boundStart >= 0 && end > boundStart {
+			cur.Bounds = append(cur.Bounds, p.text(boundStart, end))
+		}
+		boundStart = -1
+	}
+	for i := open + 1; i < closeIdx; i++ {
+		t := p.toks[i]
+		switch {
+		case t.Kind == Punct && (t.Text == "<" || t.Text == "(" || t.Text == "["):
+			depth++
+		case t.Kind == Punct && (t.Text == ">" || t.Text == ")" || t.Text == "]"):
+			depth--
+		case depth == 0 && t.Kind == Punct && t.Text == ",":
+			flush(i)
+			cur = nil
+			continue
+		case depth == 0 && t.Kind == Punct && t.Text == ":" && cur != nil:
+			boundStart = i + 1
+			continue
+		}
+		if depth == 0 && cur == nil && t.Kind == Ident && t.Text != "reified" && t.Text != "in" && t.Text != "out" && t.Text != "@" {
+			out = append(out, TypeParam{Name: t.Text})
+			cur = &out[len(out)-1]
+		}
+	}
+	flush(closeIdx)
+	return out
+}
+
+func (p *parser) parseWhere(d *Decl) {
+	for p.pos < len(p.toks) {
+		t := p.peek(0)
+		if t.Kind != Ident || !p.punct(1, ":") {
+			return
+		}
+		name := t.Text
+		p.pos += 2
+		start := p.pos
+		for p.pos < len(p.toks) && !p.punct(0, ",") && !p.punct(0, "{") && !p.punct(0, "=") && !p.atBoundary(0) {
+			if p.punct(0, "<") {
+				p.skipTypeArgs()
+				continue
+			}
+			p.pos++
+		}
+		bound := p.text(start, p.pos)
+		for i := range d.TypeParams {
+			if d.TypeParams[i].Name == name {
+				d.TypeParams[i].Bounds = append(d.TypeParams[i].Bounds, bound)
+			}
+		}
+		if !p.punct(0, ",") {
+			return
+		}
+		p.pos++
+	}
+}
+
+// atBoundary reports whether the token at offset starts a new declaration or
+// closes the enclosing scope on a fresh line.
+func (p *parser) atBoundary(offset int) bool {
+	t := p.peek(offset)
+	if t == nil {
+		return true
+	}
+	prev := p.peek(offset - 1)
+	if prev == nil || t.Line <= prev.EndLine {
+		return false
+	}
+	if t.Kind == Punct && t.Text == "}" {
+		return true
+	}
+	return p.startsDecl(offset)
+}
+
+func (p *parser) skipUntilBoundary() {
+	for p.pos < len(p.toks) {
+		if p.atBoundary(0) || p.punct(0, ";") || p.punct(0, "}") {
+			return
+		}
+		p.skipToken()
+	}
+}
+
+func (p *parser) skipBody() {
+	for p.pos < len(p.toks) && !p.punct(0, "{") && !p.atBoundary(0) {
+		p.pos++
+	}
+	if p.punct(0, "{") {
+		p.skipGroup()
+	}
+}
+
+// skipToken advances past one token, or a whole bracketed group.
+func (p *parser) skipToken() {
+	t := p.peek(0)
+	if t.Kind == Punct && (t.Text == "(" || t.Text == "[" || t.Text == "{") {
+		p.skipGroup()
+		return
+	}
+	p.pos++
+}
+
+func (p *parser) skipGroup() {
+	depth := 0
+	for p.pos < len(p.toks) {
+		t := p.peek(0)
+		p.pos++
+		if t.Kind != Punct {
+			continue
+		}
+		switch t.Text {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+			if depth <= 0 {
+				return
+			}
+		}
+	}
+}
+
+// skipTypeArgs skips a <...> group if present. It gives up at tokens that
+// cannot appear in type arguments so comparisons do not swallow input.
+func (p *parser) skipTypeArgs() {
+	if !p.punct(0, "<") {
+		return
+	}
+	save := p.pos
+	depth := 0
+	for p.pos < len(p.toks) {
+		t := p.peek(0)
+		p.pos++
+		if t.Kind == Punct {
+			switch t.Text {
+			case "<":
+				depth++
+			case ">":
+				depth--
+				if depth == 0 {
+					return
+				}
+			case "(", "[":
+				p.pos--
+				p.skipGroup()
+			case "{", "}", ";", "=", "&&", "||":
+				p.pos = save + 1
+				return
+			}
+		}
+	}
+	p.pos = save + 1
+}
+
+func (p *parser) prevLine() int {
+	if p.pos == 0 {
+		return 1
+	}
+	return p.toks[p.pos-1].EndLine
+}
+
+func (p *parser) text(from, to int) string {
+	if from >= to || from >= len(p.toks) {
+		return ""
+	}
+	end := p.toks[to-1].End
+	return collapseSpace(string(p.src[p.toks[from].Start:end]))
+}
+
+// receiverText returns the receiver type written before the dot at index dot,
+// or "" when the declaration has no receiver.
+func (p *parser) receiverText(from, dot int) string {
+	if dot <= from || dot >= len(p.toks) {
+		return ""
+	}
+	t := p.toks[dot]
+	if t.Kind != Punct || (t.Text != "." && t.Text != "?.") {
+		return ""
+	}
+	text := p.text(from, dot)
+	if t.Text == "?." {
+		text += "?"
+	}
+	return text
+}
+
+func collapseSpace(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func lastSegment(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
@@ -0,0 +1,267 @@
+package kotlin
+
+import (
+	"strings"
+	"testing"
+)
+
+const sample = `/*
+ * Copyright header
+ */
+@file:JvmName("FlowKt")
+package kotlinx.coroutines.flow
+
+import kotlinx.coroutines.*
+import kotlin.jvm.JvmName as Named
+
+/**
+ * A cold stream.
+ */
+public interface Flow<out T> {
+    public suspend fun collect(collector: FlowCollector<T>)
+}
+
+@Deprecated(
+    "use other",
+    level = DeprecationLevel.ERROR
+)
+public fun <T, R> Flow<T>.map(transform: suspend (value: T) -> R): Flow<R> = transform {
+    val s = "} fun fake() {"
+    emit(it)
+}
+
+public val <T> Flow<T>?.isNull: Boolean
+    get() = this == null
+
+public fun <S : CoroutineScope> S.launchIn(flow: Flow<*>): Job where S : Any {
+    return launch { }
+}
+
+internal class Impl @Inject constructor(private val x: Int) : Flow<Int> {
+    companion object {
+        const val MAX = 1
+    }
+
+    override suspend fun collect(collector: FlowCollector<Int>) {
+        // } not a brace
+    }
+}
+
+public typealias Alias<T> = Flow<T>
+`
+
+func TestParseOutline(t *testing.T) {
+	f := Parse([]byte(sample), LangKotlin)
+	if f.Package != "kotlinx.coroutines.flow" {
+		t.Fatalf("unexpected package: %q", f.Package)
+	}
+	if len(f.Imports) != 2 || !f.Imports[0].Star || f.Imports[1].Name() != "Named" {
+		t.Fatalf("unexpected imports: %+v", f.Imports)
+	}
+
+	var names []string
+	byName := map[string]*Decl{}
+	f.Walk(func(d *Decl) bool {
+		names = append(names, d.QualifiedName())
+		byName[d.QualifiedName()] = d
+		return true
+	})
+	want := []string{"Flow", "Flow.collect", "map", "isNull", "launchIn", "Impl", "Impl.Companion", "Impl.Companion.MAX", "Impl.collect", "Alias"}
+	if len(names) != len(want) {
+		t.Fatalf("unexpected declarations: %v", names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("unexpected declarations: %v", names)
+		}
+	}
+
+	flow := byName["Flow"]
+	if flow.Doc == "" || flow.Kind != "interface" || flow.StartLine != 13 || flow.EndLine != 15 {
+		t.Fatalf("unexpected Flow decl: %+v", flow)
+	}
+	mapDecl := byName["map"]
+	if mapDecl.Receiver != "Flow<T>" || len(mapDecl.Annotations) != 1 || mapDecl.Annotations[0].Name != "Deprecated" {
+		t.Fatalf("unexpected map decl: %+v", mapDecl)
+	}
+	if mapDecl.Signature != "public fun <T, R> Flow<T>.map(transform: suspend (value: T) -> R): Flow<R>" {
+		t.Fatalf("unexpected map signature: %q", mapDecl.Signature)
+	}
+	if mapDecl.StartLine != 17 || mapDecl.EndLine != 24 {
+		t.Fatalf("unexpected map lines: %d-%d", mapDecl.StartLine, mapDecl.EndLine)
+	}
+	if got := byName["isNull"].Receiver; got != "Flow<T>?" {
+		t.Fatalf("unexpected property receiver: %q", got)
+	}
+	launchIn := byName["launchIn"]
+	if len(launchIn.TypeParams) != 1 || len(launchIn.TypeParams[0].Bounds) != 2 {
+		t.Fatalf("unexpected type params: %+v", launchIn.TypeParams)
+	}
+	if byName["Impl"].Signature != "internal class Impl @Inject constructor(private val x: Int) : Flow<Int>" {
+		t.Fatalf("unexpected class signature: %q", byName["Impl"].Signature)
+	}
+
+	chain := f.Enclosing(39)
+	if len(chain) != 2 || chain[1].Name != "collect" {
+		t.Fatalf("unexpected enclosing chain: %+v", chain)
+	}
+}
+
+const javaSample = `package com.example;
+
+import java.util.List;
+
+/** A widget. */
+public class Widget<T> extends Base implements Runnable {
+    private final Runnable r = new Runnable() {
+        public void run() {}
+    };
+    private int count = compute(1);
+
+    /**
+     * Creates a widget.
+     */
+    public Widget(int count) {
+        this.count = count;
+    }
+
+    /** Runs it. */
+    @Override
+    public void run() {
+        if (count > 0) { run(); }
+    }
+
+    public static <R extends T> List<R>[] items(String name, int... ids) throws Exception {
+        return null;
+    }
+
+    abstract byte[] bytes();
+}
+`
+
+func TestParseJavaMethods(t *testing.T) {
+	f := Parse([]byte(javaSample), LangJava)
+	var names []string
+	byName := map[string]*Decl{}
+	f.Walk(func(d *Decl) bool {
+		names = append(names, d.QualifiedName())
+		byName[d.QualifiedName()] = d
+		return true
+	})
+	want := []string{"Widget", "Widget.constructor", "Widget.run", "Widget.items", "Widget.bytes"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected declarations: %v", names)
+	}
+	ctor := byName["Widget.constructor"]
+	if ctor.Kind != "constructor" || ctor.Doc == "" || ctor.Signature != "public Widget(int count)" || ctor.Line != 15 || ctor.EndLine != 17 {
+		t.Fatalf("unexpected constructor: %+v", ctor)
+	}
+	run := byName["Widget.run"]
+	if run.Kind != "fun" || !strings.Contains(run.Doc, "Runs it.") || len(run.Annotations) != 1 || run.StartLine != 20 || run.Line != 21 || run.EndLine != 23 {
+		t.Fatalf("unexpected run method: %+v", run)
+	}
+	if run.Signature != "public void run()" || !run.HasModifier("public") {
+		t.Fatalf("unexpected run signature: %q %v", run.Signature, run.Modifiers)
+	}
+	items := byName["Widget.items"]
+	if items.Signature != "public static <R extends T> List<R>[] items(String name, int... ids) throws Exception" || len(items.TypeParams) != 1 {
+		t.Fatalf("unexpected items method: %+v", items)
+	}
+	if bytes := byName["Widget.bytes"]; bytes.Signature != "abstract byte[] bytes()" || bytes.EndLine != 29 {
+		t.Fatalf("unexpected abstract method: %+v", bytes)
+	}
+}
+
+func TestParsePackage(t *testing.T) {
+	if got := ParsePackage([]byte(sample)); got != "kotlinx.coroutines.flow" {
+		t.Fatalf("unexpected package: %q", got)
+	}
+	if got := ParsePackage([]byte("class Foo\n")); got != "" {
+		t.Fatalf("expected default package, got %q", got)
+	}
+}
+
+func TestLexStringsAndComments(t *testing.T) {
+	toks := Lex([]byte("val s = \"a ${b + \"}\"} // c\" /* x /* y */ z */ d"), LangKotlin)
+	var kinds []TokenKind
+	for _, tok := range toks {
+		kinds = append(kinds, tok.Kind)
+	}
+	want := []TokenKind{Ident, Ident, Punct, String, BlockComment, Ident}
+	if len(kinds) != len(want) {
+		t.Fatalf("unexpected tokens: %+v", toks)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("unexpected tokens: %+v", toks)
+		}
+	}
+}
+
+func TestRegionAt(t *testing.T) {
+	src := "/** Flow docs */\nval flow = \"flow\" // flow\n"
+	toks := Lex([]byte(src), LangKotlin)
+	for _, tc := range []struct {
+		offset int
+		want   Region
+	}{
+		{strings.Index(src, "Flow"), RegionComment},
+		{strings.Index(src, "flow ="), RegionCode},
+		{strings.Index(src, "\"flow") + 1, RegionString},
+		{strings.LastIndex(src, "flow"), RegionComment},
+		{strings.Index(src, " = "), RegionCode},
+	} {
+		if got := RegionAt(toks, tc.offset); got != tc.want {
+			t.Fatalf("offset %d: got %s, want %s", tc.offset, got, tc.want)
+		}
+	}
+}
+
+func TestRegionAtTemplates(t *testing.T) {
+	src := `val s = "id $flowId: ${flow.map { "a$b" } /* c */} end"` + "\n"
+	toks := Lex([]byte(src), LangKotlin)
+	for _, tc := range []struct {
+		at   string
+		want Region
+	}{
+		{"id ", RegionString},
+		{"$flowId", RegionString},
+		{"flowId", RegionCode},
+		{": ", RegionString},
+		{"flow.map", RegionCode},
+		{"a$b", RegionString},
+		{"b\"", RegionCode},
+		{"c */", RegionComment},
+		{"} end", RegionString},
+		{"end", RegionString},
+	} {
+		if got := RegionAt(toks, strings.Index(src, tc.at)); got != tc.want {
+			t.Fatalf("%q: got %s, want %s", tc.at, got, tc.want)
+		}
+	}
+}
+
+func TestParseDoc(t *testing.T) {
+	doc := ParseDoc(`/**
+     * Collects the given [Flow] with a [collector][FlowCollector].
+     * Uses {@link java.util.List the list} and {@code x < y}.
+     *
+     * @param collector the collector
+     *   that receives values.
+     * @return nothing
+     * @throws IllegalStateException if reused
+     * @sample kotlinx.coroutines.samples.collectSample
+     */`)
+	if doc.Body != "Collects the given Flow with a collector.\nUses the list and `x < y`." {
+		t.Fatalf("unexpected body: %q", doc.Body)
+	}
+	if len(doc.Tags) != 4 {
+		t.Fatalf("unexpected tags: %+v", doc.Tags)
+	}
+	if doc.Tags[0].Name != "param" || doc.Tags[0].Subject != "collector" || doc.Tags[0].Text != "the collector\nthat receives values." {
+		t.Fatalf("unexpected param tag: %+v", doc.Tags[0])
+	}
+	if doc.Tags[3].Name != "sample" || doc.Tags[3].Subject != "kotlinx.coroutines.samples.collectSample" {
+		t.Fatalf("unexpected sample tag: %+v", doc.Tags[3])
+	}
+}
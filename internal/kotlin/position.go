@@ -0,0 +1,84 @@
+package kotlin
+
+import "sort"
+
+// TokenAt returns the index of the token covering line:col (1-based, byte
+// column), or -1 if the position falls between tokens.
+func TokenAt(toks []Token, line, col int) int {
+	for i, t := range toks {
+		if t.Line > line {
+			break
+		}
+		if t.Line == line && t.EndLine == line && t.Col <= col && col < t.Col+(t.End-t.Start) {
+			return i
+		}
+		if t.Line < line && t.EndLine >= line && t.IsComment() {
+			return i
+		}
+	}
+	return -1
+}
+
+// QualifierAt returns the dotted identifier chain written immediately before
+// toks[idx], e.g. "a.b" for the C in a.b.C, or "" if there is none.
+func QualifierAt(toks []Token, idx int) string {
+	qualifier := ""
+	for i := idx; i >= 2; i -= 2 {
+		dot, prev := toks[i-1], toks[i-2]
+		if dot.Kind != Punct || dot.Text != "." || prev.Kind != Ident {
+			break
+		}
+		if qualifier == "" {
+			qualifier = prev.Text
+		} else {
+			qualifier = prev.Text + "." + qualifier
+		}
+	}
+	return qualifier
+}
+
+// Region is the lexical kind of source text at a position.
+type Region int
+
+const (
+	RegionCode Region = iota
+	RegionComment
+	RegionString
+)
+
+func (r Region) String() string {
+	switch r {
+	case RegionComment:
+		return "comment"
+	case RegionString:
+		return "string"
+	default:
+		return "code"
+	}
+}
+
+// RegionAt classifies the byte at offset: inside a comment (including KDoc),
+// inside a string or char literal, or code. Template expressions of a string
+// ($name, ${...}) are classified by their own content, so identifiers in them
+// are code. Whitespace between tokens is code.
+func RegionAt(toks []Token, offset int) Region {
+	i := sort.Search(len(toks), func(i int) bool { return toks[i].Start > offset }) - 1
+	if i < 0 || offset >= toks[i].End {
+		return RegionCode
+	}
+	switch t := toks[i]; {
+	case t.IsComment():
+		return RegionComment
+	case t.Kind == String:
+		for _, tpl := range t.Templates {
+			if offset >= tpl[0] && offset < tpl[1] {
+				body := Lex([]byte(t.Text[tpl[0]-t.Start:tpl[1]-t.Start]), LangKotlin)
+				return RegionAt(body, offset-tpl[0])
+			}
+		}
+		return RegionString
+	case t.Kind == Char:
+		return RegionString
+	}
+	return RegionCode
+}
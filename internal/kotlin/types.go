@@ -0,0 +1,93 @@
+package kotlin
+
+import "strings"
+
+// BaseType strips annotations, nullability, type arguments and redundant
+// parentheses from a type reference, returning the (possibly qualified)
+// classifier name. Function types yield "".
+func BaseType(t string) string {
+	t = strings.TrimSpace(t)
+	for strings.HasPrefix(t, "@") {
+		end := strings.IndexAny(t, " \t")
+		if end < 0 {
+			return ""
+		}
+		t = strings.TrimSpace(t[end:])
+	}
+	t = strings.TrimSpace(strings.TrimPrefix(t, "suspend "))
+	t = strings.TrimSuffix(t, "?")
+	if strings.HasPrefix(t, "(") {
+		if !strings.HasSuffix(t, ")") || strings.Contains(t, "->") {
+			return ""
+		}
+		return BaseType(t[1 : len(t)-1])
+	}
+	if strings.Contains(t, "->") {
+		return ""
+	}
+	if i := strings.Index(t, "<"); i >= 0 {
+		t = t[:i]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(t, "?"))
+}
+
+// SimpleName returns the last segment of a dotted name.
+func SimpleName(name string) string {
+	return lastSegment(name)
+}
+
+// ReceiverTypes returns the classifier names a declaration's receiver can bind
+// to: the receiver itself, or the bounds of a receiver type parameter. An
+// unbounded type parameter yields nothing.
+func (d *Decl) ReceiverTypes() []string {
+	base := BaseType(d.Receiver)
+	if base == "" {
+		return nil
+	}
+	for _, tp := range d.TypeParams {
+		if tp.Name != base {
+			continue
+		}
+		var out []string
+		for _, bound := range tp.Bounds {
+			if b := BaseType(bound); b != "" && b != "Any" {
+				out = append(out, b)
+			}
+		}
+		return out
+	}
+	return []string{base}
+}
+
+// ResolveType returns the fully qualified candidates a type name written in f
+// may refer to, using explicit imports, aliases, star imports and the file package.
+func (f *File) ResolveType(name string) []string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil
+	}
+	head, rest := name, ""
+	if i := strings.Index(name, "."); i >= 0 {
+		head, rest = name[:i], name[i:]
+	}
+	for _, imp := range f.Imports {
+		if !imp.Star && imp.Name() == head {
+			return []string{imp.Path + rest}
+		}
+	}
+	var out []string
+	if rest != "" {
+		out = append(out, name)
+	}
+	if f.Package != "" {
+		out = append(out, f.Package+"."+name)
+	} else {
+		out = append(out, name)
+	}
+	for _, imp := range f.Imports {
+		if imp.Star {
+			out = append(out, imp.Path+"."+name)
+		}
+	}
+	return out
+}
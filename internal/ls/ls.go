@@ -0,0 +1,224 @@
+package ls
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/respawn-app/ksrc/internal/resolve"
+	"github.com/respawn-app/ksrc/internal/srcjar"
+)
+
+// File is a source file inside a jar.
+type File struct {
+	Coord resolve.Coord
+	Inner string
+	Bytes int64
+	// Lines is set by CountLines.
+	Lines int
+
+	entry srcjar.Entry
+}
+
+func (f File) FileID() string {
+	return resolve.FormatFileID(f.Coord, f.Inner)
+}
+
+// List returns the Kotlin and Java files of the jars accepted by keep, in jar
+// order and then by path. Sizes come from the jar's directory, so nothing is
+// decompressed; see CountLines.
+func List(archives *srcjar.Archives, jars []resolve.SourceJar, keep func(inner string) bool) ([]File, error) {
+	order := make(map[resolve.Coord]int, len(jars))
+	for i, jar := range jars {
+		if _, ok := order[jar.Coord]; !ok {
+			order[jar.Coord] = i
+		}
+	}
+	var files []File
+	err := srcjar.Walk(archives, jars, func(name string) bool {
+		return srcjar.IsSource(name) && (keep == nil || keep(name))
+	}, func(e srcjar.Entry) error {
+		files = append(files, File{Coord: e.Jar.Coord, Inner: e.Name(), Bytes: int64(e.File.UncompressedSize64), entry: e})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if a, b := order[files[i].Coord], order[files[j].Coord]; a != b {
+			return a < b
+		}
+		return files[i].Inner < files[j].Inner
+	})
+	return files, nil
+}
+
+// CountLines reads each file listed by List and sets its Lines. The archives
+// given to List must still be open.
+func CountLines(files []File) error {
+	for i := range files {
+		if files[i].entry.File == nil {
+			continue
+		}
+		data, err := files[i].entry.Read()
+		if err != nil {
+			return err
+		}
+		files[i].Lines = countLines(data)
+	}
+	return nil
+}
+
+func countLines(data []byte) int {
+	n := bytes.Count(data, []byte("\n"))
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		n++
+	}
+	return n
+}
+
+// Find returns the files whose path fuzzily matches query, best first. The
+// query's characters must appear in order in the path, ignoring case; matches
+// inside the file name, at word starts and in runs score higher. A trailing
+// "Kt" matches the file of a Kotlin facade class (FlowKt -> Flow.kt).
+func Find(files []File, query string) []File {
+	type scored struct {
+		file  File
+		score int
+	}
+	var hits []scored
+	for _, f := range files {
+		if score, ok := fuzzyScore(query, f.Inner); ok {
+			hits = append(hits, scored{f, score})
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return len(hits[i].file.Inner) < len(hits[j].file.Inner)
+	})
+	out := make([]File, len(hits))
+	for i, h := range hits {
+		out[i] = h.file
+	}
+	return out
+}
+
+func fuzzyScore(query, inner string) (int, bool) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return 0, false
+	}
+	base := path.Base(inner)
+	stem := strings.TrimSuffix(base, path.Ext(base))
+	if strings.EqualFold(stem, query) || strings.EqualFold(stem+"Kt", query) {
+		return 1000, true
+	}
+	if score, ok := subsequence(query, base); ok {
+		return 500 + score, true
+	}
+	return subsequence(query, inner)
+}
+
+// subsequence matches query against text in order, ignoring case, and scores
+// word-start and consecutive matches.
+func subsequence(query, text string) (int, bool) {
+	q := []rune(strings.ToLower(query))
+	t := []rune(text)
+	score, qi, prev := 0, 0, -2
+	for i := 0; i < len(t) && qi < len(q); i++ {
+		if unicode.ToLower(t[i]) != q[qi] {
+			continue
+		}
+		switch {
+		case i == prev+1:
+			score += 5
+		case i == 0 || strings.ContainsRune("/._-$", t[i-1]) || unicode.IsUpper(t[i]) && unicode.IsLower(t[i-1]):
+			score += 3
+		}
+		score++
+		prev = i
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	return score, true
+}
+
+// WriteTree prints the files as a directory tree per module. Directories show
+// their file count and total lines; chains of directories without files are
+// joined (kotlinx/coroutines/).
+func WriteTree(w io.Writer, files []File) {
+	var coord resolve.Coord
+	var root *dir
+	flush := func() {
+		if root != nil {
+			fmt.Fprintln(w, coord.String())
+			root.write(w, "  ")
+		}
+	}
+	for _, f := range files {
+		if root == nil || f.Coord != coord {
+			flush()
+			coord, root = f.Coord, newDir("")
+		}
+		root.add(strings.Split(f.Inner, "/"), f)
+	}
+	flush()
+}
+
+type dir struct {
+	name  string
+	dirs  map[string]*dir
+	files []File
+	count int
+	lines int
+}
+
+func newDir(name string) *dir {
+	return &dir{name: name, dirs: make(map[string]*dir)}
+}
+
+func (d *dir) add(parts []string, f File) {
+	d.count++
+	d.lines += f.Lines
+	if len(parts) == 1 {
+		d.files = append(d.files, f)
+		return
+	}
+	child, ok := d.dirs[parts[0]]
+	if !ok {
+		child = newDir(parts[0])
+		d.dirs[parts[0]] = child
+	}
+	child.add(parts[1:], f)
+}
+
+func (d *dir) write(w io.Writer, indent string) {
+	names := make([]string, 0, len(d.dirs))
+	for name := range d.dirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		child := d.dirs[name]
+		label := child.name + "/"
+		for len(child.files) == 0 && len(child.dirs) == 1 {
+			for _, only := range child.dirs {
+				child = only
+			}
+			label += child.name + "/"
+		}
+		fmt.Fprintf(w, "%s%s  [files: %d]  [lines: %d]\n", indent, label, child.count, child.lines)
+		child.write(w, indent+"  ")
+	}
+	for _, f := range d.files {
+		fmt.Fprintf(w, "%s%s  [lines: %d]  [bytes: %d]\n", indent, path.Base(f.Inner), f.Lines, f.Bytes)
+	}
+}
@@ -0,0 +1,119 @@
+package ls
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/respawn-app/ksrc/internal/resolve"
+	"github.com/respawn-app/ksrc/internal/srcjar"
+)
+
+var coord = resolve.Coord{Group: "org.example", Artifact: "lib", Version: "1.0"}
+
+func files(inners ...string) []File {
+	var out []File
+	for i, inner := range inners {
+		out = append(out, File{Coord: coord, Inner: inner, Lines: i + 1, Bytes: int64(10 * (i + 1))})
+	}
+	return out
+}
+
+func inners(files []File) []string {
+	var out []string
+	for _, f := range files {
+		out = append(out, f.Inner)
+	}
+	return out
+}
+
+func TestFindRanksFileNamesFirst(t *testing.T) {
+	all := files(
+		"commonMain/kotlinx/coroutines/flow/Channels.kt",
+		"commonMain/kotlinx/coroutines/flow/Flow.kt",
+		"commonMain/kotlinx/coroutines/flow/internal/FlowCoroutine.kt",
+		"commonMain/kotlinx/coroutines/Builders.kt",
+	)
+	got := inners(Find(all, "FlowKt"))
+	if len(got) == 0 || got[0] != "commonMain/kotlinx/coroutines/flow/Flow.kt" {
+		t.Fatalf("expected Flow.kt first for a facade name, got %v", got)
+	}
+	got = inners(Find(all, "flowco"))
+	if len(got) == 0 || got[0] != "commonMain/kotlinx/coroutines/flow/internal/FlowCoroutine.kt" {
+		t.Fatalf("expected FlowCoroutine.kt first, got %v", got)
+	}
+	got = inners(Find(all, "flow/chan"))
+	if !reflect.DeepEqual(got, []string{"commonMain/kotlinx/coroutines/flow/Channels.kt"}) {
+		t.Fatalf("expected path query to match Channels.kt only, got %v", got)
+	}
+	if got := Find(all, "zzz"); len(got) != 0 {
+		t.Fatalf("expected no matches, got %v", inners(got))
+	}
+}
+
+func TestWriteTree(t *testing.T) {
+	var out bytes.Buffer
+	WriteTree(&out, files(
+		"commonMain/kotlinx/coroutines/Builders.kt",
+		"commonMain/kotlinx/coroutines/flow/Flow.kt",
+		"jvmMain/kotlinx/coroutines/Dispatchers.kt",
+	))
+	want := `org.example:lib:1.0
+  commonMain/kotlinx/coroutines/  [files: 2]  [lines: 3]
+    flow/  [files: 1]  [lines: 2]
+      Flow.kt  [lines: 2]  [bytes: 20]
+    Builders.kt  [lines: 1]  [bytes: 10]
+  jvmMain/kotlinx/coroutines/  [files: 1]  [lines: 3]
+    Dispatchers.kt  [lines: 3]  [bytes: 30]
+`
+	if out.String() != want {
+		t.Fatalf("unexpected tree:\n%s", out.String())
+	}
+}
+
+func TestCountLines(t *testing.T) {
+	for data, want := range map[string]int{"": 0, "a": 1, "a\n": 1, "a\nb": 2, "a\nb\n": 2} {
+		if got := countLines([]byte(data)); got != want {
+			t.Fatalf("countLines(%q) = %d, want %d", data, got, want)
+		}
+	}
+}
+
+func TestListCountsLinesOnlyOnRequest(t *testing.T) {
+	jarPath := filepath.Join(t.TempDir(), "lib-sources.jar")
+	f, err := os.Create(jarPath)
+	if err != nil {
+		t.Fatalf("create jar: %v", err)
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range map[string]string{"b/B.kt": "class B\n\nfun b() {}", "a/A.java": "class A {}\n", "META-INF/MANIFEST.MF": "x\n"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip entry: %v", err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+	f.Close()
+
+	archives := srcjar.NewArchives()
+	defer archives.Close()
+	listed, err := List(archives, []resolve.SourceJar{{Coord: coord, Path: jarPath}}, nil)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if !reflect.DeepEqual(inners(listed), []string{"a/A.java", "b/B.kt"}) || listed[0].Bytes != 11 || listed[1].Bytes != 19 || listed[1].Lines != 0 {
+		t.Fatalf("unexpected listing: %+v", listed)
+	}
+	if err := CountLines(listed[1:]); err != nil {
+		t.Fatalf("CountLines: %v", err)
+	}
+	if listed[0].Lines != 0 || listed[1].Lines != 3 {
+		t.Fatalf("expected lines counted for B.kt only, got %d and %d", listed[0].Lines, listed[1].Lines)
+	}
+}
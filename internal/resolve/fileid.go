@@ -2,6 +2,7 @@ package resolve
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -21,3 +22,49 @@ func ParseFileID(value string) (Coord, string, error) {
 	}
 	return coord, path, nil
 }
+
+// FormatFileID builds group:artifact:version!/path/inside.jar
+func FormatFileID(coord Coord, inner string) string {
+	return coord.String() + "!/" + strings.TrimPrefix(inner, "/")
+}
+
+// ParseFilePosition parses group:artifact:version!/path/inside.jar:line:col
+func ParseFilePosition(value string) (Coord, string, int, int, error) {
+	idx := strings.Index(value, "!/")
+	if idx < 0 {
+		return Coord{}, "", 0, 0, fmt.Errorf("invalid file position: %q (expected <file-id>:<line>:<col>)", value)
+	}
+	rest := value[idx:]
+	parts := strings.Split(rest, ":")
+	if len(parts) < 3 {
+		return Coord{}, "", 0, 0, fmt.Errorf("invalid file position: %q (expected <file-id>:<line>:<col>)", value)
+	}
+	line, errLine := strconv.Atoi(parts[len(parts)-2])
+	col, errCol := strconv.Atoi(parts[len(parts)-1])
+	if errLine != nil || errCol != nil || line <= 0 || col <= 0 {
+		return Coord{}, "", 0, 0, fmt.Errorf("invalid file position: %q (expected <file-id>:<line>:<col>)", value)
+	}
+	fileID := value[:idx] + strings.Join(parts[:len(parts)-2], ":")
+	coord, inner, err := ParseFileID(fileID)
+	if err != nil {
+		return Coord{}, "", 0, 0, err
+	}
+	return coord, inner, line, col, nil
+}
+
+// ParseFileLine parses group:artifact:version!/path/inside.jar:line
+func ParseFileLine(value string) (Coord, string, int, error) {
+	idx := strings.LastIndex(value, ":")
+	if idx < 0 || !strings.Contains(value, "!/") {
+		return Coord{}, "", 0, fmt.Errorf("invalid file line: %q (expected <file-id>:<line>)", value)
+	}
+	line, err := strconv.Atoi(value[idx+1:])
+	if err != nil || line <= 0 {
+		return Coord{}, "", 0, fmt.Errorf("invalid file line: %q (expected <file-id>:<line>)", value)
+	}
+	coord, inner, err := ParseFileID(value[:idx])
+	if err != nil {
+		return Coord{}, "", 0, err
+	}
+	return coord, inner, line, nil
+}
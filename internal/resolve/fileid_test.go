@@ -0,0 +1,29 @@
+package resolve
+
+import "testing"
+
+func TestParseFilePosition(t *testing.T) {
+	coord, inner, line, col, err := ParseFilePosition("org.jetbrains.kotlinx:kotlinx-coroutines-core:1.8.1!/commonMain/flow/Flow.kt:12:5")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if coord.String() != "org.jetbrains.kotlinx:kotlinx-coroutines-core:1.8.1" || inner != "commonMain/flow/Flow.kt" || line != 12 || col != 5 {
+		t.Fatalf("unexpected position: %s %s %d %d", coord, inner, line, col)
+	}
+	if _, _, _, _, err := ParseFilePosition("org.jetbrains.kotlinx:kotlinx-coroutines-core:1.8.1!/Flow.kt:12"); err == nil {
+		t.Fatal("expected error without column")
+	}
+}
+
+func TestParseFileLine(t *testing.T) {
+	coord, inner, line, err := ParseFileLine("org.jetbrains.kotlinx:kotlinx-coroutines-core-jvm:1.8.1!/jvmMain/Dispatchers.kt:42")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if coord.String() != "org.jetbrains.kotlinx:kotlinx-coroutines-core-jvm:1.8.1" || inner != "jvmMain/Dispatchers.kt" || line != 42 {
+		t.Fatalf("unexpected position: %s %s %d", coord, inner, line)
+	}
+	if _, _, _, err := ParseFileLine("org.jetbrains.kotlinx:kotlinx-coroutines-core:1.8.1!/Flow.kt"); err == nil {
+		t.Fatal("expected error without line")
+	}
+}
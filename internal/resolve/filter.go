@@ -77,19 +77,49 @@ func normalizeDots(s string) string {
 func FilterSources(sources []SourceJar, module, group, artifact, version string) []SourceJar {
 	out := make([]SourceJar, 0, len(sources))
 	for _, s := range sources {
-		if module != "" && !MatchModule(module, s.Coord) {
-			continue
-		}
-		if !MatchAny(group, s.Coord.Group) {
-			continue
+		if MatchCoord(s.Coord, module, group, artifact, version) {
+			out = append(out, s)
 		}
-		if !MatchAny(artifact, s.Coord.Artifact) {
-			continue
+	}
+	return out
+}
+
+// MatchCoord reports whether a coordinate passes module/group/artifact/version filters.
+func MatchCoord(c Coord, module, group, artifact, version string) bool {
+	if module != "" && !MatchModule(module, c) {
+		return false
+	}
+	return MatchAny(group, c.Group) && MatchAny(artifact, c.Artifact) && MatchAny(version, c.Version)
+}
+
+// Closure returns the coordinates reachable from roots by following edges,
+// roots included, keyed by Coord.String(). depth limits the number of edges
+// followed; a negative depth is unlimited.
+func Closure(roots []Coord, edges []Edge, depth int) map[string]bool {
+	children := make(map[string][]Coord)
+	for _, e := range edges {
+		key := e.From.String()
+		children[key] = append(children[key], e.To)
+	}
+	seen := make(map[string]bool, len(roots))
+	frontier := make([]Coord, 0, len(roots))
+	for _, c := range roots {
+		if !seen[c.String()] {
+			seen[c.String()] = true
+			frontier = append(frontier, c)
 		}
-		if !MatchAny(version, s.Coord.Version) {
-			continue
+	}
+	for level := 0; len(frontier) > 0 && (depth < 0 || level < depth); level++ {
+		var next []Coord
+		for _, c := range frontier {
+			for _, child := range children[c.String()] {
+				if !seen[child.String()] {
+					seen[child.String()] = true
+					next = append(next, child)
+				}
+			}
 		}
-		out = append(out, s)
+		frontier = next
 	}
-	return out
+	return seen
 }
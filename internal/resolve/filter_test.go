@@ -23,3 +23,23 @@ func TestMatchAny(t *testing.T) {
 		t.Fatal("expected no match")
 	}
 }
+
+func TestClosure(t *testing.T) {
+	core := Coord{Group: "io.ktor", Artifact: "ktor-client-core", Version: "2.3.0"}
+	utils := Coord{Group: "io.ktor", Artifact: "ktor-utils", Version: "2.3.0"}
+	io := Coord{Group: "io.ktor", Artifact: "ktor-io", Version: "2.3.0"}
+	other := Coord{Group: "org.slf4j", Artifact: "slf4j-api", Version: "2.0.0"}
+	edges := []Edge{{From: core, To: utils}, {From: utils, To: io}, {From: io, To: utils}, {From: other, To: io}}
+
+	all := Closure([]Coord{core}, edges, -1)
+	if len(all) != 3 || !all[core.String()] || !all[utils.String()] || !all[io.String()] {
+		t.Fatalf("unexpected closure: %v", all)
+	}
+	direct := Closure([]Coord{core}, edges, 1)
+	if len(direct) != 2 || !direct[utils.String()] {
+		t.Fatalf("unexpected depth-1 closure: %v", direct)
+	}
+	if none := Closure([]Coord{core}, edges, 0); len(none) != 1 {
+		t.Fatalf("unexpected depth-0 closure: %v", none)
+	}
+}
@@ -41,3 +41,9 @@ type SourceJar struct {
 	Coord Coord
 	Path  string
 }
+
+// Edge is a resolved dependency of one module on another.
+type Edge struct {
+	From Coord
+	To   Coord
+}
@@ -0,0 +1,109 @@
+package search
+
+import "sort"
+
+// Block is a contiguous range of lines in one file holding one or more hits
+// and the context lines around them.
+type Block struct {
+	FileID    string      `json:"fileId"`
+	File      string      `json:"path,omitempty"`
+	StartLine int         `json:"startLine"`
+	EndLine   int         `json:"endLine"`
+	Lines     []BlockLine `json:"lines"`
+}
+
+// BlockLine is one line of a Block. Column, Submatches, Class, Function,
+// Score and In are only set on hits.
+type BlockLine struct {
+	Line       int        `json:"line"`
+	Column     int        `json:"column,omitempty"`
+	Text       string     `json:"text"`
+	Hit        bool       `json:"hit"`
+	Submatches []Submatch `json:"submatches,omitempty"`
+	Class      *Scope     `json:"class,omitempty"`
+	Function   *Scope     `json:"function,omitempty"`
+	Score      int        `json:"score,omitempty"`
+	In         string     `json:"in,omitempty"`
+}
+
+// Blocks groups matches into blocks. Overlapping or adjacent windows in the
+// same file are merged so every line appears once; a line reported both as a
+// hit and as context is a hit. Blocks keep the order in which their first
+// line appears in matches.
+func Blocks(matches []Match) []Block {
+	var blocks []*Block
+	lines := make(map[*Block]map[int]BlockLine)
+	byFile := make(map[string][]*Block)
+	for _, m := range matches {
+		var target *Block
+		for _, b := range byFile[m.FileID] {
+			if m.Line >= b.StartLine-1 && m.Line <= b.EndLine+1 {
+				target = b
+				break
+			}
+		}
+		if target == nil {
+			target = &Block{FileID: m.FileID, File: m.File, StartLine: m.Line, EndLine: m.Line}
+			blocks = append(blocks, target)
+			byFile[m.FileID] = append(byFile[m.FileID], target)
+			lines[target] = make(map[int]BlockLine)
+		}
+		target.StartLine = min(target.StartLine, m.Line)
+		target.EndLine = max(target.EndLine, m.Line)
+		if existing, ok := lines[target][m.Line]; ok && (existing.Hit || m.Context) {
+			continue
+		}
+		line := BlockLine{Line: m.Line, Text: m.Text}
+		if !m.Context {
+			line.Column = m.Column
+			line.Hit = true
+			line.Submatches = m.Submatches
+			line.Class = m.Class
+			line.Function = m.Function
+			line.Score = m.Score
+			line.In = m.In
+		}
+		lines[target][m.Line] = line
+	}
+
+	// Windows added out of order may now touch each other; fold them into
+	// the earliest block.
+	merged := make(map[*Block]bool)
+	for _, fileBlocks := range byFile {
+		for i, a := range fileBlocks {
+			if merged[a] {
+				continue
+			}
+			for changed := true; changed; {
+				changed = false
+				for _, b := range fileBlocks[i+1:] {
+					if merged[b] || b.StartLine > a.EndLine+1 || b.EndLine < a.StartLine-1 {
+						continue
+					}
+					a.StartLine = min(a.StartLine, b.StartLine)
+					a.EndLine = max(a.EndLine, b.EndLine)
+					for n, line := range lines[b] {
+						if existing, ok := lines[a][n]; !ok || !existing.Hit {
+							lines[a][n] = line
+						}
+					}
+					merged[b] = true
+					changed = true
+				}
+			}
+		}
+	}
+
+	out := make([]Block, 0, len(blocks))
+	for _, b := range blocks {
+		if merged[b] {
+			continue
+		}
+		for _, line := range lines[b] {
+			b.Lines = append(b.Lines, line)
+		}
+		sort.Slice(b.Lines, func(i, j int) bool { return b.Lines[i].Line < b.Lines[j].Line })
+		out = append(out, *b)
+	}
+	return out
+}
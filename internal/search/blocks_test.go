@@ -0,0 +1,40 @@
+package search
+
+import "testing"
+
+func TestBlocksMergesOverlappingWindows(t *testing.T) {
+	a := "com.example:core:1.0!/a/A.kt"
+	b := "com.example:core:1.0!/b/B.kt"
+	matches := []Match{
+		{FileID: a, Line: 10, Column: 3, Text: "hit 10"},
+		{FileID: a, Line: 11, Context: true, Text: "ctx 11"},
+		{FileID: b, Line: 1, Column: 1, Text: "hit b"},
+		// A later window in a.kt that overlaps the first one, as produced by
+		// ranking or by several rg invocations.
+		{FileID: a, Line: 11, Column: 1, Text: "ctx 11"},
+		{FileID: a, Line: 12, Context: true, Text: "ctx 12"},
+		{FileID: a, Line: 8, Context: true, Text: "ctx 8"},
+		{FileID: a, Line: 9, Context: true, Text: "ctx 9"},
+		{FileID: a, Line: 30, Column: 1, Text: "hit 30"},
+	}
+	blocks := Blocks(matches)
+	if len(blocks) != 3 {
+		t.Fatalf("expected 3 blocks, got %+v", blocks)
+	}
+	first := blocks[0]
+	if first.FileID != a || first.StartLine != 8 || first.EndLine != 12 || len(first.Lines) != 5 {
+		t.Fatalf("unexpected first block: %+v", first)
+	}
+	var hits []int
+	for _, line := range first.Lines {
+		if line.Hit {
+			hits = append(hits, line.Line)
+		}
+	}
+	if len(hits) != 2 || hits[0] != 10 || hits[1] != 11 {
+		t.Fatalf("unexpected hits: %v", hits)
+	}
+	if blocks[1].FileID != b || blocks[2].StartLine != 30 {
+		t.Fatalf("unexpected block order: %+v", blocks)
+	}
+}
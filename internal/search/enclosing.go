@@ -0,0 +1,88 @@
+package search
+
+import (
+	"github.com/respawn-app/ksrc/internal/kotlin"
+	"github.com/respawn-app/ksrc/internal/resolve"
+	"github.com/respawn-app/ksrc/internal/srcjar"
+)
+
+// Scope is a declaration that encloses a match.
+type Scope struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Signature string `json:"signature"`
+	StartLine int    `json:"startLine"`
+	EndLine   int    `json:"endLine"`
+}
+
+func newScope(d *kotlin.Decl) *Scope {
+	return &Scope{
+		Kind:      d.Kind,
+		Name:      d.QualifiedName(),
+		Signature: d.Signature,
+		StartLine: d.StartLine,
+		EndLine:   d.EndLine,
+	}
+}
+
+// Encloser annotates matches one at a time, as they are streamed. It keeps
+// only the most recently parsed file, which suits ripgrep's per-file order.
+type Encloser struct {
+	archives *srcjar.Archives
+	byCoord  map[string][]resolve.SourceJar
+	fileID   string
+	file     *kotlin.File
+}
+
+func NewEncloser(archives *srcjar.Archives, jars []resolve.SourceJar) *Encloser {
+	byCoord := make(map[string][]resolve.SourceJar)
+	for _, j := range jars {
+		byCoord[j.Coord.String()] = append(byCoord[j.Coord.String()], j)
+	}
+	return &Encloser{archives: archives, byCoord: byCoord}
+}
+
+// Annotate sets m.Class and m.Function to the innermost type and callable
+// declaring the matched line. Matches whose file cannot be found in the jars
+// are left unchanged.
+func (e *Encloser) Annotate(m *Match) error {
+	if m.FileID != e.fileID || e.fileID == "" {
+		f, err := parseMatchFile(e.archives, e.byCoord, m.FileID)
+		if err != nil {
+			return err
+		}
+		e.fileID, e.file = m.FileID, f
+	}
+	if e.file == nil {
+		return nil
+	}
+	m.Class, m.Function = nil, nil
+	for _, d := range e.file.Enclosing(m.Line) {
+		if d.IsType() {
+			m.Class = newScope(d)
+			m.Function = nil
+			continue
+		}
+		m.Function = newScope(d)
+	}
+	return nil
+}
+
+func parseMatchFile(archives *srcjar.Archives, byCoord map[string][]resolve.SourceJar, fileID string) (*kotlin.File, error) {
+	coord, inner, err := resolve.ParseFileID(fileID)
+	if err != nil {
+		return nil, nil
+	}
+	for _, jar := range byCoord[coord.String()] {
+		entry, err := srcjar.Find(archives, jar, inner)
+		if err != nil {
+			continue
+		}
+		data, err := entry.Read()
+		if err != nil {
+			return nil, err
+		}
+		return kotlin.Parse(data, kotlin.LangForPath(inner)), nil
+	}
+	return nil, nil
+}
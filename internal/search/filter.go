@@ -0,0 +1,133 @@
+package search
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/respawn-app/ksrc/internal/kotlin"
+	"github.com/respawn-app/ksrc/internal/srcjar"
+)
+
+// Filter restricts a search to some files inside the source jars. Each
+// non-empty list must match; within a list any entry may match. The zero
+// Filter keeps every file.
+type Filter struct {
+	// Packages keeps files whose package declaration is one of these packages
+	// or a subpackage of one.
+	Packages []string
+	// SourceSets keeps files under these top-level source set directories
+	// (commonMain, jvmMain, ...).
+	SourceSets []string
+	// Paths keeps files whose in-jar path matches one of these globs. "*" and
+	// "?" stay within a path segment, "**" spans segments. A glob may match
+	// the full path or the path below the source set directory.
+	Paths []string
+}
+
+func (f Filter) IsZero() bool {
+	return len(f.Packages) == 0 && len(f.SourceSets) == 0 && len(f.Paths) == 0
+}
+
+// Keep reports whether the file at inner passes the filter. read is only
+// called when a package filter is set.
+func (f Filter) Keep(inner string, read func() ([]byte, error)) (bool, error) {
+	if !f.KeepPath(inner) {
+		return false, nil
+	}
+	if len(f.Packages) == 0 {
+		return true, nil
+	}
+	data, err := read()
+	if err != nil {
+		return false, err
+	}
+	return f.KeepPackage(kotlin.ParsePackage(data)), nil
+}
+
+// KeepPath applies the source set and path filters.
+func (f Filter) KeepPath(inner string) bool {
+	inner = strings.TrimPrefix(inner, "/")
+	set := srcjar.SourceSet(inner)
+	if len(f.SourceSets) > 0 && !containsString(f.SourceSets, set) {
+		return false
+	}
+	if len(f.Paths) == 0 {
+		return true
+	}
+	rest := inner
+	if set != "" {
+		rest = strings.TrimPrefix(inner, set+"/")
+	}
+	for _, pattern := range f.Paths {
+		if globMatch(pattern, inner) || (rest != inner && globMatch(pattern, rest)) {
+			return true
+		}
+	}
+	return false
+}
+
+// KeepPackage applies the package filter.
+func (f Filter) KeepPackage(pkg string) bool {
+	if len(f.Packages) == 0 {
+		return true
+	}
+	for _, p := range f.Packages {
+		if pkg == p || strings.HasPrefix(pkg, p+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+var globCache sync.Map // pattern -> *regexp.Regexp (nil if invalid)
+
+func globMatch(pattern, path string) bool {
+	cached, ok := globCache.Load(pattern)
+	if !ok {
+		re, err := regexp.Compile(globRegexp(strings.TrimPrefix(pattern, "/")))
+		if err != nil {
+			re = nil
+		}
+		cached, _ = globCache.LoadOrStore(pattern, re)
+	}
+	re := cached.(*regexp.Regexp)
+	return re != nil && re.MatchString(path)
+}
+
+func globRegexp(pattern string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					// "**/" matches zero or more directories.
+					i++
+					b.WriteString("(?:.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+				continue
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
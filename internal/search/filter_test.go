@@ -0,0 +1,44 @@
+package search
+
+import "testing"
+
+func TestFilterKeepPath(t *testing.T) {
+	cases := []struct {
+		filter Filter
+		path   string
+		want   bool
+	}{
+		{Filter{}, "commonMain/a/B.kt", true},
+		{Filter{SourceSets: []string{"commonMain", "jvmMain"}}, "jvmMain/a/B.kt", true},
+		{Filter{SourceSets: []string{"commonMain"}}, "nativeMain/a/B.kt", false},
+		{Filter{SourceSets: []string{"commonMain"}}, "a/B.kt", false},
+		{Filter{Paths: []string{"kotlinx/**/flow/*.kt"}}, "commonMain/kotlinx/coroutines/flow/Flow.kt", true},
+		{Filter{Paths: []string{"kotlinx/**/flow/*.kt"}}, "kotlinx/flow/Flow.kt", true},
+		{Filter{Paths: []string{"kotlinx/**/flow/*.kt"}}, "kotlinx/coroutines/flow/internal/A.kt", false},
+		{Filter{Paths: []string{"**/Flow?.kt"}}, "jvmMain/x/FlowX.kt", true},
+		{Filter{Paths: []string{"jvmMain/**"}, SourceSets: []string{"jvmMain"}}, "jvmMain/x/y/Z.kt", true},
+	}
+	for _, tc := range cases {
+		if got := tc.filter.KeepPath(tc.path); got != tc.want {
+			t.Errorf("%+v KeepPath(%q) = %v, want %v", tc.filter, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestFilterKeepPackage(t *testing.T) {
+	f := Filter{Packages: []string{"kotlinx.coroutines.flow"}}
+	read := func(src string) func() ([]byte, error) {
+		return func() ([]byte, error) { return []byte(src), nil }
+	}
+	for src, want := range map[string]bool{
+		"package kotlinx.coroutines.flow\n":          true,
+		"package kotlinx.coroutines.flow.internal\n": true,
+		"package kotlinx.coroutines.flowx\n":         false,
+		"class NoPackage\n":                          false,
+	} {
+		got, err := f.Keep("commonMain/flow/A.kt", read(src))
+		if err != nil || got != want {
+			t.Errorf("Keep(%q) = %v, %v; want %v", src, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/respawn-app/ksrc/internal/resolve"
+)
+
+// jarSearchFunc runs ripgrep on one jar and calls fn for every line it reports.
+type jarSearchFunc func(ctx context.Context, jar resolve.SourceJar, fn func(Match) error) error
+
+// jarResult holds the matches of one jar. A jar's matches are buffered until
+// every jar before it is done; from then on emit is set and they are passed
+// on as ripgrep reports them.
+type jarResult struct {
+	mu      sync.Mutex
+	emit    func(Match) error
+	matches []Match
+	// limited is set when the jar had more hits than Options.MaxResults.
+	limited bool
+	err     error
+	done    chan struct{}
+}
+
+// add buffers m, or passes it on once the jar is at the head of the output.
+func (r *jarResult) add(m Match) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.emit != nil {
+		return r.emit(m)
+	}
+	r.matches = append(r.matches, m)
+	return nil
+}
+
+// release passes on the buffered matches and lets later ones through.
+func (r *jarResult) release(emit func(Match) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range r.matches {
+		if err := emit(m); err != nil {
+			return err
+		}
+	}
+	r.matches = nil
+	r.emit = emit
+	return nil
+}
+
+// searchJars searches the jars on up to opts.Jobs workers and calls fn with
+// their matches ordered by module, then by file. The first unfinished jar is
+// streamed to fn; later ones are buffered until it is done. Workers filter
+// their own matches and stop at opts.MaxResults; the limit is applied again
+// across jars in order, and the searches still running are cancelled once it
+// is reached.
+func searchJars(ctx context.Context, opts Options, matcher *queryMatcher, search jarSearchFunc, fn func(Match) error) error {
+	jars := slices.Clone(opts.Jars)
+	sort.SliceStable(jars, func(i, j int) bool { return jars[i].Coord.String() < jars[j].Coord.String() })
+	jobs := opts.Jobs
+	if jobs <= 0 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
+	jobs = min(jobs, len(jars))
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	results := make([]jarResult, len(jars))
+	for i := range results {
+		results[i].done = make(chan struct{})
+	}
+	next := make(chan int)
+	wg.Add(jobs + 1)
+	go func() {
+		defer wg.Done()
+		defer close(next)
+		for i := range jars {
+			select {
+			case next <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	for range jobs {
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				r := &results[i]
+				r.limited, r.err = searchJar(ctx, opts, matcher, search, jars[i], r.add)
+				close(r.done)
+			}
+		}()
+	}
+
+	limit := newLimiter(opts.MaxResults, fn)
+	for i := range results {
+		r := &results[i]
+		if err := r.release(limit); err != nil {
+			return err
+		}
+		select {
+		case <-r.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if r.err != nil {
+			return r.err
+		}
+		if r.limited {
+			// This jar reached the limit and had more hits.
+			return ErrLimitReached
+		}
+	}
+	return nil
+}
+
+// searchJar searches one jar and passes its matches to out after the
+// per-line query and region filters. It reports whether the jar stopped at
+// opts.MaxResults or out returned ErrLimitReached.
+func searchJar(ctx context.Context, opts Options, matcher *queryMatcher, search jarSearchFunc, jar resolve.SourceJar, out func(Match) error) (bool, error) {
+	regions := newRegionFilter(opts)
+	defer regions.Close()
+	withContext := HasContext(opts.RGArgs)
+
+	limit := newLimiter(opts.MaxResults, out)
+	err := search(ctx, jar, func(m Match) error {
+		if !m.Context {
+			keep := matcher.keepLine(m.Text)
+			if keep && regions != nil {
+				var err error
+				if keep, err = regions.apply(&m); err != nil {
+					return err
+				}
+			}
+			if !keep {
+				if !withContext {
+					return nil
+				}
+				// Still shown as context of the hits around it.
+				m.Context, m.Column, m.Submatches, m.In = true, 0, nil, ""
+			}
+		}
+		return limit(m)
+	})
+	if errors.Is(err, ErrLimitReached) {
+		return true, nil
+	}
+	return false, err
+}
+
+// newLimiter passes matches to fn until max hits went through (0 = no
+// limit), then only context lines directly following the last hit. The next
+// line after those returns ErrLimitReached.
+func newLimiter(max int, fn func(Match) error) func(Match) error {
+	hits := 0
+	var last Match
+	return func(m Match) error {
+		if max > 0 && hits >= max {
+			if !m.Context || m.FileID != last.FileID || m.Line != last.Line+1 {
+				return ErrLimitReached
+			}
+		}
+		if !m.Context {
+			hits++
+		}
+		last = m
+		return fn(m)
+	}
+}
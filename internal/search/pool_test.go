@@ -0,0 +1,162 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/respawn-app/ksrc/internal/resolve"
+)
+
+// fakeJars returns jars in non-sorted order and a search func reporting the
+// given files of each jar (one hit per file, in the given order) after a delay
+// that makes later jars finish first.
+func fakeJars(files map[string][]string) ([]resolve.SourceJar, jarSearchFunc) {
+	var jars []resolve.SourceJar
+	for _, artifact := range []string{"c", "a", "b"} {
+		if _, ok := files[artifact]; ok {
+			jars = append(jars, resolve.SourceJar{Coord: resolve.Coord{Group: "g", Artifact: artifact, Version: "1"}, Path: artifact + ".jar"})
+		}
+	}
+	search := func(ctx context.Context, jar resolve.SourceJar, fn func(Match) error) error {
+		delay := time.Duration('d'-jar.Coord.Artifact[0]) * 5 * time.Millisecond
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		for _, file := range files[jar.Coord.Artifact] {
+			if err := fn(Match{FileID: jar.Coord.String() + "!/" + file, Line: 1, Text: "hit"}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return jars, search
+}
+
+func fileIDs(matches []Match) []string {
+	var out []string
+	for _, m := range matches {
+		out = append(out, m.FileID)
+	}
+	return out
+}
+
+func TestSearchJarsOrdersByModule(t *testing.T) {
+	// ripgrep reports each jar's files in path order (--sort path).
+	jars, search := fakeJars(map[string][]string{
+		"a": {"a/A.kt", "z/Z.kt"},
+		"b": {"B.kt"},
+		"c": {"C1.kt", "C2.kt"},
+	})
+	want := []string{"g:a:1!/a/A.kt", "g:a:1!/z/Z.kt", "g:b:1!/B.kt", "g:c:1!/C1.kt", "g:c:1!/C2.kt"}
+	for _, jobs := range []int{1, 4} {
+		var got []Match
+		err := searchJars(context.Background(), Options{Jars: jars, Jobs: jobs}, nil, search, func(m Match) error {
+			got = append(got, m)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("jobs=%d: %v", jobs, err)
+		}
+		if !reflect.DeepEqual(fileIDs(got), want) {
+			t.Fatalf("jobs=%d: unexpected order %v", jobs, fileIDs(got))
+		}
+	}
+}
+
+func TestSearchJarsStreamsFirstJar(t *testing.T) {
+	jars := []resolve.SourceJar{
+		{Coord: resolve.Coord{Group: "g", Artifact: "b", Version: "1"}, Path: "b.jar"},
+		{Coord: resolve.Coord{Group: "g", Artifact: "a", Version: "1"}, Path: "a.jar"},
+	}
+	received := make(chan string, 4)
+	search := func(ctx context.Context, jar resolve.SourceJar, fn func(Match) error) error {
+		id := jar.Coord.String() + "!/"
+		if err := fn(Match{FileID: id + "First.kt", Line: 1}); err != nil {
+			return err
+		}
+		if jar.Coord.Artifact == "a" {
+			// The first jar's hit must arrive while it is still running.
+			select {
+			case <-received:
+			case <-time.After(time.Second):
+				return fmt.Errorf("first hit of %s was not streamed", id)
+			}
+		}
+		return fn(Match{FileID: id + "Second.kt", Line: 1})
+	}
+	var got []Match
+	err := searchJars(context.Background(), Options{Jars: jars, Jobs: 2}, nil, search, func(m Match) error {
+		got = append(got, m)
+		received <- m.FileID
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("searchJars: %v", err)
+	}
+	want := []string{"g:a:1!/First.kt", "g:a:1!/Second.kt", "g:b:1!/First.kt", "g:b:1!/Second.kt"}
+	if !reflect.DeepEqual(fileIDs(got), want) {
+		t.Fatalf("unexpected order %v", fileIDs(got))
+	}
+}
+
+func TestSearchJarsSharesLimitAndCancels(t *testing.T) {
+	jars, search := fakeJars(map[string][]string{
+		"a": {"A.kt"},
+		"b": {"B1.kt", "B2.kt", "B3.kt"},
+		"c": {"C.kt"},
+	})
+	started := make(chan string, len(jars))
+	cancelled := false
+	blocking := func(ctx context.Context, jar resolve.SourceJar, fn func(Match) error) error {
+		started <- jar.Coord.Artifact
+		if jar.Coord.Artifact == "c" {
+			// Never finishes unless cancelled.
+			<-ctx.Done()
+			cancelled = true
+			return ctx.Err()
+		}
+		return search(ctx, jar, fn)
+	}
+
+	var got []Match
+	err := searchJars(context.Background(), Options{Jars: jars, Jobs: 3, MaxResults: 2}, nil, blocking, func(m Match) error {
+		got = append(got, m)
+		return nil
+	})
+	if !errors.Is(err, ErrLimitReached) {
+		t.Fatalf("expected ErrLimitReached, got %v", err)
+	}
+	if want := []string{"g:a:1!/A.kt", "g:b:1!/B1.kt"}; !reflect.DeepEqual(fileIDs(got), want) {
+		t.Fatalf("unexpected matches %v", fileIDs(got))
+	}
+	if len(started) != 3 || !cancelled {
+		t.Fatalf("expected the remaining search to be cancelled (started %d, cancelled %v)", len(started), cancelled)
+	}
+}
+
+func TestSearchJarsReturnsJarErrors(t *testing.T) {
+	jars, search := fakeJars(map[string][]string{"a": {"A.kt"}, "b": {"B.kt"}})
+	failing := func(ctx context.Context, jar resolve.SourceJar, fn func(Match) error) error {
+		if jar.Coord.Artifact == "b" {
+			return fmt.Errorf("broken jar")
+		}
+		return search(ctx, jar, fn)
+	}
+	var got []Match
+	err := searchJars(context.Background(), Options{Jars: jars, Jobs: 2}, nil, failing, func(m Match) error {
+		got = append(got, m)
+		return nil
+	})
+	if err == nil || err.Error() != "broken jar" {
+		t.Fatalf("expected jar error, got %v", err)
+	}
+	if !reflect.DeepEqual(fileIDs(got), []string{"g:a:1!/A.kt"}) {
+		t.Fatalf("expected matches before the failing jar, got %v", fileIDs(got))
+	}
+}
@@ -0,0 +1,142 @@
+package search
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Query is what a search looks for: one or more patterns combined with
+// and/or, minus exclusions. Ripgrep finds the lines matching any pattern; the
+// rest of the logic (All, Not) is evaluated here with Go regexps, per file or
+// per line.
+type Query struct {
+	// Patterns are ripgrep regexes, or literals with FixedStrings.
+	Patterns []string
+	// All requires every pattern to match (--and); otherwise any may (--or).
+	All bool
+	// Not excludes files (or lines, with PerLine) matching any of these.
+	Not []string
+	// PerLine evaluates All and Not on each hit line instead of on the file.
+	PerLine bool
+	// FixedStrings treats patterns literally (rg -F).
+	FixedStrings bool
+	// Word only matches whole words (rg -w).
+	Word bool
+}
+
+// rgArgs returns the ripgrep arguments searching for any of the patterns.
+func (q Query) rgArgs() []string {
+	var args []string
+	if q.FixedStrings {
+		args = append(args, "--fixed-strings")
+	}
+	if q.Word {
+		args = append(args, "--word-regexp")
+	}
+	for _, p := range q.Patterns {
+		args = append(args, "-e", p)
+	}
+	return args
+}
+
+// queryMatcher evaluates the parts of a Query ripgrep cannot.
+type queryMatcher struct {
+	all     []*regexp.Regexp
+	not     []*regexp.Regexp
+	perLine bool
+}
+
+// compile returns nil when ripgrep's own matching is enough. rgArgs are
+// checked for case-insensitive matching so that both sides agree.
+func (q Query) compile(rgArgs []string) (*queryMatcher, error) {
+	needAll := q.All && len(q.Patterns) > 1
+	if !needAll && len(q.Not) == 0 {
+		return nil, nil
+	}
+	m := &queryMatcher{perLine: q.PerLine}
+	if needAll {
+		for _, p := range q.Patterns {
+			re, err := q.regexp(p, rgArgs)
+			if err != nil {
+				return nil, err
+			}
+			m.all = append(m.all, re)
+		}
+	}
+	for _, p := range q.Not {
+		re, err := q.regexp(p, rgArgs)
+		if err != nil {
+			return nil, err
+		}
+		m.not = append(m.not, re)
+	}
+	return m, nil
+}
+
+func (q Query) regexp(pattern string, rgArgs []string) (*regexp.Regexp, error) {
+	expr := pattern
+	if q.FixedStrings {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if q.Word {
+		expr = `\b(?:` + expr + `)\b`
+	}
+	if ignoreCase(pattern, rgArgs) {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile("(?m)" + expr)
+	if err != nil {
+		return nil, fmt.Errorf("pattern %q is not supported with --and/--not: %v. Try: --fixed-strings", pattern, err)
+	}
+	return re, nil
+}
+
+// ignoreCase mirrors rg -i and -S (smart case: insensitive unless the pattern
+// has an uppercase letter). The last flag wins, as in rg.
+func ignoreCase(pattern string, rgArgs []string) bool {
+	insensitive := false
+	for _, arg := range rgArgs {
+		switch arg {
+		case "--":
+			return insensitive
+		case "-i", "--ignore-case":
+			insensitive = true
+		case "-s", "--case-sensitive":
+			insensitive = false
+		case "-S", "--smart-case":
+			insensitive = !strings.ContainsFunc(pattern, unicode.IsUpper)
+		}
+	}
+	return insensitive
+}
+
+// filtersFiles reports whether keepFile needs the file content.
+func (m *queryMatcher) filtersFiles() bool {
+	return m != nil && !m.perLine
+}
+
+// keepFile reports whether a file's content satisfies the query.
+func (m *queryMatcher) keepFile(data []byte) bool {
+	for _, re := range m.all {
+		if !re.Match(data) {
+			return false
+		}
+	}
+	for _, re := range m.not {
+		if re.Match(data) {
+			return false
+		}
+	}
+	return true
+}
+
+// keepLine reports whether a hit line satisfies the query. Without PerLine
+// every line of a kept file is.
+func (m *queryMatcher) keepLine(text string) bool {
+	if m == nil || !m.perLine {
+		return true
+	}
+	return m.keepFile([]byte(text))
+}
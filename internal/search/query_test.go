@@ -0,0 +1,210 @@
+package search
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/respawn-app/ksrc/internal/index"
+	"github.com/respawn-app/ksrc/internal/resolve"
+)
+
+func TestQueryRgArgs(t *testing.T) {
+	q := Query{Patterns: []string{"suspend fun", "Flow<"}, FixedStrings: true, Word: true}
+	want := []string{"--fixed-strings", "--word-regexp", "-e", "suspend fun", "-e", "Flow<"}
+	if got := q.rgArgs(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected rg args: %v", got)
+	}
+}
+
+func TestQueryCompileOnlyWhenNeeded(t *testing.T) {
+	for _, q := range []Query{
+		{Patterns: []string{"a"}},
+		{Patterns: []string{"a", "b"}},
+		{Patterns: []string{"a"}, All: true},
+	} {
+		m, err := q.compile(nil)
+		if err != nil || m != nil {
+			t.Fatalf("%+v: expected rg-only query, got %v, %v", q, m, err)
+		}
+	}
+	if _, err := (Query{Patterns: []string{"a"}, Not: []string{"("}}).compile(nil); err == nil {
+		t.Fatal("expected error for invalid pattern")
+	}
+}
+
+func TestQueryKeepFileAndLine(t *testing.T) {
+	q := Query{Patterns: []string{"suspend fun", "Flow<"}, All: true, Not: []string{"@Deprecated"}}
+	m, err := q.compile(nil)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if !m.keepFile([]byte("suspend fun a()\nval f: Flow<Int>\n")) {
+		t.Fatal("expected file with both patterns to be kept")
+	}
+	if m.keepFile([]byte("suspend fun a()\n")) {
+		t.Fatal("expected file with one pattern to be dropped")
+	}
+	if m.keepFile([]byte("@Deprecated\nsuspend fun a(): Flow<Int>\n")) {
+		t.Fatal("expected excluded file to be dropped")
+	}
+	if !m.keepLine("suspend fun a()") {
+		t.Fatal("per-file query should keep every line of a kept file")
+	}
+
+	q.PerLine = true
+	if m, err = q.compile(nil); err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if !m.keepLine("suspend fun a(): Flow<Int>") || m.keepLine("suspend fun a()") {
+		t.Fatal("unexpected per-line result")
+	}
+}
+
+func TestQueryFixedStringsWordAndCase(t *testing.T) {
+	q := Query{Patterns: []string{"a.b"}, Not: []string{"Flow"}, FixedStrings: true, Word: true, PerLine: true}
+	m, err := q.compile([]string{"-i"})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if m.keepLine("val x = a.b // flow") || !m.keepLine("val x = a.b // Flows") {
+		t.Fatal("expected case-insensitive whole-word literal exclusion")
+	}
+	if !ignoreCase("flow", []string{"-S"}) || ignoreCase("Flow", []string{"-S"}) || ignoreCase("flow", []string{"-i", "-s"}) {
+		t.Fatal("unexpected case sensitivity")
+	}
+}
+
+func TestStreamAppliesQueryToZipSearch(t *testing.T) {
+	jarPath := filepath.Join(t.TempDir(), "foo.jar")
+	writeZip(t, jarPath, map[string]string{
+		"com/foo/A.kt": "suspend fun a(): Flow<Int>\nsuspend fun b()\n",
+		"com/foo/B.kt": "suspend fun c()\n",
+	})
+	coord := resolve.Coord{Group: "com.example", Artifact: "foo", Version: "1.0.0"}
+	runner := &lineRunner{fakeRunner: fakeRunner{jarPath: jarPath}, lines: []string{
+		rgJSONLine("match", jarPath+":com/foo/A.kt", 1, "suspend fun a(): Flow<Int>"),
+		rgJSONLine("match", jarPath+":com/foo/A.kt", 2, "suspend fun b()"),
+		rgJSONLine("match", jarPath+":com/foo/B.kt", 1, "suspend fun c()"),
+	}}
+	search := func(q Query) []string {
+		var got []string
+		err := Stream(context.Background(), runner, Options{
+			Query: q,
+			Jars:  []resolve.SourceJar{{Coord: coord, Path: jarPath}},
+		}, func(m Match) error {
+			got = append(got, fmt.Sprintf("%s:%d", strings.TrimPrefix(m.FileID, "com.example:foo:1.0.0!/"), m.Line))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Stream error: %v", err)
+		}
+		return got
+	}
+
+	both := []string{"suspend fun", "Flow<"}
+	if got := search(Query{Patterns: both, All: true}); !reflect.DeepEqual(got, []string{"com/foo/A.kt:1", "com/foo/A.kt:2"}) {
+		t.Fatalf("per-file and: %v", got)
+	}
+	if got := search(Query{Patterns: both, All: true, PerLine: true}); !reflect.DeepEqual(got, []string{"com/foo/A.kt:1"}) {
+		t.Fatalf("per-line and: %v", got)
+	}
+	if got := search(Query{Patterns: both[:1], Not: []string{"Flow"}}); !reflect.DeepEqual(got, []string{"com/foo/B.kt:1"}) {
+		t.Fatalf("per-file not: %v", got)
+	}
+}
+
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create zip: %v", err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip entry: %v", err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("zip write: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+}
+
+func TestIndexCandidates(t *testing.T) {
+	jarPath := filepath.Join(t.TempDir(), "foo.jar")
+	writeZip(t, jarPath, map[string]string{
+		"com/foo/A.kt": "suspend fun a(): Flow<Int>\n",
+		"com/foo/B.kt": "suspend fun b()\n",
+	})
+	jar := resolve.SourceJar{Path: jarPath}
+	store := &index.Store{Dir: filepath.Join(t.TempDir(), "index")}
+	opts := Options{
+		Query: Query{Patterns: []string{"suspend", "Flow<"}, All: true},
+		Jars:  []resolve.SourceJar{jar},
+		Index: store,
+	}
+	candidates := func() (map[string]bool, bool) {
+		return indexCandidates(opts, indexPlans(opts), jar)
+	}
+	if got, ok := candidates(); ok {
+		t.Fatalf("expected no narrowing before indexing is enabled, got %v", got)
+	}
+	if _, err := store.Build(jarPath); err != nil {
+		t.Fatalf("build index: %v", err)
+	}
+	if got, ok := candidates(); !ok || !reflect.DeepEqual(got, map[string]bool{"com/foo/A.kt": true}) {
+		t.Fatalf("unexpected candidates: %v", got)
+	}
+	opts.Query.All = false
+	opts.Query.Patterns = []string{"Flow<", "fun .*"}
+	if got, ok := candidates(); !ok || len(got) != 2 {
+		t.Fatalf("expected both files for an or-query, got %v", got)
+	}
+	opts.Query.Patterns = []string{"Flow<", ".*"}
+	if got, ok := candidates(); ok {
+		t.Fatalf("expected no narrowing when a pattern needs no trigram, got %v", got)
+	}
+	opts.RGArgs = []string{"-v"}
+	opts.Query.Patterns = []string{"Flow<"}
+	if got, ok := candidates(); ok {
+		t.Fatalf("expected no narrowing for inverted matches, got %v", got)
+	}
+}
+
+func TestStreamPassesIndexCandidatesToZipSearch(t *testing.T) {
+	jarPath := filepath.Join(t.TempDir(), "foo.jar")
+	writeZip(t, jarPath, map[string]string{
+		"com/foo/A.kt": "val flow: Flow<Int>\n",
+		"com/foo/B.kt": "val b = 1\n",
+	})
+	store := &index.Store{Dir: filepath.Join(t.TempDir(), "index")}
+	if _, err := store.Build(jarPath); err != nil {
+		t.Fatalf("build index: %v", err)
+	}
+	coord := resolve.Coord{Group: "com.example", Artifact: "foo", Version: "1.0.0"}
+	runner := &lineRunner{fakeRunner: fakeRunner{jarPath: jarPath}, lines: []string{
+		rgJSONLine("match", jarPath+":com/foo/A.kt", 1, "val flow: Flow<Int>"),
+	}}
+	matches, err := Run(context.Background(), runner, Options{
+		Query: Query{Patterns: []string{"Flow<"}},
+		Jars:  []resolve.SourceJar{{Coord: coord, Path: jarPath}},
+		Index: store,
+	})
+	if err != nil || len(matches) != 1 {
+		t.Fatalf("unexpected result: %v, %v", matches, err)
+	}
+	if !containsArg(runner.args, "--search-zip") || !containsArg(runner.args, "**/com/foo/A.kt") || containsArg(runner.args, "*.kt") {
+		t.Fatalf("expected the candidate as the only glob, got %v", runner.args)
+	}
+}
@@ -0,0 +1,161 @@
+package search
+
+import (
+	"sort"
+
+	"github.com/respawn-app/ksrc/internal/kotlin"
+	"github.com/respawn-app/ksrc/internal/resolve"
+	"github.com/respawn-app/ksrc/internal/srcjar"
+)
+
+const (
+	scoreDeclaration = 100
+	scoreDirect      = 40
+	scorePublic      = 20
+	scoreCommon      = 10
+)
+
+// RankOptions configures Rank.
+type RankOptions struct {
+	// Direct reports whether a module is a direct dependency of the project.
+	// When nil, every module is treated as direct.
+	Direct func(resolve.Coord) bool
+}
+
+// Rank sets Score on every match and orders matches by it: declaration sites
+// before call sites, public before protected before internal/private,
+// commonMain before platform source sets, direct before transitive
+// dependencies, then shorter paths. Every match is ranked by its own score;
+// leading context moves with the match after it and trailing context with the
+// one before it. Ties keep ripgrep's order.
+func Rank(matches []Match, opts RankOptions) []Match {
+	type run struct {
+		matches []Match
+		score   int
+		pathLen int
+	}
+	var runs []*run
+	var pending []Match
+	for i, m := range matches {
+		if m.Context {
+			pending = append(pending, m)
+			continue
+		}
+		matches[i].Score = score(m, opts)
+		m = matches[i]
+		// Split the context since the previous match between it and m. A block
+		// adjacent to both is shared by their -C windows; it is split in half.
+		lead := 0
+		for prev := m; lead < len(pending) && adjacent(pending[len(pending)-1-lead], prev); lead++ {
+			prev = pending[len(pending)-1-lead]
+		}
+		if n := len(runs); n > 0 {
+			trail := 0
+			for prev := runs[n-1].matches[len(runs[n-1].matches)-1]; trail < len(pending) && adjacent(prev, pending[trail]); trail++ {
+				prev = pending[trail]
+			}
+			if trail+lead > len(pending) {
+				trail = (len(pending) + 1) / 2
+			} else {
+				trail = len(pending) - lead
+			}
+			runs[n-1].matches = append(runs[n-1].matches, pending[:trail]...)
+			pending = pending[trail:]
+		}
+		_, inner, _ := resolve.ParseFileID(m.FileID)
+		runs = append(runs, &run{matches: append(pending, m), score: m.Score, pathLen: len(inner)})
+		pending = nil
+	}
+	if n := len(runs); n > 0 {
+		runs[n-1].matches = append(runs[n-1].matches, pending...)
+	} else if len(pending) > 0 {
+		runs = append(runs, &run{matches: pending})
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		if runs[i].score != runs[j].score {
+			return runs[i].score > runs[j].score
+		}
+		return runs[i].pathLen < runs[j].pathLen
+	})
+	out := make([]Match, 0, len(matches))
+	for _, r := range runs {
+		out = append(out, r.matches...)
+	}
+	return out
+}
+
+// adjacent reports whether b is the line right after a in the same file.
+func adjacent(a, b Match) bool {
+	return a.FileID == b.FileID && b.Line == a.Line+1
+}
+
+func score(m Match, opts RankOptions) int {
+	s := 0
+	if d := kotlin.ParseDeclarationLine(m.Text); d != nil && m.Column <= nameEnd(m.Text, d) {
+		s += scoreDeclaration
+		switch {
+		case d.HasModifier("private") || d.HasModifier("internal"):
+		case d.HasModifier("protected"):
+			s += scorePublic / 2
+		default:
+			s += scorePublic
+		}
+	}
+	coord, inner, err := resolve.ParseFileID(m.FileID)
+	if err != nil {
+		return s
+	}
+	switch set := srcjar.SourceSet(inner); {
+	case set == "commonMain":
+		s += scoreCommon
+	case set == "":
+		s += scoreCommon / 2
+	}
+	if opts.Direct == nil || opts.Direct(coord) {
+		s += scoreDirect
+	}
+	return s
+}
+
+// nameEnd returns the column of the last byte of d's name in line, so that hits
+// in parameter lists or initializers (val x = Foo()) do not count as
+// declarations of the searched symbol.
+func nameEnd(line string, d *kotlin.Decl) int {
+	for _, tok := range kotlin.Lex([]byte(line), kotlin.LangKotlin) {
+		if tok.Kind == kotlin.Ident && tok.Col > d.Col && tok.Text == d.Name {
+			return tok.Col + (tok.End - tok.Start) - 1
+		}
+	}
+	return d.Col + len(d.Kind) - 1
+}
+
+// Limit keeps the first n matches (context lines excluded from the count),
+// along with context lines before the next dropped match. n <= 0 keeps all.
+// It also returns the total number of matches.
+func Limit(matches []Match, n int) ([]Match, int) {
+	total := 0
+	cut := len(matches)
+	last := -1
+	for i, m := range matches {
+		if m.Context {
+			continue
+		}
+		total++
+		if n > 0 && total == n {
+			last = i
+		}
+		if n > 0 && total == n+1 {
+			cut = i
+		}
+	}
+	if cut < len(matches) {
+		// Drop leading context of the first dropped match when it is not
+		// also trailing context of the last kept one.
+		end := last + 1
+		for end < cut && matches[end].FileID == matches[end-1].FileID && matches[end].Line == matches[end-1].Line+1 {
+			end++
+		}
+		cut = end
+	}
+	return matches[:cut], total
+}
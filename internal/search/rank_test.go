@@ -0,0 +1,103 @@
+package search
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/respawn-app/ksrc/internal/resolve"
+)
+
+func TestRankPrefersDeclarations(t *testing.T) {
+	core := "com.example:core:1.0!/"
+	other := "com.example:other:1.0!/"
+	matches := []Match{
+		{FileID: other + "a/Use.kt", Line: 3, Column: 13, Text: "    val d = LocalDate()"},
+		{FileID: core + "jvmMain/a/LocalDate.kt", Line: 1, Context: true, Text: "// before"},
+		{FileID: core + "jvmMain/a/LocalDate.kt", Line: 2, Column: 1, Text: "public actual class LocalDate"},
+		{FileID: core + "commonMain/a/LocalDate.kt", Line: 5, Column: 1, Text: "public expect class LocalDate"},
+		{FileID: core + "commonMain/a/Internal.kt", Line: 9, Column: 1, Text: "internal class LocalDateImpl : LocalDate"},
+		{FileID: other + "b/LocalDate.kt", Line: 2, Column: 1, Text: "public class LocalDate"},
+	}
+	direct := func(c resolve.Coord) bool { return c.Artifact == "core" }
+	ranked := Rank(matches, RankOptions{Direct: direct})
+
+	var order []string
+	for _, m := range ranked {
+		order = append(order, m.FileID+":"+m.Text)
+	}
+	want := []string{
+		core + "commonMain/a/LocalDate.kt:public expect class LocalDate",
+		core + "jvmMain/a/LocalDate.kt:// before",
+		core + "jvmMain/a/LocalDate.kt:public actual class LocalDate",
+		core + "commonMain/a/Internal.kt:internal class LocalDateImpl : LocalDate",
+		other + "b/LocalDate.kt:public class LocalDate",
+		other + "a/Use.kt:    val d = LocalDate()",
+	}
+	if len(order) != len(want) {
+		t.Fatalf("unexpected ranking: %v", order)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("unexpected ranking at %d:\n got %v\nwant %v", i, order, want)
+		}
+	}
+}
+
+func TestRankScoresNeighboringMatchesSeparately(t *testing.T) {
+	a := "com.example:core:1.0!/jvmMain/a/A.kt"
+	b := "com.example:core:1.0!/commonMain/b/B.kt"
+	matches := []Match{
+		{FileID: a, Line: 1, Context: true, Text: "// before"},
+		{FileID: a, Line: 2, Column: 13, Text: "    val d = LocalDate()"},
+		{FileID: a, Line: 3, Column: 1, Text: "public class LocalDate"},
+		{FileID: a, Line: 4, Context: true, Text: "// after"},
+		{FileID: b, Line: 7, Column: 1, Text: "internal class LocalDate"},
+	}
+	var order []string
+	for _, m := range Rank(matches, RankOptions{}) {
+		order = append(order, m.Text)
+	}
+	want := []string{"public class LocalDate", "// after", "internal class LocalDate", "// before", "    val d = LocalDate()"}
+	if len(order) != len(want) {
+		t.Fatalf("unexpected ranking: %q", order)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("unexpected ranking at %d:\n got %q\nwant %q", i, order, want)
+		}
+	}
+}
+
+func TestRankSplitsSharedContext(t *testing.T) {
+	id := "com.example:core:1.0!/a/A.kt"
+	matches := []Match{
+		{FileID: id, Line: 1, Column: 5, Text: "use(LocalDate)"},
+		{FileID: id, Line: 2, Context: true, Text: "one"},
+		{FileID: id, Line: 3, Context: true, Text: "two"},
+		{FileID: id, Line: 4, Column: 1, Text: "class LocalDate"},
+	}
+	var order []string
+	for _, m := range Rank(matches, RankOptions{}) {
+		order = append(order, m.Text)
+	}
+	if got := strings.Join(order, "|"); got != "two|class LocalDate|use(LocalDate)|one" {
+		t.Fatalf("unexpected ranking: %s", got)
+	}
+}
+
+func TestLimitKeepsContextOfKeptMatches(t *testing.T) {
+	id := "com.example:core:1.0!/a/A.kt"
+	matches := []Match{
+		{FileID: id, Line: 1, Column: 1, Text: "hit"},
+		{FileID: id, Line: 2, Context: true, Text: "after"},
+		{FileID: id, Line: 10, Context: true, Text: "before"},
+		{FileID: id, Line: 11, Column: 1, Text: "hit"},
+	}
+	limited, total := Limit(matches, 1)
+	if total != 2 || len(limited) != 2 || limited[1].Text != "after" {
+		t.Fatalf("unexpected limit result: %d %+v", total, limited)
+	}
+	if all, _ := Limit(matches, 0); len(all) != len(matches) {
+		t.Fatalf("expected no limit, got %+v", all)
+	}
+}
@@ -0,0 +1,140 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/respawn-app/ksrc/internal/kotlin"
+	"github.com/respawn-app/ksrc/internal/resolve"
+	"github.com/respawn-app/ksrc/internal/srcjar"
+)
+
+// ParseRegions parses --in values: code, comments (or comment) and strings
+// (or string).
+func ParseRegions(names []string) ([]kotlin.Region, error) {
+	var out []kotlin.Region
+	for _, name := range names {
+		switch strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), "s") {
+		case "code":
+			out = append(out, kotlin.RegionCode)
+		case "comment":
+			out = append(out, kotlin.RegionComment)
+		case "string":
+			out = append(out, kotlin.RegionString)
+		default:
+			return nil, fmt.Errorf("invalid --in %q. Try: --in code, --in comments or --in strings", name)
+		}
+	}
+	return out, nil
+}
+
+// regionFilter classifies hits by the lexical region of their submatches and
+// keeps those in the wanted regions. Files are read back from the jars and
+// lexed once; ripgrep reports a file's matches together.
+type regionFilter struct {
+	want     map[kotlin.Region]bool
+	jars     []resolve.SourceJar
+	archives *srcjar.Archives
+	fileID   string
+	toks     []kotlin.Token
+	lines    []int
+}
+
+// newRegionFilter returns nil when matches are neither filtered nor classified.
+func newRegionFilter(opts Options) *regionFilter {
+	if len(opts.In) == 0 && !opts.Classify {
+		return nil
+	}
+	r := &regionFilter{want: make(map[kotlin.Region]bool), jars: opts.Jars, archives: srcjar.NewArchives()}
+	for _, region := range opts.In {
+		r.want[region] = true
+	}
+	return r
+}
+
+func (r *regionFilter) Close() {
+	if r != nil {
+		r.archives.Close()
+	}
+}
+
+// apply sets m.In and drops the submatches outside the wanted regions. It
+// reports whether any submatch is left.
+func (r *regionFilter) apply(m *Match) (bool, error) {
+	if err := r.load(m.FileID); err != nil {
+		return false, err
+	}
+	if m.Line > len(r.lines) {
+		return len(r.want) == 0, nil
+	}
+	lineStart := r.lines[m.Line-1]
+	subs := m.Submatches
+	if len(subs) == 0 {
+		subs = []Submatch{{Start: max(m.Column-1, 0)}}
+	}
+	var kept []Submatch
+	m.In = ""
+	for _, sm := range subs {
+		region := kotlin.RegionAt(r.toks, lineStart+sm.Start)
+		if len(r.want) > 0 && !r.want[region] {
+			continue
+		}
+		if m.In == "" {
+			m.In = region.String()
+		}
+		kept = append(kept, sm)
+	}
+	if len(kept) == 0 {
+		return false, nil
+	}
+	if len(m.Submatches) > 0 {
+		m.Submatches = kept
+		m.Column = kept[0].Start + 1
+	}
+	return true, nil
+}
+
+func (r *regionFilter) load(fileID string) error {
+	if fileID == r.fileID {
+		return nil
+	}
+	coord, inner, err := resolve.ParseFileID(fileID)
+	if err != nil {
+		return err
+	}
+	var findErr error
+	for _, jar := range r.jars {
+		if jar.Coord != coord {
+			continue
+		}
+		entry, err := srcjar.Find(r.archives, jar, inner)
+		if err != nil {
+			// Several jars can share a coord; the file may be in another one.
+			findErr = err
+			continue
+		}
+		data, err := entry.Read()
+		if err != nil {
+			return err
+		}
+		r.fileID = fileID
+		r.toks = kotlin.Lex(data, kotlin.LangForPath(inner))
+		r.lines = lineStarts(data)
+		return nil
+	}
+	if findErr != nil {
+		return findErr
+	}
+	return fmt.Errorf("source jar not found for %s", fileID)
+}
+
+// lineStarts returns the byte offset at which each line begins.
+func lineStarts(data []byte) []int {
+	starts := []int{0}
+	for i, c := range data {
+		if c == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
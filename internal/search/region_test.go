@@ -0,0 +1,76 @@
+package search
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/respawn-app/ksrc/internal/kotlin"
+	"github.com/respawn-app/ksrc/internal/resolve"
+)
+
+func TestStreamFiltersRegions(t *testing.T) {
+	jarPath := filepath.Join(t.TempDir(), "foo.jar")
+	writeZip(t, jarPath, map[string]string{
+		"com/foo/A.kt": "/** Flow docs */\nval Flow = \"Flow\"\n",
+	})
+	coord := resolve.Coord{Group: "com.example", Artifact: "foo", Version: "1.0.0"}
+	line2 := `{"type":"match","data":{"path":{"text":"` + jarPath + `:com/foo/A.kt"},"lines":{"text":"val Flow = \"Flow\"\n"},"line_number":2,"submatches":[{"match":{"text":"Flow"},"start":4,"end":8},{"match":{"text":"Flow"},"start":12,"end":16}]}}`
+	runner := &lineRunner{fakeRunner: fakeRunner{jarPath: jarPath}, lines: []string{
+		rgJSONLine("match", jarPath+":com/foo/A.kt", 1, "/** Flow docs */"),
+		line2,
+	}}
+	search := func(in ...kotlin.Region) []Match {
+		got, err := Run(context.Background(), runner, Options{
+			Query:    Query{Patterns: []string{"Flow"}},
+			Jars:     []resolve.SourceJar{{Coord: coord, Path: jarPath}},
+			In:       in,
+			Classify: true,
+		})
+		if err != nil {
+			t.Fatalf("Run error: %v", err)
+		}
+		return got
+	}
+
+	all := search()
+	if len(all) != 2 || all[0].In != "comment" || all[1].In != "code" || len(all[1].Submatches) != 2 {
+		t.Fatalf("unexpected classification: %+v", all)
+	}
+	strs := search(kotlin.RegionString)
+	if len(strs) != 1 || strs[0].Line != 2 || strs[0].Column != 13 || strs[0].In != "string" || len(strs[0].Submatches) != 1 {
+		t.Fatalf("unexpected string matches: %+v", strs)
+	}
+	if code := search(kotlin.RegionCode); len(code) != 1 || code[0].Column != 5 {
+		t.Fatalf("unexpected code matches: %+v", code)
+	}
+}
+
+func TestParseRegions(t *testing.T) {
+	got, err := ParseRegions([]string{"code", "comments", "string"})
+	if err != nil || len(got) != 3 || got[1] != kotlin.RegionComment || got[2] != kotlin.RegionString {
+		t.Fatalf("unexpected regions: %v, %v", got, err)
+	}
+	if _, err := ParseRegions([]string{"docs"}); err == nil {
+		t.Fatal("expected error for unknown region")
+	}
+}
+
+func TestRegionFilterFindsFileInAnyJarOfCoord(t *testing.T) {
+	dir := t.TempDir()
+	coord := resolve.Coord{Group: "com.example", Artifact: "foo", Version: "1.0.0"}
+	first := filepath.Join(dir, "foo-common.jar")
+	writeZip(t, first, map[string]string{"com/foo/A.kt": "val a = 1\n"})
+	second := filepath.Join(dir, "foo-jvm.jar")
+	writeZip(t, second, map[string]string{"com/foo/B.kt": "// Flow\n"})
+
+	r := newRegionFilter(Options{
+		Jars:     []resolve.SourceJar{{Coord: coord, Path: first}, {Coord: coord, Path: second}},
+		Classify: true,
+	})
+	defer r.Close()
+	m := Match{FileID: resolve.FormatFileID(coord, "com/foo/B.kt"), Line: 1, Column: 4}
+	if ok, err := r.apply(&m); err != nil || !ok || m.In != "comment" {
+		t.Fatalf("unexpected result: %v, %v, %+v", ok, err, m)
+	}
+}
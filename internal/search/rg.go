@@ -3,224 +3,708 @@ package search
 import (
 	"archive/zip"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
-	"strconv"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/respawn-app/ksrc/internal/executil"
+	"github.com/respawn-app/ksrc/internal/extract"
+	"github.com/respawn-app/ksrc/internal/index"
+	"github.com/respawn-app/ksrc/internal/kotlin"
 	"github.com/respawn-app/ksrc/internal/resolve"
+	"github.com/respawn-app/ksrc/internal/srcjar"
 )
 
 type Match struct {
-	FileID string
-	File   string
-	Line   int
-	Column int
-	Text   string
+	FileID string `json:"fileId"`
+	File   string `json:"path,omitempty"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Text   string `json:"text"`
+	// Context marks lines printed around a hit (rg -A/-B/-C); their Column is 0.
+	Context bool `json:"context,omitempty"`
+	// Class and Function are the innermost enclosing declarations, set by
+	// Encloser.Annotate.
+	Class    *Scope `json:"class,omitempty"`
+	Function *Scope `json:"function,omitempty"`
+	// Submatches are the byte ranges of every match within Text.
+	Submatches []Submatch `json:"submatches,omitempty"`
+	// Score is the relevance set by Rank.
+	Score int `json:"score,omitempty"`
+	// In is the lexical region of the first submatch (code, comment or
+	// string), set when Options.In or Options.Classify is used.
+	In string `json:"in,omitempty"`
+}
+
+// Submatch is one match within a line; Start and End are byte offsets into
+// Match.Text (End exclusive).
+type Submatch struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Text  string `json:"text"`
 }
 
 type Options struct {
-	Pattern string
+	Query   Query
 	Jars    []resolve.SourceJar
 	RGArgs  []string
 	WorkDir string
+	// Filter restricts the search to some files inside the jars.
+	Filter Filter
+	// In keeps only hits whose match lies in one of these regions; a line
+	// keeps just the submatches inside them.
+	In []kotlin.Region
+	// Classify sets Match.In on every hit even without In.
+	Classify bool
+	// Extracts returns the cache for extracted jars. It is only called when
+	// ripgrep cannot search zips; nil extracts into a temporary directory.
+	Extracts func() (*extract.Cache, error)
+	// Index narrows the files handed to ripgrep with per-jar trigram
+	// indexes; nil searches every file.
+	Index *index.Store
+	// Jobs is the number of jars searched at once (0 = GOMAXPROCS).
+	Jobs int
+	// MaxResults stops the search after this many hits (0 = no limit). Context
+	// lines directly following the last hit are still reported.
+	MaxResults int
 }
 
+// ErrLimitReached is returned by Stream when it stopped early because
+// Options.MaxResults hits were reported.
+var ErrLimitReached = errors.New("result limit reached")
+
 func Run(ctx context.Context, runner executil.Runner, opts Options) ([]Match, error) {
-	if opts.Pattern == "" {
-		return nil, fmt.Errorf("pattern is required")
+	matches := []Match{}
+	err := Stream(ctx, runner, opts, func(m Match) error {
+		matches = append(matches, m)
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrLimitReached) {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// Stream runs the search and calls fn for every match, ordered by module and
+// then by file. Jars are searched in parallel (see Options.Jobs); the matches
+// of the first jar still running are reported as ripgrep finds them, those of
+// later jars once every jar before them is done.
+// Cancelling ctx stops ripgrep. An error returned by fn
+// ends the search and is returned as is.
+func Stream(ctx context.Context, runner executil.Runner, opts Options, fn func(Match) error) error {
+	if len(opts.Query.Patterns) == 0 {
+		return fmt.Errorf("pattern is required")
 	}
 	if len(opts.Jars) == 0 {
-		return nil, fmt.Errorf("no source jars to search")
+		return fmt.Errorf("no source jars to search")
 	}
 	if _, err := runner.LookPath("rg"); err != nil {
-		return nil, fmt.Errorf("rg not found on PATH")
+		return fmt.Errorf("rg not found on PATH")
 	}
 
-	if supportsZipSearch(ctx, runner) {
-		return runZipSearch(ctx, runner, opts)
+	if hasArg(opts.RGArgs, "-F", "--fixed-strings") {
+		opts.Query.FixedStrings = true
+	}
+	if hasArg(opts.RGArgs, "-w", "--word-regexp") {
+		opts.Query.Word = true
+	}
+	matcher, err := opts.Query.compile(opts.RGArgs)
+	if err != nil {
+		return err
+	}
+	keepFile := fileFilter(opts.Filter, matcher)
+	plans := indexPlans(opts)
+	zipSearch := supportsZipSearch(ctx, runner)
+	var ex *extractor
+	if !zipSearch {
+		ex = &extractor{open: opts.Extracts}
+		defer ex.Close()
 	}
-	return runExtractSearch(ctx, runner, opts)
+	search := func(ctx context.Context, jar resolve.SourceJar, fn func(Match) error) error {
+		// With an index only the candidate files are searched.
+		files, indexed := indexCandidates(opts, plans, jar)
+		if indexed && len(files) == 0 {
+			return nil
+		}
+		keep := keepFile
+		if indexed {
+			keep = onlyFiles(files, keepFile)
+		}
+		switch {
+		case zipSearch:
+			return runZipSearch(ctx, runner, opts, jar, files, keep, fn)
+		case indexed:
+			return searchCandidates(ctx, runner, opts, jar, keep, fn)
+		default:
+			return ex.search(ctx, runner, opts, jar, keep, fn)
+		}
+	}
+	return searchJars(ctx, opts, matcher, search, fn)
 }
 
-func parseRgLine(line string) (Match, bool) {
-	if m, ok := parseRgMatchLine(line); ok {
-		return m, true
+// indexPlans returns the trigram plans of the query's patterns. It returns
+// nil when no index is used or the query cannot be narrowed.
+func indexPlans(opts Options) []index.Plan {
+	if opts.Index == nil || !opts.Index.Enabled() || !index.Searchable(opts.RGArgs) {
+		return nil
 	}
-	return parseRgContextLine(line)
+	var plans []index.Plan
+	for _, p := range opts.Query.Patterns {
+		plan := index.PlanPattern(p, opts.Query.FixedStrings, ignoreCase(p, opts.RGArgs))
+		switch {
+		case plan != nil:
+			plans = append(plans, plan)
+		case !opts.Query.All:
+			// Any file may match this pattern.
+			return nil
+		}
+	}
+	return plans
 }
 
-func parseRgMatchLine(line string) (Match, bool) {
-	// file:line:col:match
-	last := strings.LastIndex(line, ":")
-	if last <= 0 {
-		return Match{}, false
+// indexCandidates returns the files of jar that may match according to its
+// trigram index, loading or building the index. It returns false when the
+// jar is searched in full: without plans, or when its index cannot be built.
+func indexCandidates(opts Options, plans []index.Plan, jar resolve.SourceJar) (map[string]bool, bool) {
+	if len(plans) == 0 {
+		return nil, false
 	}
-	second := strings.LastIndex(line[:last], ":")
-	if second <= 0 {
-		return Match{}, false
+	ix, err := opts.Index.Get(jar.Path)
+	if err != nil {
+		return nil, false
 	}
-	third := strings.LastIndex(line[:second], ":")
-	if third <= 0 {
-		return Match{}, false
+	if !opts.Query.All {
+		files, _ := ix.Candidates(plans)
+		return files, true
 	}
-	file := line[:third]
-	lineStr := line[third+1 : second]
-	colStr := line[second+1 : last]
-	text := line[last+1:]
-	ln, err := strconv.Atoi(lineStr)
-	if err != nil {
-		return Match{}, false
+	// Every pattern must occur in the file.
+	var files map[string]bool
+	for i, plan := range plans {
+		next, _ := ix.Candidates([]index.Plan{plan})
+		if i > 0 {
+			for name := range next {
+				if !files[name] {
+					delete(next, name)
+				}
+			}
+		}
+		files = next
 	}
-	col, err := strconv.Atoi(colStr)
-	if err != nil {
-		return Match{}, false
+	return files, true
+}
+
+func hasArg(args []string, names ...string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			return false
+		}
+		for _, name := range names {
+			if arg == name {
+				return true
+			}
+		}
 	}
-	return Match{File: file, Line: ln, Column: col, Text: text}, true
+	return false
 }
 
-func parseRgContextLine(line string) (Match, bool) {
-	// file-line-text (rg -C with --no-heading --line-number --column)
-	last := strings.LastIndex(line, "-")
-	if last <= 0 {
-		return Match{}, false
+// keepFileFunc decides whether a file inside a jar is searched; read returns
+// its content.
+type keepFileFunc func(inner string, read func() ([]byte, error)) (bool, error)
+
+// fileFilter combines the file filter with the file-level part of the query.
+// It returns nil when every file is searched.
+func fileFilter(filter Filter, matcher *queryMatcher) keepFileFunc {
+	if filter.IsZero() && !matcher.filtersFiles() {
+		return nil
 	}
-	second := strings.LastIndex(line[:last], "-")
-	if second <= 0 {
-		return Match{}, false
+	return func(inner string, read func() ([]byte, error)) (bool, error) {
+		var data []byte
+		readOnce := func() ([]byte, error) {
+			if data != nil {
+				return data, nil
+			}
+			var err error
+			data, err = read()
+			return data, err
+		}
+		keep, err := filter.Keep(inner, readOnce)
+		if err != nil || !keep || !matcher.filtersFiles() {
+			return keep, err
+		}
+		content, err := readOnce()
+		if err != nil {
+			return false, err
+		}
+		return matcher.keepFile(content), nil
 	}
-	file := line[:second]
-	lineStr := line[second+1 : last]
-	text := line[last+1:]
-	ln, err := strconv.Atoi(lineStr)
-	if err != nil {
-		return Match{}, false
+}
+
+// onlyFiles restricts keep to the named files.
+func onlyFiles(files map[string]bool, keep keepFileFunc) keepFileFunc {
+	return func(inner string, read func() ([]byte, error)) (bool, error) {
+		if !files[inner] {
+			return false, nil
+		}
+		if keep == nil {
+			return true, nil
+		}
+		return keep(inner, read)
 	}
-	return Match{File: file, Line: ln, Column: 0, Text: text}, true
 }
 
-func mapToCoord(roots map[string]resolve.Coord, filePath string) (resolve.Coord, string, bool) {
-	for root, coord := range roots {
-		rel, err := filepath.Rel(root, filePath)
-		if err != nil || strings.HasPrefix(rel, "..") {
-			continue
+// HasContext reports whether rg arguments ask for context lines.
+func HasContext(rgArgs []string) bool {
+	for _, arg := range rgArgs {
+		if arg == "--" {
+			return false
+		}
+		for _, flag := range []string{"-A", "-B", "-C", "--context", "--after-context", "--before-context"} {
+			if arg == flag || strings.HasPrefix(arg, flag+"=") || (len(flag) == 2 && strings.HasPrefix(arg, flag) && len(arg) > 2 && arg[2] >= '0' && arg[2] <= '9') {
+				return true
+			}
 		}
-		rel = filepath.ToSlash(rel)
-		return coord, rel, true
 	}
-	return resolve.Coord{}, "", false
+	return false
+}
+
+// runRg runs ripgrep and passes each parsed output line to onLine. Exit code 1
+// (no matches) is not an error.
+func runRg(ctx context.Context, runner executil.Runner, dir string, args []string, onLine func(string) error) error {
+	seen := false
+	handle := func(line string) error {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return nil
+		}
+		seen = true
+		return onLine(line)
+	}
+
+	var stderr string
+	var err error
+	if lr, ok := runner.(executil.LineRunner); ok {
+		stderr, err = lr.RunLines(ctx, dir, "rg", args, handle)
+	} else {
+		var stdout string
+		stdout, stderr, err = runner.Run(ctx, dir, "rg", args...)
+		if err == nil || strings.TrimSpace(stdout) != "" {
+			runErr := err
+			err = nil
+			for _, line := range strings.Split(stdout, "\n") {
+				if err = handle(line); err != nil {
+					break
+				}
+			}
+			if err == nil && runErr != nil && !isNoMatches(runErr) && !seen {
+				err = runErr
+			}
+		}
+	}
+	if err == nil || isNoMatches(err) {
+		return nil
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if !seen {
+		return fmt.Errorf("rg failed: %w\n%s", err, strings.TrimSpace(stderr))
+	}
+	return err
+}
+
+// rgMessage is one line of `rg --json` output. Only "match" and "context"
+// messages are used.
+type rgMessage struct {
+	Type string `json:"type"`
+	Data struct {
+		Path       rgData `json:"path"`
+		Lines      rgData `json:"lines"`
+		LineNumber int    `json:"line_number"`
+		Submatches []struct {
+			Start int `json:"start"`
+			End   int `json:"end"`
+		} `json:"submatches"`
+	} `json:"data"`
+}
+
+// rgData holds text that rg reports either as UTF-8 or, for invalid UTF-8,
+// base64-encoded bytes.
+type rgData struct {
+	Text  *string `json:"text"`
+	Bytes *string `json:"bytes"`
+}
+
+func (d rgData) String() string {
+	if d.Text != nil {
+		return *d.Text
+	}
+	if d.Bytes != nil {
+		if b, err := base64.StdEncoding.DecodeString(*d.Bytes); err == nil {
+			return string(b)
+		}
+	}
+	return ""
+}
+
+// parseRgJSON parses a match or context message of `rg --json`. Columns and
+// submatch offsets are byte offsets into Text; Column is 1-based.
+func parseRgJSON(line string) (Match, bool) {
+	var msg rgMessage
+	if err := json.Unmarshal([]byte(line), &msg); err != nil {
+		return Match{}, false
+	}
+	if msg.Type != "match" && msg.Type != "context" {
+		return Match{}, false
+	}
+	file := msg.Data.Path.String()
+	if file == "" || msg.Data.LineNumber <= 0 {
+		return Match{}, false
+	}
+	text := strings.TrimRight(msg.Data.Lines.String(), "\r\n")
+	m := Match{File: file, Line: msg.Data.LineNumber, Text: text}
+	if msg.Type == "context" {
+		m.Context = true
+		return m, true
+	}
+	for _, sm := range msg.Data.Submatches {
+		start, end := min(sm.Start, len(text)), min(sm.End, len(text))
+		m.Submatches = append(m.Submatches, Submatch{Start: start, End: end, Text: text[start:end]})
+	}
+	m.Column = 1
+	if len(m.Submatches) > 0 {
+		m.Column = m.Submatches[0].Start + 1
+	}
+	return m, true
 }
 
-func mapToCoordFromJarPath(jarPaths map[string]resolve.Coord, filePath string) (resolve.Coord, string, bool) {
-	for jar, coord := range jarPaths {
-		prefix := jar + ":"
+func mapToJarPath(jars []resolve.SourceJar, filePath string) (resolve.SourceJar, string, bool) {
+	for _, jar := range jars {
+		prefix := jar.Path + ":"
 		if !strings.HasPrefix(filePath, prefix) {
 			continue
 		}
 		inner := strings.TrimPrefix(filePath, prefix)
 		inner = strings.TrimPrefix(inner, "/")
-		return coord, inner, true
+		return jar, inner, true
 	}
-	return resolve.Coord{}, "", false
+	return resolve.SourceJar{}, "", false
 }
 
-func runZipSearch(ctx context.Context, runner executil.Runner, opts Options) ([]Match, error) {
-	jarPaths := make(map[string]resolve.Coord, len(opts.Jars))
-	searchJars := make([]string, 0, len(opts.Jars))
-	for _, j := range opts.Jars {
-		jarPaths[j.Path] = j.Coord
-		searchJars = append(searchJars, j.Path)
-	}
+// maxCandidateGlobs is the most index candidates passed to ripgrep as globs;
+// with more, every Kotlin file is searched and the rest dropped afterwards.
+const maxCandidateGlobs = 512
 
-	args := []string{"--search-zip", "--no-heading", "--line-number", "--column", "--color=never", "--with-filename", "-g", "*.kt"}
+// runZipSearch runs ripgrep on jar itself. Index candidates, when not nil,
+// are the only files included.
+func runZipSearch(ctx context.Context, runner executil.Runner, opts Options, jar resolve.SourceJar, candidates map[string]bool, keepFile keepFileFunc, fn func(Match) error) error {
+	globs := zipGlobs(candidates)
+	if len(globs) == 0 {
+		return nil
+	}
+	// Files are reported in path order, so output is the same every time and
+	// a limited search stops at the same files.
+	args := append([]string{"--search-zip", "--json", "--color=never", "--sort", "path"}, globs...)
 	args = append(args, opts.RGArgs...)
-	args = append(args, opts.Pattern)
-	args = append(args, searchJars...)
+	args = append(args, opts.Query.rgArgs()...)
+	args = append(args, jar.Path)
 
-	stdout, stderr, err := runner.Run(ctx, opts.WorkDir, "rg", args...)
-	if err != nil {
-		if isNoMatches(err) {
-			return nil, nil
+	// rg reads the archives itself, so the filter is applied to its results.
+	archives := srcjar.NewArchives()
+	defer archives.Close()
+	kept := make(map[string]bool)
+	return runRg(ctx, runner, opts.WorkDir, args, func(line string) error {
+		m, ok := parseRgJSON(line)
+		if !ok {
+			return nil
+		}
+		_, inner, ok := mapToJarPath([]resolve.SourceJar{jar}, m.File)
+		if !ok {
+			return nil
 		}
-		if strings.TrimSpace(stdout) == "" {
-			return nil, fmt.Errorf("rg failed: %w\n%s", err, strings.TrimSpace(stderr))
+		m.FileID = jar.Coord.String() + "!/" + inner
+		if keepFile != nil {
+			keep, seen := kept[m.FileID]
+			if !seen {
+				var err error
+				keep, err = keepFile(inner, func() ([]byte, error) {
+					entry, err := srcjar.Find(archives, jar, inner)
+					if err != nil {
+						return nil, err
+					}
+					return entry.Read()
+				})
+				if err != nil {
+					return err
+				}
+				kept[m.FileID] = keep
+			}
+			if !keep {
+				return nil
+			}
 		}
-	}
+		return fn(m)
+	})
+}
 
-	matches := []Match{}
-	for _, line := range strings.Split(stdout, "\n") {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
+// zipGlobs returns the -g arguments selecting the Kotlin files to search:
+// the candidates, or every Kotlin file when there is no index or too many
+// candidates. It returns nil when no candidate is a Kotlin file.
+func zipGlobs(candidates map[string]bool) []string {
+	if candidates == nil || len(candidates) > maxCandidateGlobs {
+		return []string{"-g", "*.kt"}
+	}
+	var names []string
+	for name := range candidates {
+		if strings.HasSuffix(name, ".kt") {
+			names = append(names, name)
 		}
-		m, ok := parseRgLine(line)
-		if !ok {
-			continue
+	}
+	sort.Strings(names)
+	var globs []string
+	for _, name := range names {
+		globs = append(globs, "-g", "**/"+globEscaper.Replace(name))
+	}
+	return globs
+}
+
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`, "{", `\{`, "}", `\}`)
+
+// extractor runs ripgrep on extracted jars for runners without zip support.
+// Jars are extracted into the cache returned by open, asked for on first
+// use, or into a temporary one without it.
+type extractor struct {
+	open  func() (*extract.Cache, error)
+	once  sync.Once
+	cache *extract.Cache
+	err   error
+	tmp   string
+}
+
+func (ex *extractor) getCache() (*extract.Cache, error) {
+	ex.once.Do(func() {
+		if ex.open != nil {
+			ex.cache, ex.err = ex.open()
+			return
 		}
-		coord, inner, ok := mapToCoordFromJarPath(jarPaths, m.File)
-		if !ok {
-			continue
+		if ex.tmp, ex.err = os.MkdirTemp("", "ksrc-search-"); ex.err == nil {
+			ex.cache = &extract.Cache{Dir: ex.tmp}
 		}
-		m.FileID = coord.String() + "!/" + inner
-		matches = append(matches, m)
+	})
+	return ex.cache, ex.err
+}
+
+// Close removes the temporary cache, or evicts old extractions from the
+// shared one.
+func (ex *extractor) Close() {
+	switch {
+	case ex.tmp != "":
+		_ = os.RemoveAll(ex.tmp)
+	case ex.cache != nil:
+		_ = ex.cache.Evict()
 	}
-	return matches, nil
 }
 
-func runExtractSearch(ctx context.Context, runner executil.Runner, opts Options) ([]Match, error) {
-	root, err := os.MkdirTemp("", "ksrc-search-")
+// search runs ripgrep on the extraction of jar, in place unless files are
+// filtered; the files that pass keep are linked into a temporary directory
+// and searched there. Reported paths always point into the extraction.
+func (ex *extractor) search(ctx context.Context, runner executil.Runner, opts Options, jar resolve.SourceJar, keep keepFileFunc, fn func(Match) error) error {
+	cache, err := ex.getCache()
 	if err != nil {
-		return nil, err
+		return err
+	}
+	dir, err := cache.Extract(jar.Path)
+	if err != nil {
+		return err
+	}
+	defer cache.Release(dir)
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-	defer os.RemoveAll(root)
 
-	extractRoots := make(map[string]resolve.Coord)
-	searchDirs := make([]string, 0, len(opts.Jars))
-	for i, j := range opts.Jars {
-		dir := filepath.Join(root, fmt.Sprintf("jar-%d", i))
-		if err := extractJar(j.Path, dir); err != nil {
-			return nil, err
+	searchDir := dir
+	if keep != nil {
+		links, err := os.MkdirTemp("", "ksrc-search-")
+		if err != nil {
+			return err
 		}
-		extractRoots[dir] = j.Coord
-		searchDirs = append(searchDirs, dir)
+		defer os.RemoveAll(links)
+		n, err := linkFiles(dir, links, keep)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			// Nothing in this jar passed the filter.
+			return nil
+		}
+		searchDir = links
+	}
+	return runDirSearch(ctx, runner, opts, jar, searchDir, func(rel string) string {
+		return filepath.Join(dir, rel)
+	}, fn)
+}
+
+// searchCandidates runs ripgrep on the files of jar that pass keep, read
+// straight from the jar into a temporary directory, so narrowing by the index
+// never extracts whole jars. The directory is removed afterwards; reported
+// paths are "<jar>:<path>", as with zip search.
+func searchCandidates(ctx context.Context, runner executil.Runner, opts Options, jar resolve.SourceJar, keep keepFileFunc, fn func(Match) error) error {
+	dir, err := os.MkdirTemp("", "ksrc-search-")
+	if err != nil {
+		return err
 	}
+	defer os.RemoveAll(dir)
+	n, err := unzipFiles(jar.Path, dir, keep)
+	if err != nil || n == 0 {
+		return err
+	}
+	return runDirSearch(ctx, runner, opts, jar, dir, func(rel string) string {
+		return jar.Path + ":" + filepath.ToSlash(rel)
+	}, fn)
+}
 
-	args := []string{"--no-heading", "--line-number", "--column", "--color=never", "--with-filename", "-g", "*.kt"}
+// runDirSearch runs ripgrep on the files of jar below dir; path maps a file
+// relative to dir to the path reported in Match.File.
+func runDirSearch(ctx context.Context, runner executil.Runner, opts Options, jar resolve.SourceJar, dir string, path func(rel string) string, fn func(Match) error) error {
+	// Files are reported in path order, so output is the same every time and
+	// a limited search stops at the same files.
+	args := []string{"--json", "--color=never", "--sort", "path", "-g", "*.kt"}
 	args = append(args, opts.RGArgs...)
-	args = append(args, opts.Pattern)
-	args = append(args, searchDirs...)
+	args = append(args, opts.Query.rgArgs()...)
+	args = append(args, dir)
 
-	stdout, stderr, err := runner.Run(ctx, opts.WorkDir, "rg", args...)
+	return runRg(ctx, runner, opts.WorkDir, args, func(line string) error {
+		m, ok := parseRgJSON(line)
+		if !ok {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, m.File)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return nil
+		}
+		m.FileID = jar.Coord.String() + "!/" + filepath.ToSlash(rel)
+		m.File = path(rel)
+		return fn(m)
+	})
+}
+
+// unzipFiles writes the source files of jar that pass keep below dest and
+// returns how many there are.
+func unzipFiles(jar, dest string, keep keepFileFunc) (int, error) {
+	zr, err := zip.OpenReader(jar)
 	if err != nil {
-		if isNoMatches(err) {
-			return nil, nil
+		return 0, err
+	}
+	defer zr.Close()
+	n := 0
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || !srcjar.IsSource(f.Name) {
+			continue
+		}
+		var data []byte
+		var readErr error
+		read := func() ([]byte, error) {
+			if data == nil && readErr == nil {
+				data, readErr = readZipFile(f)
+			}
+			return data, readErr
+		}
+		if keep != nil {
+			ok, err := keep(f.Name, read)
+			if err != nil {
+				return n, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		if _, err := read(); err != nil {
+			return n, err
+		}
+		target := filepath.Clean(filepath.Join(dest, filepath.FromSlash(f.Name)))
+		if !strings.HasPrefix(target, dest+string(filepath.Separator)) {
+			return n, fmt.Errorf("invalid path in archive: %s", f.Name)
 		}
-		if strings.TrimSpace(stdout) == "" {
-			return nil, fmt.Errorf("rg failed: %w\n%s", err, strings.TrimSpace(stderr))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return n, err
 		}
+		if err := os.WriteFile(target, data, 0o644); err != nil {
+			return n, err
+		}
+		n++
 	}
+	return n, nil
+}
 
-	matches := []Match{}
-	for _, line := range strings.Split(stdout, "\n") {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// linkFiles hard-links the files of the extraction src that pass keep into
+// dest, copying them where links are not supported, and returns how many there
+// are. A nil keep links every file.
+func linkFiles(src, dest string, keep keepFileFunc) (int, error) {
+	n := 0
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
 		}
-		m, ok := parseRgLine(line)
-		if !ok {
-			continue
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
 		}
-		coord, inner, ok := mapToCoord(extractRoots, m.File)
-		if !ok {
-			continue
+		inner := filepath.ToSlash(rel)
+		if keep != nil {
+			if !srcjar.IsSource(inner) {
+				return nil
+			}
+			ok, err := keep(inner, func() ([]byte, error) { return os.ReadFile(path) })
+			if err != nil || !ok {
+				return err
+			}
 		}
-		m.FileID = coord.String() + "!/" + inner
-		matches = append(matches, m)
+		target := filepath.Join(dest, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		if err := os.Link(path, target); err != nil {
+			if err := copyFile(path, target); err != nil {
+				return err
+			}
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
+
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
 	}
-	return matches, nil
+	defer in.Close()
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
 }
 
 type exitCoder interface {
@@ -252,13 +736,13 @@ func supportsZipSearch(ctx context.Context, runner executil.Runner) bool {
 	_ = file.Close()
 	defer os.Remove(path)
 
-	args := []string{"--search-zip", "--no-heading", "--line-number", "--column", "--color=never", "-g", "*.txt", "ksrc-zip-probe", path}
+	args := []string{"--search-zip", "--json", "--color=never", "-g", "*.txt", "ksrc-zip-probe", path}
 	stdout, _, err := runner.Run(ctx, "", "rg", args...)
 	if err != nil {
 		return false
 	}
 	for _, line := range strings.Split(stdout, "\n") {
-		m, ok := parseRgLine(strings.TrimSpace(line))
+		m, ok := parseRgJSON(strings.TrimSpace(line))
 		if !ok {
 			continue
 		}
@@ -268,42 +752,3 @@ func supportsZipSearch(ctx context.Context, runner executil.Runner) bool {
 	}
 	return false
 }
-
-func extractJar(src, dest string) error {
-	zr, err := zip.OpenReader(src)
-	if err != nil {
-		return err
-	}
-	defer zr.Close()
-
-	for _, f := range zr.File {
-		if f.FileInfo().IsDir() {
-			continue
-		}
-		path := filepath.Join(dest, filepath.FromSlash(f.Name))
-		clean := filepath.Clean(path)
-		if !strings.HasPrefix(clean, dest) {
-			return fmt.Errorf("invalid path in archive: %s", f.Name)
-		}
-		if err := os.MkdirAll(filepath.Dir(clean), 0o755); err != nil {
-			return err
-		}
-		rc, err := f.Open()
-		if err != nil {
-			return err
-		}
-		out, err := os.Create(clean)
-		if err != nil {
-			_ = rc.Close()
-			return err
-		}
-		if _, err := io.Copy(out, rc); err != nil {
-			_ = out.Close()
-			_ = rc.Close()
-			return err
-		}
-		_ = out.Close()
-		_ = rc.Close()
-	}
-	return nil
-}
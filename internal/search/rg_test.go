@@ -2,33 +2,42 @@ package search
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/respawn-app/ksrc/internal/extract"
 	"github.com/respawn-app/ksrc/internal/resolve"
 )
 
-func TestParseRgLine(t *testing.T) {
-	line := "/tmp/lib.jar:com/foo/Bar.kt:12:3:match text"
-	m, ok := parseRgLine(line)
+func TestParseRgJSONMatch(t *testing.T) {
+	line := `{"type":"match","data":{"path":{"text":"/tmp/lib-1:2.jar:com/foo/Foo-Bar.kt"},"lines":{"text":"val x: Int = a:b - c\n"},"line_number":12,"absolute_offset":0,"submatches":[{"match":{"text":"a:b"},"start":13,"end":16},{"match":{"text":"c"},"start":19,"end":20}]}}`
+	m, ok := parseRgJSON(line)
 	if !ok {
 		t.Fatal("expected parse ok")
 	}
-	if m.File != "/tmp/lib.jar:com/foo/Bar.kt" || m.Line != 12 || m.Column != 3 || m.Text != "match text" {
+	if m.File != "/tmp/lib-1:2.jar:com/foo/Foo-Bar.kt" || m.Line != 12 || m.Column != 14 || m.Text != "val x: Int = a:b - c" || m.Context {
 		t.Fatalf("unexpected match: %+v", m)
 	}
+	if len(m.Submatches) != 2 || m.Submatches[0].Text != "a:b" || m.Submatches[1].Start != 19 || m.Submatches[1].End != 20 {
+		t.Fatalf("unexpected submatches: %+v", m.Submatches)
+	}
 }
 
-func TestParseRgContextLine(t *testing.T) {
-	line := "/tmp/foo-bar/baz.kt-7-context line"
-	m, ok := parseRgLine(line)
+func TestParseRgJSONContext(t *testing.T) {
+	line := `{"type":"context","data":{"path":{"text":"/tmp/foo-bar/baz.kt"},"lines":{"bytes":"Y29udGV4dCBsaW5lCg=="},"line_number":7,"absolute_offset":0,"submatches":[]}}`
+	m, ok := parseRgJSON(line)
 	if !ok {
 		t.Fatal("expected parse ok")
 	}
-	if m.File != "/tmp/foo-bar/baz.kt" || m.Line != 7 || m.Column != 0 || m.Text != "context line" {
+	if m.File != "/tmp/foo-bar/baz.kt" || m.Line != 7 || m.Column != 0 || m.Text != "context line" || !m.Context {
 		t.Fatalf("unexpected match: %+v", m)
 	}
+	if _, ok := parseRgJSON(`{"type":"begin","data":{"path":{"text":"/tmp/a.kt"}}}`); ok {
+		t.Fatal("expected begin message to be skipped")
+	}
 }
 
 func TestRunUsesZipSearchWhenSupported(t *testing.T) {
@@ -37,9 +46,11 @@ func TestRunUsesZipSearchWhenSupported(t *testing.T) {
 	runner := &fakeRunner{jarPath: jarPath}
 
 	matches, err := Run(context.Background(), runner, Options{
-		Pattern: "Needle",
+		Query:   Query{Patterns: []string{"Needle"}},
 		Jars:    []resolve.SourceJar{{Coord: coord, Path: jarPath}},
 		WorkDir: ".",
+		// Zip search never needs the extraction cache.
+		Extracts: func() (*extract.Cache, error) { return nil, fmt.Errorf("invalid cache size") },
 	})
 	if err != nil {
 		t.Fatalf("Run error: %v", err)
@@ -61,7 +72,7 @@ func TestRunTreatsExitCodeOneAsNoMatches(t *testing.T) {
 	runner := &exitCodeRunner{jarPath: jarPath, exitCode: 1}
 
 	matches, err := Run(context.Background(), runner, Options{
-		Pattern: "Needle",
+		Query:   Query{Patterns: []string{"Needle"}},
 		Jars:    []resolve.SourceJar{{Coord: coord, Path: jarPath}},
 		WorkDir: ".",
 	})
@@ -90,10 +101,10 @@ func (f *fakeRunner) Run(_ context.Context, _ string, name string, args ...strin
 	}
 	if containsArg(args, "ksrc-zip-probe") {
 		path := args[len(args)-1]
-		return fmt.Sprintf("%s:probe.txt:1:1:ksrc-zip-probe\n", path), "", nil
+		return rgJSONLine("match", path+":probe.txt", 1, "ksrc-zip-probe") + "\n", "", nil
 	}
 	if containsArg(args, "Needle") {
-		return fmt.Sprintf("%s:com/foo/Bar.kt:12:3:Needle\n", f.jarPath), "", nil
+		return rgJSONLine("match", f.jarPath+":com/foo/Bar.kt", 12, "  Needle") + "\n", "", nil
 	}
 	return "", "", nil
 }
@@ -122,7 +133,7 @@ func (e *exitCodeRunner) Run(_ context.Context, _ string, name string, args ...s
 	}
 	if containsArg(args, "ksrc-zip-probe") {
 		path := args[len(args)-1]
-		return fmt.Sprintf("%s:probe.txt:1:1:ksrc-zip-probe\n", path), "", nil
+		return rgJSONLine("match", path+":probe.txt", 1, "ksrc-zip-probe") + "\n", "", nil
 	}
 	if containsArg(args, "Needle") {
 		return "", "", exitError{code: e.exitCode}
@@ -145,3 +156,68 @@ func (e exitError) Error() string {
 func (e exitError) ExitCode() int {
 	return e.code
 }
+
+func TestStreamStopsAtMaxResults(t *testing.T) {
+	jarPath := filepath.Join(t.TempDir(), "foo.jar")
+	coord := resolve.Coord{Group: "com.example", Artifact: "foo", Version: "1.0.0"}
+	runner := &lineRunner{fakeRunner: fakeRunner{jarPath: jarPath}, lines: []string{
+		rgJSONLine("match", jarPath+":com/foo/A.kt", 1, "Needle one"),
+		rgJSONLine("context", jarPath+":com/foo/A.kt", 2, "after one"),
+		rgJSONLine("context", jarPath+":com/foo/A.kt", 9, "before two"),
+		rgJSONLine("match", jarPath+":com/foo/A.kt", 10, "Needle two"),
+		rgJSONLine("match", jarPath+":com/foo/B.kt", 1, "Needle three"),
+	}}
+
+	var got []Match
+	err := Stream(context.Background(), runner, Options{
+		Query:      Query{Patterns: []string{"Needle"}},
+		Jars:       []resolve.SourceJar{{Coord: coord, Path: jarPath}},
+		MaxResults: 1,
+	}, func(m Match) error {
+		got = append(got, m)
+		return nil
+	})
+	if !errors.Is(err, ErrLimitReached) {
+		t.Fatalf("expected ErrLimitReached, got %v", err)
+	}
+	if len(got) != 2 || got[1].Text != "after one" || !got[1].Context {
+		t.Fatalf("unexpected matches: %+v", got)
+	}
+	if runner.consumed != 3 {
+		t.Fatalf("expected rg output to stop after 3 lines, read %d", runner.consumed)
+	}
+}
+
+type lineRunner struct {
+	fakeRunner
+	lines    []string
+	consumed int
+	// args are the arguments of the last run.
+	args []string
+}
+
+func (l *lineRunner) RunLines(ctx context.Context, dir string, name string, args []string, onLine func(string) error) (string, error) {
+	l.args = args
+	for _, line := range l.lines {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		l.consumed++
+		if err := onLine(line); err != nil {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// rgJSONLine renders an `rg --json` message; match messages get a submatch for
+// the first word of text after leading spaces.
+func rgJSONLine(kind, path string, line int, text string) string {
+	sub := "[]"
+	if kind == "match" {
+		start := len(text) - len(strings.TrimLeft(text, " "))
+		end := strings.IndexByte(text[start:]+" ", ' ') + start
+		sub = fmt.Sprintf(`[{"match":{"text":%q},"start":%d,"end":%d}]`, text[start:end], start, end)
+	}
+	return fmt.Sprintf(`{"type":%q,"data":{"path":{"text":%q},"lines":{"text":%q},"line_number":%d,"absolute_offset":0,"submatches":%s}}`, kind, path, text+"\n", line, sub)
+}
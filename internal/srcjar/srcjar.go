@@ -0,0 +1,138 @@
+package srcjar
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/respawn-app/ksrc/internal/resolve"
+)
+
+// SkipAll stops a Walk without reporting an error.
+var SkipAll = errors.New("skip all entries")
+
+// Archives keeps source jars open so repeated reads share one zip reader.
+type Archives struct {
+	mu      sync.Mutex
+	readers map[string]*zip.ReadCloser
+}
+
+func NewArchives() *Archives {
+	return &Archives{readers: make(map[string]*zip.ReadCloser)}
+}
+
+func (a *Archives) Open(path string) (*zip.Reader, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if zr, ok := a.readers[path]; ok {
+		return &zr.Reader, nil
+	}
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	a.readers[path] = zr
+	return &zr.Reader, nil
+}
+
+func (a *Archives) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var firstErr error
+	for path, zr := range a.readers {
+		if err := zr.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(a.readers, path)
+	}
+	return firstErr
+}
+
+// Entry is a file inside a source jar.
+type Entry struct {
+	Jar  resolve.SourceJar
+	File *zip.File
+}
+
+func (e Entry) Name() string {
+	return e.File.Name
+}
+
+func (e Entry) FileID() string {
+	return resolve.FormatFileID(e.Jar.Coord, e.File.Name)
+}
+
+func (e Entry) Read() ([]byte, error) {
+	rc, err := e.File.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// IsSource reports whether an inner path is a Kotlin or Java source file.
+func IsSource(name string) bool {
+	return strings.HasSuffix(name, ".kt") || strings.HasSuffix(name, ".java")
+}
+
+// SourceSet returns the KMP source set a path inside a sources jar belongs to
+// (commonMain/..., jvmMain/...), or "" for single-platform layouts.
+func SourceSet(inner string) string {
+	first, _, ok := strings.Cut(inner, "/")
+	if !ok {
+		return ""
+	}
+	if strings.HasSuffix(first, "Main") || strings.HasSuffix(first, "Test") {
+		return first
+	}
+	return ""
+}
+
+// Walk calls fn for every file entry accepted by keep, in jar order and then
+// archive order. Returning SkipAll from fn ends the walk early.
+func Walk(archives *Archives, jars []resolve.SourceJar, keep func(name string) bool, fn func(Entry) error) error {
+	if archives == nil {
+		archives = NewArchives()
+		defer archives.Close()
+	}
+	for _, jar := range jars {
+		zr, err := archives.Open(jar.Path)
+		if err != nil {
+			return err
+		}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			if keep != nil && !keep(f.Name) {
+				continue
+			}
+			if err := fn(Entry{Jar: jar, File: f}); err != nil {
+				if errors.Is(err, SkipAll) {
+					return nil
+				}
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Find returns the entry for inner in jar.
+func Find(archives *Archives, jar resolve.SourceJar, inner string) (Entry, error) {
+	zr, err := archives.Open(jar.Path)
+	if err != nil {
+		return Entry{}, err
+	}
+	inner = strings.TrimPrefix(inner, "/")
+	for _, f := range zr.File {
+		if f.Name == inner {
+			return Entry{Jar: jar, File: f}, nil
+		}
+	}
+	return Entry{}, fmt.Errorf("file not found in archive: %s", inner)
+}
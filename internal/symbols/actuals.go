@@ -0,0 +1,97 @@
+package symbols
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/respawn-app/ksrc/internal/kotlin"
+	"github.com/respawn-app/ksrc/internal/resolve"
+	"github.com/respawn-app/ksrc/internal/srcjar"
+)
+
+// Counterpart is one side of a multiplatform expect/actual pair.
+type Counterpart struct {
+	Symbol
+	Expect bool
+	// SourceSet is the KMP source set of the file (commonMain, jvmMain, ...),
+	// or "" when the jar has no source set directories.
+	SourceSet string
+}
+
+// Actuals finds the expect declarations matching name (FQN or suffix, as in
+// Find) together with all actual declarations sharing their FQN. Looking up
+// an actual returns its expect and the other actuals. Expects come first.
+func Actuals(archives *srcjar.Archives, jars []resolve.SourceJar, name string) ([]Counterpart, error) {
+	found, err := Find(archives, jars, name)
+	if err != nil {
+		return nil, err
+	}
+	return counterparts(found), nil
+}
+
+// ActualsAt is Actuals for the expect or actual declaration containing line in
+// entry. It returns the FQN that was looked up.
+func ActualsAt(archives *srcjar.Archives, jars []resolve.SourceJar, entry srcjar.Entry, line int) (string, []Counterpart, error) {
+	data, err := entry.Read()
+	if err != nil {
+		return "", nil, err
+	}
+	f := kotlin.Parse(data, kotlin.LangForPath(entry.Name()))
+	chain := f.Enclosing(line)
+	var fqn string
+	for i := len(chain) - 1; i >= 0; i-- {
+		if isExpect(chain[i]) || chain[i].HasModifier("actual") {
+			fqn = f.FQN(chain[i])
+			break
+		}
+	}
+	if fqn == "" {
+		return "", nil, fmt.Errorf("no expect or actual declaration at %s:%d", entry.FileID(), line)
+	}
+	found, err := Lookup(archives, jars, []string{fqn})
+	if err != nil {
+		return fqn, nil, err
+	}
+	return fqn, counterparts(found[fqn]), nil
+}
+
+// counterparts keeps the expect and actual declarations among found, grouped
+// by FQN in first-appearance order, expects before actuals.
+func counterparts(found []Symbol) []Counterpart {
+	var fqns []string
+	byFQN := make(map[string][]Counterpart)
+	for _, s := range found {
+		expect := isExpect(s.Decl)
+		if !expect && !s.Decl.HasModifier("actual") {
+			continue
+		}
+		if _, ok := byFQN[s.FQN]; !ok {
+			fqns = append(fqns, s.FQN)
+		}
+		byFQN[s.FQN] = append(byFQN[s.FQN], Counterpart{
+			Symbol:    s,
+			Expect:    expect,
+			SourceSet: srcjar.SourceSet(s.Inner),
+		})
+	}
+	var out []Counterpart
+	for _, fqn := range fqns {
+		group := byFQN[fqn]
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].Expect && !group[j].Expect
+		})
+		out = append(out, group...)
+	}
+	return out
+}
+
+// isExpect reports whether d is an expect declaration. Members of an expect
+// class are implicitly expect.
+func isExpect(d *kotlin.Decl) bool {
+	for ; d != nil; d = d.Parent {
+		if d.HasModifier("expect") {
+			return true
+		}
+	}
+	return false
+}
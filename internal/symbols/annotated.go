@@ -0,0 +1,64 @@
+package symbols
+
+import (
+	"strings"
+
+	"github.com/respawn-app/ksrc/internal/kotlin"
+	"github.com/respawn-app/ksrc/internal/resolve"
+	"github.com/respawn-app/ksrc/internal/srcjar"
+)
+
+// Annotated finds declarations carrying the annotation name. name may be
+// simple (Composable) or qualified (androidx.compose.runtime.Composable); a
+// qualified name only matches usages that resolve to it through the file's
+// imports, package or the default imports.
+func Annotated(archives *srcjar.Archives, jars []resolve.SourceJar, name string) ([]Symbol, error) {
+	want := strings.TrimPrefix(strings.TrimSpace(name), "@")
+	simple := kotlin.SimpleName(want)
+	qualified := strings.Contains(want, ".")
+
+	var out []Symbol
+	err := scan(archives, jars, simple, func(e srcjar.Entry, f *kotlin.File) error {
+		f.Walk(func(d *kotlin.Decl) bool {
+			for _, a := range d.Annotations {
+				if annotationMatches(f, a.Name, want, simple, qualified) {
+					out = append(out, newSymbol(e, f, d))
+					break
+				}
+			}
+			return true
+		})
+		return nil
+	})
+	return out, err
+}
+
+func annotationMatches(f *kotlin.File, written, want, simple string, qualified bool) bool {
+	if !qualified {
+		if kotlin.SimpleName(written) == simple {
+			return true
+		}
+		// Aliased imports: @C where C is imported as an alias of the annotation.
+		for _, candidate := range f.ResolveType(written) {
+			if kotlin.SimpleName(candidate) == simple {
+				return true
+			}
+		}
+		return false
+	}
+	if written == want {
+		return true
+	}
+	candidates := f.ResolveType(written)
+	if !strings.Contains(written, ".") {
+		for _, pkg := range defaultImports {
+			candidates = append(candidates, pkg+"."+written)
+		}
+	}
+	for _, candidate := range candidates {
+		if candidate == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package symbols
+
+import (
+	"path"
+	"strings"
+
+	"github.com/respawn-app/ksrc/internal/kotlin"
+	"github.com/respawn-app/ksrc/internal/resolve"
+	"github.com/respawn-app/ksrc/internal/srcjar"
+)
+
+// Class locates a class, interface, object or type alias by fully qualified
+// name. Jars whose group is a prefix of the name (androidx.compose.runtime for
+// androidx.compose.runtime.State) are searched first, the others only when
+// they do not declare it.
+func Class(archives *srcjar.Archives, jars []resolve.SourceJar, fqn string) ([]Symbol, error) {
+	fqn = strings.TrimSpace(fqn)
+	if archives == nil {
+		archives = srcjar.NewArchives()
+		defer archives.Close()
+	}
+	var likely, rest []resolve.SourceJar
+	for _, jar := range jars {
+		if group := jar.Coord.Group; group != "" && strings.HasPrefix(fqn, group+".") {
+			likely = append(likely, jar)
+		} else {
+			rest = append(rest, jar)
+		}
+	}
+	if len(likely) > 0 {
+		found, err := classIn(archives, likely, fqn)
+		if err != nil || len(found) > 0 {
+			return found, err
+		}
+	}
+	return classIn(archives, rest, fqn)
+}
+
+// classIn looks for the declaration in jars. Only files in the directories
+// its possible packages map to are read at first (with or without a source set
+// prefix such as commonMain/): Kotlin files may be named differently from their
+// classes or declare several, so all of them are parsed. When none declares
+// the class, every file mentioning its simple name is parsed instead.
+func classIn(archives *srcjar.Archives, jars []resolve.SourceJar, fqn string) ([]Symbol, error) {
+	if len(jars) == 0 {
+		return nil, nil
+	}
+	dirs := packageDirs(fqn)
+	var found []Symbol
+	collect := func(e srcjar.Entry, f *kotlin.File) {
+		f.Walk(func(d *kotlin.Decl) bool {
+			if (d.IsType() || d.Kind == "typealias") && f.FQN(d) == fqn {
+				found = append(found, newSymbol(e, f, d))
+			}
+			return d.IsType()
+		})
+	}
+
+	err := srcjar.Walk(archives, jars, func(name string) bool {
+		return srcjar.IsSource(name) && dirs[packageDir(name)]
+	}, func(e srcjar.Entry) error {
+		data, err := e.Read()
+		if err != nil {
+			return err
+		}
+		collect(e, kotlin.Parse(data, kotlin.LangForPath(e.Name())))
+		return nil
+	})
+	if err != nil || len(found) > 0 {
+		return found, err
+	}
+
+	err = scan(archives, jars, kotlin.SimpleName(fqn), func(e srcjar.Entry, f *kotlin.File) error {
+		collect(e, f)
+		return nil
+	})
+	return found, err
+}
+
+// packageDirs returns the directories of every package fqn may belong to: each
+// proper prefix of its dotted name, since nested classes make the split
+// ambiguous (java.util.Map.Entry).
+func packageDirs(fqn string) map[string]bool {
+	parts := strings.Split(fqn, ".")
+	dirs := make(map[string]bool, len(parts))
+	for i := range parts {
+		dirs[strings.Join(parts[:i], "/")] = true
+	}
+	return dirs
+}
+
+// packageDir returns the directory of an inner path without its source set.
+func packageDir(inner string) string {
+	if set := srcjar.SourceSet(inner); set != "" {
+		inner = strings.TrimPrefix(inner, set+"/")
+	}
+	dir := path.Dir(inner)
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
@@ -0,0 +1,135 @@
+package symbols
+
+import (
+	"fmt"
+
+	"github.com/respawn-app/ksrc/internal/kotlin"
+	"github.com/respawn-app/ksrc/internal/resolve"
+	"github.com/respawn-app/ksrc/internal/srcjar"
+)
+
+var defaultImports = []string{
+	"kotlin",
+	"kotlin.annotation",
+	"kotlin.collections",
+	"kotlin.comparisons",
+	"kotlin.io",
+	"kotlin.ranges",
+	"kotlin.sequences",
+	"kotlin.text",
+	"kotlin.jvm",
+	"java.lang",
+}
+
+// Definitions resolves the identifier at line:col in entry to its declarations.
+// Candidates are tried in Kotlin scope order: an explicit qualifier, explicit
+// imports, the same file, the file's package, star imports and default imports.
+// When nothing resolves, declarations with the same name in entry's jar are
+// returned as a best-effort fallback. It returns the identifier that was resolved.
+func Definitions(archives *srcjar.Archives, jars []resolve.SourceJar, entry srcjar.Entry, line, col int) (string, []Symbol, error) {
+	data, err := entry.Read()
+	if err != nil {
+		return "", nil, err
+	}
+	lang := kotlin.LangForPath(entry.Name())
+	toks := kotlin.Lex(data, lang)
+	idx := kotlin.TokenAt(toks, line, col)
+	if idx < 0 || toks[idx].Kind != kotlin.Ident {
+		return "", nil, fmt.Errorf("no identifier at %s:%d:%d", entry.FileID(), line, col)
+	}
+	name := toks[idx].Text
+	qualifier := kotlin.QualifierAt(toks, idx)
+	f := kotlin.Parse(data, lang)
+
+	var tiers [][]string
+	if qualifier != "" {
+		tier := []string{qualifier + "." + name}
+		for _, owner := range f.ResolveType(qualifier) {
+			tier = append(tier, owner+"."+name)
+		}
+		tiers = append(tiers, tier)
+	}
+	var local []Symbol
+	f.Walk(func(d *kotlin.Decl) bool {
+		if d.Name == name {
+			local = append(local, newSymbol(entry, f, d))
+		}
+		return d.IsType()
+	})
+	var explicit, stars, defaults []string
+	for _, imp := range f.Imports {
+		switch {
+		case imp.Star:
+			stars = append(stars, imp.Path+"."+name)
+		case imp.Name() == name:
+			explicit = append(explicit, imp.Path)
+		}
+	}
+	samePackage := []string{name}
+	if f.Package != "" {
+		samePackage = []string{f.Package + "." + name}
+	}
+	for _, pkg := range defaultImports {
+		defaults = append(defaults, pkg+"."+name)
+	}
+
+	var all []string
+	for _, tier := range tiers {
+		all = append(all, tier...)
+	}
+	all = append(all, explicit...)
+	all = append(all, samePackage...)
+	all = append(all, stars...)
+	all = append(all, defaults...)
+	found, err := Lookup(archives, ownJarFirst(jars, entry.Jar), all)
+	if err != nil {
+		return name, nil, err
+	}
+
+	pick := func(fqns []string) []Symbol {
+		var out []Symbol
+		for _, fqn := range fqns {
+			out = append(out, found[fqn]...)
+		}
+		return out
+	}
+	for _, tier := range tiers {
+		if syms := pick(tier); len(syms) > 0 {
+			return name, syms, nil
+		}
+	}
+	if syms := pick(explicit); len(syms) > 0 {
+		return name, syms, nil
+	}
+	if len(local) > 0 {
+		return name, local, nil
+	}
+	for _, tier := range [][]string{samePackage, stars, defaults} {
+		if syms := pick(tier); len(syms) > 0 {
+			return name, syms, nil
+		}
+	}
+
+	var fallback []Symbol
+	err = scan(archives, []resolve.SourceJar{entry.Jar}, name, func(e srcjar.Entry, f *kotlin.File) error {
+		f.Walk(func(d *kotlin.Decl) bool {
+			if d.Name == name {
+				fallback = append(fallback, newSymbol(e, f, d))
+			}
+			return d.IsType()
+		})
+		return nil
+	})
+	return name, fallback, err
+}
+
+func ownJarFirst(jars []resolve.SourceJar, own resolve.SourceJar) []resolve.SourceJar {
+	out := make([]resolve.SourceJar, 0, len(jars)+1)
+	out = append(out, own)
+	for _, j := range jars {
+		if j.Path != own.Path {
+			out = append(out, j)
+		}
+	}
+	return out
+}
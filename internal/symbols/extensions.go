@@ -0,0 +1,45 @@
+package symbols
+
+import (
+	"strings"
+
+	"github.com/respawn-app/ksrc/internal/kotlin"
+	"github.com/respawn-app/ksrc/internal/resolve"
+	"github.com/respawn-app/ksrc/internal/srcjar"
+)
+
+// Extensions finds extension functions and properties whose receiver is
+// typeName. typeName may be simple (Flow) or qualified
+// (kotlinx.coroutines.flow.Flow); type arguments and nullability are ignored,
+// and receivers written as bounded type parameters match their bounds.
+func Extensions(archives *srcjar.Archives, jars []resolve.SourceJar, typeName string) ([]Symbol, error) {
+	want := kotlin.BaseType(typeName)
+	simple := kotlin.SimpleName(want)
+	qualified := strings.Contains(want, ".")
+
+	var out []Symbol
+	err := scan(archives, jars, simple, func(e srcjar.Entry, f *kotlin.File) error {
+		f.Walk(func(d *kotlin.Decl) bool {
+			if d.Receiver != "" && receiverMatches(f, d, want, simple, qualified) {
+				out = append(out, newSymbol(e, f, d))
+			}
+			return d.IsType()
+		})
+		return nil
+	})
+	return out, err
+}
+
+func receiverMatches(f *kotlin.File, d *kotlin.Decl, want, simple string, qualified bool) bool {
+	for _, recv := range d.ReceiverTypes() {
+		for _, candidate := range f.ResolveType(recv) {
+			if qualified && candidate == want {
+				return true
+			}
+			if !qualified && kotlin.SimpleName(candidate) == simple {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package symbols
+
+import (
+	"strings"
+
+	"github.com/respawn-app/ksrc/internal/kotlin"
+	"github.com/respawn-app/ksrc/internal/resolve"
+	"github.com/respawn-app/ksrc/internal/srcjar"
+)
+
+// Find locates declarations by a fully or partially qualified name. Exact FQN
+// matches win; otherwise declarations whose FQN ends with the given dotted
+// suffix are returned (e.g. Flow.collect or collect).
+func Find(archives *srcjar.Archives, jars []resolve.SourceJar, name string) ([]Symbol, error) {
+	name = strings.TrimSpace(name)
+	var exact, suffix []Symbol
+	err := scan(archives, jars, kotlin.SimpleName(name), func(e srcjar.Entry, f *kotlin.File) error {
+		f.Walk(func(d *kotlin.Decl) bool {
+			fqn := f.FQN(d)
+			switch {
+			case fqn == name:
+				exact = append(exact, newSymbol(e, f, d))
+			case strings.HasSuffix(fqn, "."+name):
+				suffix = append(suffix, newSymbol(e, f, d))
+			}
+			return d.IsType()
+		})
+		return nil
+	})
+	if len(exact) > 0 {
+		return exact, err
+	}
+	return suffix, err
+}
@@ -0,0 +1,72 @@
+package symbols
+
+import (
+	"github.com/respawn-app/ksrc/internal/kotlin"
+	"github.com/respawn-app/ksrc/internal/resolve"
+	"github.com/respawn-app/ksrc/internal/srcjar"
+)
+
+// ImportResult maps one import directive to its declarations (named imports)
+// or to the modules providing the package (star imports).
+type ImportResult struct {
+	Import  kotlin.Import
+	Symbols []Symbol
+	Modules []resolve.Coord
+}
+
+func (r ImportResult) Resolved() bool {
+	return len(r.Symbols) > 0 || len(r.Modules) > 0
+}
+
+// ResolveImports resolves import directives against the given source jars in a
+// single pass. Results are returned in import order.
+func ResolveImports(archives *srcjar.Archives, jars []resolve.SourceJar, imports []kotlin.Import) ([]ImportResult, error) {
+	var fqns, packages []string
+	for _, imp := range imports {
+		if imp.Star {
+			packages = append(packages, imp.Path)
+			// A star import may also target a class's nested declarations.
+			fqns = append(fqns, imp.Path)
+			continue
+		}
+		fqns = append(fqns, imp.Path)
+		if parent := parentName(imp.Path); parent != "" {
+			// Enum entries are not declarations in the outline; fall back to the owner.
+			fqns = append(fqns, parent)
+		}
+	}
+	res, err := lookup(archives, jars, fqns, packages)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ImportResult, 0, len(imports))
+	for _, imp := range imports {
+		r := ImportResult{Import: imp}
+		if imp.Star {
+			r.Modules = res.packages[imp.Path]
+			if len(r.Modules) == 0 {
+				r.Symbols = res.decls[imp.Path]
+			}
+		} else {
+			r.Symbols = res.decls[imp.Path]
+			if len(r.Symbols) == 0 {
+				for _, owner := range res.decls[parentName(imp.Path)] {
+					if owner.Decl.IsType() {
+						r.Symbols = append(r.Symbols, owner)
+					}
+				}
+			}
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func parentName(fqn string) string {
+	for i := len(fqn) - 1; i >= 0; i-- {
+		if fqn[i] == '.' {
+			return fqn[:i]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+package symbols
+
+import (
+	"bytes"
+
+	"github.com/respawn-app/ksrc/internal/kotlin"
+	"github.com/respawn-app/ksrc/internal/resolve"
+	"github.com/respawn-app/ksrc/internal/srcjar"
+)
+
+// Lookup finds declarations by fully qualified name (package plus nested
+// declaration names, e.g. kotlinx.coroutines.flow.Flow.collect). Files are
+// only parsed when they mention one of the simple names.
+func Lookup(archives *srcjar.Archives, jars []resolve.SourceJar, fqns []string) (map[string][]Symbol, error) {
+	res, err := lookup(archives, jars, fqns, nil)
+	return res.decls, err
+}
+
+type lookupResult struct {
+	decls    map[string][]Symbol
+	packages map[string][]resolve.Coord
+}
+
+// lookup resolves declarations by FQN and, in the same pass, records which
+// jars contain files in the requested packages.
+func lookup(archives *srcjar.Archives, jars []resolve.SourceJar, fqns []string, packages []string) (lookupResult, error) {
+	res := lookupResult{
+		decls:    make(map[string][]Symbol),
+		packages: make(map[string][]resolve.Coord),
+	}
+	wanted := make(map[string]bool, len(fqns))
+	seenNeedles := make(map[string]bool)
+	var needles [][]byte
+	for _, fqn := range fqns {
+		wanted[fqn] = true
+		name := kotlin.SimpleName(fqn)
+		if !seenNeedles[name] {
+			seenNeedles[name] = true
+			needles = append(needles, []byte(name))
+		}
+	}
+	wantedPkgs := make(map[string]bool, len(packages))
+	for _, pkg := range packages {
+		wantedPkgs[pkg] = true
+	}
+	seenPkgJar := make(map[string]bool)
+
+	err := srcjar.Walk(archives, jars, srcjar.IsSource, func(e srcjar.Entry) error {
+		data, err := e.Read()
+		if err != nil {
+			return err
+		}
+		var pkg string
+		if containsAny(data, needles) {
+			f := kotlin.Parse(data, kotlin.LangForPath(e.Name()))
+			pkg = f.Package
+			f.Walk(func(d *kotlin.Decl) bool {
+				if fqn := f.FQN(d); wanted[fqn] {
+					res.decls[fqn] = append(res.decls[fqn], newSymbol(e, f, d))
+				}
+				return d.IsType()
+			})
+		} else if len(wantedPkgs) > 0 {
+			pkg = kotlin.ParsePackage(data)
+		}
+		if wantedPkgs[pkg] {
+			key := pkg + "|" + e.Jar.Path
+			if !seenPkgJar[key] {
+				seenPkgJar[key] = true
+				res.packages[pkg] = append(res.packages[pkg], e.Jar.Coord)
+			}
+		}
+		return nil
+	})
+	return res, err
+}
+
+func containsAny(data []byte, needles [][]byte) bool {
+	for _, n := range needles {
+		if bytes.Contains(data, n) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package symbols
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/respawn-app/ksrc/internal/kotlin"
+	"github.com/respawn-app/ksrc/internal/resolve"
+	"github.com/respawn-app/ksrc/internal/srcjar"
+)
+
+// Symbol is a declaration found in a source jar.
+type Symbol struct {
+	FileID    string
+	Jar       resolve.SourceJar
+	Inner     string
+	FQN       string
+	Kind      string
+	Signature string
+	StartLine int
+	Line      int
+	EndLine   int
+	Decl      *kotlin.Decl
+	File      *kotlin.File
+}
+
+func newSymbol(e srcjar.Entry, f *kotlin.File, d *kotlin.Decl) Symbol {
+	return Symbol{
+		FileID:    e.FileID(),
+		Jar:       e.Jar,
+		Inner:     e.Name(),
+		FQN:       f.FQN(d),
+		Kind:      d.Kind,
+		Signature: d.Signature,
+		StartLine: d.StartLine,
+		Line:      d.Line,
+		EndLine:   d.EndLine,
+		Decl:      d,
+		File:      f,
+	}
+}
+
+// Lines returns the source lines from..to (1-based, inclusive) of the symbol's file.
+func (s Symbol) Lines(archives *srcjar.Archives, from, to int) ([]string, error) {
+	if archives == nil {
+		archives = srcjar.NewArchives()
+		defer archives.Close()
+	}
+	entry, err := srcjar.Find(archives, s.Jar, s.Inner)
+	if err != nil {
+		return nil, err
+	}
+	data, err := entry.Read()
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(data), "\n")
+	from = max(from, 1)
+	to = min(to, len(lines))
+	if from > to {
+		return nil, nil
+	}
+	return lines[from-1 : to], nil
+}
+
+// scan parses every source file in jars whose content contains needle.
+func scan(archives *srcjar.Archives, jars []resolve.SourceJar, needle string, fn func(srcjar.Entry, *kotlin.File) error) error {
+	return srcjar.Walk(archives, jars, srcjar.IsSource, func(e srcjar.Entry) error {
+		data, err := e.Read()
+		if err != nil {
+			return err
+		}
+		if needle != "" && !bytes.Contains(data, []byte(needle)) {
+			return nil
+		}
+		return fn(e, kotlin.Parse(data, kotlin.LangForPath(e.Name())))
+	})
+}